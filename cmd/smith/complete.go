@@ -0,0 +1,518 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/config"
+	"github.com/JesusTinoco/go-smith/stacksmith/render"
+)
+
+// How long completion candidates are cached. The catalog changes with
+// releases, the stacks of an account whenever someone creates one.
+const (
+	catalogTTL = 24 * time.Hour
+	accountTTL = 5 * time.Minute
+)
+
+const bashCompletion = `# bash completion for smith, load with: source <(smith completion bash)
+_smith() {
+    local line=${COMP_LINE:0:COMP_POINT} words
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] ]] && words+=("")
+    local cur=${words[${#words[@]}-1]}
+    local IFS=$'\n'
+    COMPREPLY=($(smith __complete "${words[@]:1}" 2>/dev/null | cut -f1))
+    if [[ $cur == *:* && $COMP_WORDBREAKS == *:* ]]; then
+        local i prefix=${cur%:*}:
+        for i in "${!COMPREPLY[@]}"; do
+            COMPREPLY[i]=${COMPREPLY[i]#"$prefix"}
+        done
+    fi
+}
+complete -o default -F _smith smith
+`
+
+const zshCompletion = `#compdef smith
+# zsh completion for smith, load with: source <(smith completion zsh)
+_smith() {
+    local -a candidates
+    local line value
+    for line in "${(@f)$(smith __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -n $line ]] || continue
+        value=${line%%$'\t'*}
+        value=${value//:/\\:}
+        if [[ $line == *$'\t'* ]]; then
+            candidates+=("$value:${line#*$'\t'}")
+        else
+            candidates+=("$value")
+        fi
+    done
+    if (( ${#candidates} )); then
+        _describe smith candidates
+    else
+        _files
+    fi
+}
+compdef _smith smith
+`
+
+const fishCompletion = `# fish completion for smith, load with: smith completion fish | source
+function __smith_complete
+    set -l words (commandline -opc) (commandline -ct)
+    smith __complete $words[2..-1] 2>/dev/null
+end
+complete -c smith -f -a '(__smith_complete)'
+`
+
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// completion prints the completion script of a shell.
+func completion(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		fmt.Fprintln(stderr, "Usage: smith completion bash|zsh|fish")
+		return exitUsage
+	}
+	fmt.Fprint(stdout, completionScripts[args[0]])
+	return exitOK
+}
+
+// globalValueFlags are the global flags taking a value.
+var globalValueFlags = map[string]bool{"api-key": true, "profile": true, "config": true}
+
+// complete prints the candidates for the last of words, the command line
+// after "smith", one per line as "value" or "value<TAB>description".
+// Failing lookups only leave the dynamic candidates out.
+func complete(words []string, stdout io.Writer, newClient func(*config.Profile) (*stacksmith.Client, error)) int {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+	c := &completer{newClient: newClient}
+
+	i := 0
+	for i < len(prev) && strings.HasPrefix(prev[i], "-") {
+		name := strings.TrimLeft(prev[i], "-")
+		if globalValueFlags[name] && i+1 < len(prev) {
+			c.globals(name, prev[i+1])
+			i++
+		} else if j := strings.Index(name, "="); j >= 0 {
+			c.globals(name[:j], name[j+1:])
+		}
+		i++
+	}
+	rest := prev[i:]
+
+	var candidates []string
+	switch {
+	case i == len(prev) && i > 0 && globalValueFlags[strings.TrimLeft(prev[i-1], "-")]:
+		if strings.TrimLeft(prev[i-1], "-") == "profile" {
+			candidates = c.profiles()
+		}
+	case len(rest) == 0 && strings.HasPrefix(cur, "-"):
+		candidates = []string{"-api-key", "-config", "-profile"}
+	case len(rest) == 0:
+		for name := range groups {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+		candidates = append(candidates, "completion\tPrint a shell completion script.", "help\tShow the commands.")
+	case rest[0] == "completion" && len(rest) == 1:
+		candidates = []string{"bash", "fish", "zsh"}
+	case len(rest) == 1:
+		for _, cmd := range groups[rest[0]] {
+			candidates = append(candidates, cmd.name+"\t"+cmd.help)
+		}
+	default:
+		if commands, ok := groups[rest[0]]; ok {
+			if cmd, ok := findCommand(commands, rest[1]); ok {
+				candidates = c.command(cmd, rest[2:], cur)
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, cur) {
+			fmt.Fprintln(stdout, candidate)
+		}
+	}
+	return exitOK
+}
+
+// completer finds the candidates of a command line, building the client
+// only when a lookup needs it.
+type completer struct {
+	newClient  func(*config.Profile) (*stacksmith.Client, error)
+	configPath string
+	profile    string
+	apiKey     string
+
+	client *stacksmith.Client
+	cache  *completionCache
+}
+
+func (c *completer) globals(name, value string) {
+	switch name {
+	case "config":
+		c.configPath = value
+	case "profile":
+		c.profile = value
+	case "api-key":
+		c.apiKey = value
+	}
+}
+
+// command completes the flags and arguments of cmd, given the words typed
+// after its name.
+func (c *completer) command(cmd command, words []string, cur string) []string {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.String("o", "", "output `format`")
+	fs.String("columns", "", "comma separated field `paths` shown by the table format")
+	cmd.flags(fs)
+
+	values := make(map[string][]string)
+	var positional []string
+	for i := 0; i < len(words); i++ {
+		name := strings.TrimLeft(words[i], "-")
+		if !strings.HasPrefix(words[i], "-") || name == "" {
+			positional = append(positional, words[i])
+			continue
+		}
+		if j := strings.Index(name, "="); j >= 0 {
+			values[name[:j]] = append(values[name[:j]], name[j+1:])
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil || isBoolFlag(f) {
+			continue
+		}
+		if i+1 == len(words) {
+			return c.flagValue(name, cur, values)
+		}
+		values[name] = append(values[name], words[i+1])
+		i++
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		var candidates []string
+		fs.VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			candidates = append(candidates, "-"+f.Name+"\t"+usage)
+		})
+		return candidates
+	}
+
+	args := strings.Fields(cmd.args)
+	if len(args) == 0 {
+		return nil
+	}
+	arg := args[len(args)-1]
+	if len(positional) < len(args) {
+		arg = args[len(positional)]
+	} else if !strings.HasSuffix(arg, "...]") {
+		return nil
+	}
+	return c.argument(strings.TrimSuffix(strings.Trim(arg, "[]"), "..."), positional)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// argument completes a positional argument from its name in the usage.
+func (c *completer) argument(name string, previous []string) []string {
+	switch {
+	case name == "STACK":
+		return c.stacks()
+	case name == "HOOK" && len(previous) > 0:
+		return c.hooks(previous[0])
+	case name == "COMPONENT":
+		return c.components()
+	case strings.Contains(name, "|"):
+		return strings.Split(name, "|")
+	}
+	return nil
+}
+
+// flagValue completes the value of the flag name.
+func (c *completer) flagValue(name, cur string, values map[string][]string) []string {
+	switch name {
+	case "o":
+		return []string{render.Table, render.JSON, render.YAML, render.Template + "=", render.JSONPath + "="}
+	case "component":
+		if i := strings.Index(cur, ":"); i >= 0 {
+			return c.componentVersions(cur[:i])
+		}
+		return c.components()
+	case "os":
+		if i := strings.Index(cur, ":"); i >= 0 {
+			return c.componentVersions(cur[:i])
+		}
+		return c.oses()
+	case "flavor":
+		if components := values["component"]; len(components) > 0 {
+			return c.flavors(parseComponent(components[0]).ID)
+		}
+		return c.flavors("")
+	case "profile":
+		return c.profiles()
+	}
+	return nil
+}
+
+func (c *completer) loadProfile() (*config.Profile, error) {
+	profile, err := loadProfile(c.configPath, c.profile)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		profile.APIKey = c.apiKey
+	}
+	return profile, nil
+}
+
+// setup builds the client and opens the cache of the profile.
+func (c *completer) setup() bool {
+	if c.client != nil {
+		return true
+	}
+	profile, err := c.loadProfile()
+	if err != nil {
+		return false
+	}
+	key, err := profile.Key()
+	if err != nil {
+		return false
+	}
+	profile.APIKey = key
+	client, err := c.newClient(profile)
+	if err != nil {
+		return false
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return false
+	}
+	c.client = client
+	c.cache = &completionCache{
+		dir:     dir,
+		catalog: cacheKey(profile.BaseURL),
+		account: cacheKey(profile.BaseURL, key),
+	}
+	return true
+}
+
+// cacheKey names the cache of the given settings without revealing them.
+func cacheKey(settings ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(settings, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *completer) profiles() []string {
+	var cfg *config.Config
+	var err error
+	if c.configPath != "" {
+		cfg, err = config.Load(c.configPath)
+	} else {
+		cfg, err = config.LoadDefault()
+	}
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *completer) stacks() []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("stacks", true, accountTTL, func() ([]string, error) {
+		stacks, _, err := c.client.Stacks.ListAll()
+		candidates := make([]string, len(stacks))
+		for i, stack := range stacks {
+			candidates[i] = stack.ID + "\t" + stack.Name
+		}
+		return candidates, err
+	})
+}
+
+func (c *completer) hooks(stackID string) []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("hooks-"+stackID, true, accountTTL, func() ([]string, error) {
+		hooks, _, err := c.client.Hooks.ListAll(stackID)
+		candidates := make([]string, len(hooks))
+		for i, hook := range hooks {
+			candidates[i] = hook.ID + "\t" + hook.URL
+		}
+		return candidates, err
+	})
+}
+
+func (c *completer) components() []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("components", false, catalogTTL, func() ([]string, error) {
+		return itemCandidates(c.client.Discovery.ComponentsList(""))
+	})
+}
+
+func (c *completer) oses() []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("oses", false, catalogTTL, func() ([]string, error) {
+		return itemCandidates(c.client.Discovery.OsesList(""))
+	})
+}
+
+func itemCandidates(items *stacksmith.ListItems, _ *http.Response, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]string, len(items.Items))
+	for i, item := range items.Items {
+		candidates[i] = item.ID + "\t" + item.Name
+	}
+	return candidates, nil
+}
+
+// componentVersions completes "id:version" from Item.Versions, for
+// components and OSes alike: Discovery.GetComponent serves both, as
+// preflight relies on.
+func (c *completer) componentVersions(id string) []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("component-"+id, false, catalogTTL, func() ([]string, error) {
+		item, _, err := c.client.Discovery.GetComponent(id)
+		if err != nil {
+			return nil, err
+		}
+		return versionCandidates(*item), nil
+	})
+}
+
+func versionCandidates(item stacksmith.Item) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, v := range item.Versions {
+		if !seen[v.Version] {
+			seen[v.Version] = true
+			candidates = append(candidates, item.ID+":"+v.Version+"\t"+v.Branch)
+		}
+	}
+	return candidates
+}
+
+// flavors completes the flavors of a component, or every flavor.
+func (c *completer) flavors(componentID string) []string {
+	if !c.setup() {
+		return nil
+	}
+	return c.cache.get("flavors-"+componentID, false, catalogTTL, func() ([]string, error) {
+		var candidates []string
+		pag := &stacksmith.PaginationParams{Page: 1, PerPage: stacksmith.MaxPerPage}
+		for {
+			var flavors *stacksmith.Flavors
+			var err error
+			if componentID != "" {
+				flavors, _, err = c.client.Discovery.GetFlavorsFrom(componentID, pag)
+			} else {
+				flavors, _, err = c.client.Discovery.FlavorsList(pag)
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, flavor := range flavors.Items {
+				candidates = append(candidates, flavor.ID+"\t"+flavor.Name)
+			}
+			if pag.Page >= flavors.TotalPages {
+				return candidates, nil
+			}
+			pag.Page++
+		}
+	})
+}
+
+// cacheDir returns $SMITH_CACHE_DIR, or else smith in the user cache directory.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("SMITH_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smith"), nil
+}
+
+// completionCache keeps completion candidates in files, the catalog ones
+// per API base URL and the others per account, told apart by base URL and
+// API key rather than profile name, which flags and the environment can
+// point at another account.
+type completionCache struct {
+	dir     string
+	catalog string
+	account string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// get returns the cached candidates of key when younger than ttl, and else
+// fetches and caches them. Stale candidates are better than none when the
+// fetch fails.
+func (c *completionCache) get(key string, perAccount bool, ttl time.Duration, fetch func() ([]string, error)) []string {
+	if perAccount {
+		key = c.account + "-" + key
+	} else {
+		key = c.catalog + "-" + key
+	}
+	path := filepath.Join(c.dir, "completion", unsafeFileChars.ReplaceAllString(key, "_")+".json")
+
+	var cached []string
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		if data, err := ioutil.ReadFile(path); err == nil && json.Unmarshal(data, &cached) == nil {
+			if time.Since(info.ModTime()) < ttl {
+				return cached
+			}
+		}
+	}
+
+	candidates, err := fetch()
+	if err != nil {
+		return cached
+	}
+	if data, err := json.Marshal(candidates); err == nil {
+		if os.MkdirAll(filepath.Dir(path), 0700) == nil {
+			ioutil.WriteFile(path, data, 0600)
+		}
+	}
+	return candidates
+}
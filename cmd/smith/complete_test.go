@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+func completeLine(t *testing.T, words ...string) []string {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	args := append([]string{"__complete", "-config", os.DevNull, "-api-key", "my_api_key"}, words...)
+	if code := run(args, stdout, stderr, utils.RedirectClient(server.URL)); code != exitOK {
+		t.Fatalf("smith __complete exited with %d: %s", code, stderr)
+	}
+	return strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+}
+
+func TestComplete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+	os.Setenv("SMITH_CACHE_DIR", dir)
+	defer os.Unsetenv("SMITH_CACHE_DIR")
+
+	stackLists := 0
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		stackLists++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":2,"total_pages":1,"items":[
+			{"id":"bzr9nhz","name":"php-app"},{"id":"bk4a5cx","name":"java-app"}]}`))
+	})
+	mux.HandleFunc("/api/v1/components/apache", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("component"))
+	})
+	mux.HandleFunc("/api/v1/components/debian", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"debian","name":"Debian","versions":[
+			{"version":"wheezy","revision":7,"branch":"stable"},{"version":"jessie","revision":2,"branch":"stable"}]}`))
+	})
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("all_items"))
+	})
+	mux.HandleFunc("/api/v1/components/apache/flavors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":[{"id":"apache-base","name":"Apache base"}]}`))
+	})
+
+	cases := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"st"}, []string{"stacks"}},
+		{[]string{"hooks", "reg"}, []string{"register\tRegister a URL called when the stack is updated."}},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+		{[]string{"stacks", "get", "b"}, []string{"bzr9nhz\tphp-app", "bk4a5cx\tjava-app"}},
+		{[]string{"stacks", "get", "-o", "y"}, []string{"yaml"}},
+		{[]string{"stacks", "get", "bzr9nhz", "b"}, []string{""}},
+		{[]string{"policy", "check", "bzr9nhz", "bk"}, []string{"bk4a5cx\tjava-app"}},
+		{[]string{"stacks", "create", "-name", "app", "-component", "je"}, []string{"jetty\tJetty"}},
+		{[]string{"stacks", "create", "-component", "apache:2.4.2"}, []string{"apache:2.4.23\tstable", "apache:2.4.20\tstable"}},
+		{[]string{"stacks", "create", "-os", "debian:w"}, []string{"debian:wheezy\tstable"}},
+		{[]string{"stacks", "create", "-component", "apache", "-flavor", ""}, []string{"apache-base\tApache base"}},
+		{[]string{"user", "notifications", ""}, []string{"on", "off"}},
+		{[]string{"stacks", "update", "-shar"}, []string{"-shared\tshare the stack"}},
+	}
+	for _, c := range cases {
+		if got := completeLine(t, c.words...); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("smith __complete %q printed %q, want %q", c.words, got, c.expected)
+		}
+	}
+
+	completeLine(t, "hooks", "list", "")
+	if stackLists != 1 {
+		t.Errorf("Stacks listed %d times, want once thanks to the cache", stackLists)
+	}
+
+	// Another API key is another account, with its own cache.
+	args := []string{"__complete", "-config", os.DevNull, "-api-key", "other_api_key", "hooks", "list", ""}
+	if code := run(args, ioutil.Discard, ioutil.Discard, utils.RedirectClient(server.URL)); code != exitOK || stackLists != 2 {
+		t.Errorf("smith __complete with another API key exited with %d after %d stack lists, want 2", code, stackLists)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "completion"))
+	for _, f := range files {
+		if strings.Contains(f.Name(), "api_key") {
+			t.Errorf("cache file %s reveals the API key", f.Name())
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		if code := run([]string{"completion", shell}, stdout, stderr, nil); code != exitOK {
+			t.Fatalf("smith completion %s exited with %d: %s", shell, code, stderr)
+		}
+		if !strings.Contains(stdout.String(), "smith __complete") {
+			t.Errorf("smith completion %s printed %s", shell, stdout)
+		}
+	}
+	if code := run([]string{"completion", "tcsh"}, ioutil.Discard, ioutil.Discard, nil); code != exitUsage {
+		t.Errorf("smith completion tcsh exited with %d, want %d", code, exitUsage)
+	}
+}
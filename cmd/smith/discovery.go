@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/depgraph"
+)
+
+var discoveryCommands = []command{
+	listItemsCommand("components", "List the available components.", func(d *stacksmith.DiscoveryService, query string) (*stacksmith.ListItems, *http.Response, error) {
+		return d.ComponentsList(query)
+	}),
+	listItemsCommand("runtimes", "List the available runtimes.", func(d *stacksmith.DiscoveryService, query string) (*stacksmith.ListItems, *http.Response, error) {
+		return d.RuntimesList(query)
+	}),
+	listItemsCommand("services", "List the available services.", func(d *stacksmith.DiscoveryService, query string) (*stacksmith.ListItems, *http.Response, error) {
+		return d.ServicesList(query)
+	}),
+	listItemsCommand("oses", "List the available OSes.", func(d *stacksmith.DiscoveryService, query string) (*stacksmith.ListItems, *http.Response, error) {
+		return d.OsesList(query)
+	}),
+	{
+		name: "component",
+		args: "COMPONENT",
+		help: "Show a component and its versions.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Discovery.GetComponent(args[0])
+		}),
+	},
+	{
+		name: "flavors",
+		args: "[COMPONENT]",
+		help: "List the flavors, of a component when given.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if len(args) > 0 {
+					return e.client.Discovery.GetFlavorsFrom(args[0], firstPage(pag))
+				}
+				return e.client.Discovery.FlavorsList(firstPage(pag))
+			}
+		},
+	},
+	{
+		name: "changelog",
+		args: "COMPONENT",
+		help: "Show the changelog of a component.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			rangeParams := new(stacksmith.RangeParams)
+			fs.StringVar(&rangeParams.From, "from", "", "first version")
+			fs.StringVar(&rangeParams.To, "to", "", "last version")
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				return e.client.Discovery.GetChangelogFrom(args[0], rangeParams, firstPage(pag))
+			}
+		},
+	},
+	{
+		name: "deps",
+		args: "COMPONENT",
+		help: "List the dependencies of a component.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Discovery.GetDependenciesFrom(args[0])
+		}),
+	},
+	{
+		name: "graph",
+		args: "[COMPONENT...]",
+		help: "Print the transitive dependencies of components or of a StackDefinition JSON file as DOT or JSON.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			file := fs.String("f", "", "read the StackDefinition from `file`")
+			format := fs.String("format", "dot", "`format` of the graph: dot or json")
+			concurrency := fs.Int("concurrency", depgraph.DefaultConcurrency, "requests in flight")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				write := depgraph.WriteDOT
+				switch *format {
+				case "dot":
+				case "json":
+					write = depgraph.WriteJSON
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown graph format %q", *format))
+				}
+				def := new(stacksmith.StackDefinition)
+				if *file != "" {
+					data, err := ioutil.ReadFile(*file)
+					if err != nil {
+						return nil, nil, err
+					}
+					if err := json.Unmarshal(data, def); err != nil {
+						return nil, nil, fmt.Errorf("%s: %v", *file, err)
+					}
+				}
+				for _, arg := range args {
+					def.Components = append(def.Components, parseComponent(arg))
+				}
+				if def.Name == "" {
+					def.Name = strings.Join(args, " ")
+				}
+				if def.OS.ID == "" && len(def.Components) == 0 {
+					return nil, nil, usageError("graph needs components or a StackDefinition file")
+				}
+				g, err := depgraph.NewResolver(e.client.Discovery, *concurrency).Definition(context.Background(), def)
+				if err != nil {
+					return nil, nil, err
+				}
+				return nil, nil, write(e.stdout, g)
+			}
+		},
+	},
+}
+
+func listItemsCommand(name, help string, list func(*stacksmith.DiscoveryService, string) (*stacksmith.ListItems, *http.Response, error)) command {
+	return command{
+		name: name,
+		args: "[QUERY]",
+		help: help,
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			query := ""
+			if len(args) > 0 {
+				query = args[0]
+			}
+			return list(e.client.Discovery, query)
+		}),
+	}
+}
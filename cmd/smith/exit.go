@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/preflight"
+)
+
+// Exit codes, mapping the errors returned by the stacksmith package.
+const (
+	exitOK = 0
+	// exitFailure is any error not covered below.
+	exitFailure = 1
+	// exitUsage is a command line error.
+	exitUsage = 2
+	// exitAPI is an APIError returned by Stacksmith.
+	exitAPI = 3
+	// exitNotFound is an APIError for a missing resource.
+	exitNotFound = 4
+	// exitAuth is an APIError for a missing or rejected API key.
+	exitAuth = 5
+	// exitNetwork is a request that could not be sent or answered, reported
+	// as a *url.Error or a net.Error.
+	exitNetwork = 6
+	// exitPolicy is a stack violating the policy it was checked against.
+	exitPolicy = 7
+)
+
+func exitCode(resp *http.Response, err error) int {
+	switch err.(type) {
+	case usageError:
+		return exitUsage
+	case preflight.Problems:
+		return exitFailure
+	case stacksmith.APIError:
+		if resp == nil {
+			return exitAPI
+		}
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return exitNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return exitAuth
+		}
+		return exitAPI
+	case *url.Error, net.Error:
+		return exitNetwork
+	}
+	return exitFailure
+}
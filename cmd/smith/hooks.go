@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+var hooksCommands = []command{
+	{
+		name: "list",
+		args: "STACK",
+		help: "List the hooks of a stack.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if pag.Page == 0 {
+					return e.client.Hooks.ListAll(args[0])
+				}
+				return e.client.Hooks.List(args[0], pag)
+			}
+		},
+	},
+	{
+		name: "register",
+		args: "STACK URL",
+		help: "Register a URL called when the stack is updated.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Hooks.Register(args[0], &stacksmith.HookParams{URL: args[1]})
+		}),
+	},
+	{
+		name: "update",
+		args: "STACK HOOK URL",
+		help: "Change the URL of a hook.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Hooks.Update(args[0], args[1], &stacksmith.HookParams{URL: args[2]})
+		}),
+	},
+	{
+		name: "delete",
+		args: "STACK HOOK",
+		help: "Delete a hook.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Hooks.Delete(args[0], args[1])
+		}),
+	},
+	{
+		name: "test",
+		args: "STACK HOOK",
+		help: "Send a test payload to a hook.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Hooks.Test(args[0], args[1])
+		}),
+	},
+}
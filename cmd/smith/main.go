@@ -0,0 +1,275 @@
+// Command smith is a command-line client for the Stacksmith API.
+//
+// Usage:
+//
+//	smith [-profile NAME] [-api-key KEY] <group> <command> [flags] [args]
+//
+// Settings come from the profiles of the configuration file read by package
+// config, overridden by the environment and then by the flags.
+//
+// Run "smith help" for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/config"
+	"github.com/JesusTinoco/go-smith/stacksmith/preflight"
+	"github.com/JesusTinoco/go-smith/stacksmith/render"
+)
+
+// command is one "smith <group> <name>" command.
+type command struct {
+	name string
+	args string
+	help string
+	// flags defines the flags of the command and returns the function running it.
+	flags func(fs *flag.FlagSet) runFunc
+}
+
+// runFunc runs a command on its positional arguments and returns the result to print.
+type runFunc func(env *env, args []string) (interface{}, *http.Response, error)
+
+// env holds what commands share.
+type env struct {
+	client *stacksmith.Client
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var groups = map[string][]command{
+	"stacks":    stacksCommands,
+	"hooks":     hooksCommands,
+	"discovery": discoveryCommands,
+	"user":      userCommands,
+	"policy":    policyCommands,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, nil))
+}
+
+// run executes the command line args and returns the exit code. httpClient
+// is handed to the Stacksmith client.
+func run(args []string, stdout, stderr io.Writer, httpClient *http.Client) int {
+	if len(args) > 0 && args[0] == "__complete" {
+		return complete(args[1:], stdout, func(p *config.Profile) (*stacksmith.Client, error) {
+			return p.NewClient(httpClient)
+		})
+	}
+
+	global := flag.NewFlagSet("smith", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	apiKey := global.String("api-key", "", "Stacksmith API key (default from the profile or $STACKSMITH_API_KEY)")
+	profileName := global.String("profile", "", "configuration `profile` (default $SMITH_PROFILE or the default profile)")
+	configPath := global.String("config", "", "configuration `file` (default $SMITH_CONFIG or ~/.config/smith/config.yaml)")
+	global.Usage = func() { usage(stderr) }
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	args = global.Args()
+
+	if len(args) == 0 || args[0] == "help" {
+		usage(stdout)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	if args[0] == "completion" {
+		return completion(args[1:], stdout, stderr)
+	}
+
+	commands, ok := groups[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "smith: unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+	if len(args) < 2 {
+		groupUsage(stderr, args[0], commands)
+		return exitUsage
+	}
+	cmd, ok := findCommand(commands, args[1])
+	if !ok {
+		fmt.Fprintf(stderr, "smith: unknown command %q\n", strings.Join(args[:2], " "))
+		groupUsage(stderr, args[0], commands)
+		return exitUsage
+	}
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		fmt.Fprintf(stderr, "smith: %v\n", err)
+		return exitUsage
+	}
+	if *apiKey != "" {
+		profile.APIKey = *apiKey
+	}
+
+	fs := flag.NewFlagSet("smith "+args[0]+" "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: smith %s %s [flags] %s\n\n%s\n", args[0], cmd.name, cmd.args, cmd.help)
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", render.Table, "output `format`: table, json, yaml, template=TEMPLATE or jsonpath=EXPRESSION")
+	columns := fs.String("columns", "", "comma separated field `paths` shown by the table format")
+	do := cmd.flags(fs)
+	if profile.Output != "" {
+		setDefault(fs, "o", profile.Output)
+	}
+	if profile.PerPage > 0 {
+		setDefault(fs, "per-page", strconv.Itoa(profile.PerPage))
+	}
+	if err := fs.Parse(args[2:]); err != nil {
+		return exitUsage
+	}
+	opts, err := render.ParseFormat(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "smith: %v\n", err)
+		return exitUsage
+	}
+	if *columns != "" {
+		opts.Columns = strings.Split(*columns, ",")
+	}
+	if fs.NArg() < requiredArgs(cmd.args) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	client, err := profile.NewClient(httpClient)
+	if err != nil {
+		fmt.Fprintf(stderr, "smith: %v, set -api-key or $STACKSMITH_API_KEY\n", err)
+		return exitUsage
+	}
+	e := &env{
+		client: client,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	result, resp, err := do(e, fs.Args())
+	if problems, ok := err.(preflight.Problems); ok {
+		for _, p := range problems {
+			fmt.Fprintf(stderr, "smith: %v\n", p)
+		}
+		return exitCode(resp, err)
+	}
+	if err != nil {
+		// A partial result, such as a bulk run stopped early, is still
+		// printed before the error.
+		if _, ok := result.(partial); ok {
+			if err := render.Render(stdout, result, opts); err != nil {
+				fmt.Fprintf(stderr, "smith: %v\n", err)
+			}
+		}
+		fmt.Fprintf(stderr, "smith: %v\n", err)
+		return exitCode(resp, err)
+	}
+	if result != nil {
+		if err := render.Render(stdout, result, opts); err != nil {
+			fmt.Fprintf(stderr, "smith: %v\n", err)
+			return exitFailure
+		}
+	}
+	if f, ok := result.(failer); ok && f.Failed() {
+		return exitPolicy
+	}
+	if p, ok := result.(partial); ok && p.Err() != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// failer is a result printed as any other, such as policy verdicts, that
+// can still make the command fail.
+type failer interface {
+	Failed() bool
+}
+
+// partial is a result printed as any other, such as the outcomes of a bulk
+// operation, whose failed items make the command fail.
+type partial interface {
+	Err() error
+}
+
+// loadProfile reads the profile from the configuration file at path, or
+// at the default path when empty.
+func loadProfile(path, name string) (*config.Profile, error) {
+	var c *config.Config
+	var err error
+	if path != "" {
+		c, err = config.Load(path)
+	} else {
+		c, err = config.LoadDefault()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Profile(name)
+}
+
+// setDefault changes the default value of a flag, if the command has it.
+func setDefault(fs *flag.FlagSet, name, value string) {
+	if f := fs.Lookup(name); f != nil {
+		f.Value.Set(value)
+		f.DefValue = value
+	}
+}
+
+func findCommand(commands []command, name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// requiredArgs counts the arguments of a command usage not in brackets.
+func requiredArgs(args string) int {
+	n := 0
+	for _, arg := range strings.Fields(args) {
+		if !strings.HasPrefix(arg, "[") {
+			n++
+		}
+	}
+	return n
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: smith [-profile NAME] [-api-key KEY] <group> <command> [flags] [args]")
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w)
+		groupUsage(w, name, groups[name])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "smith completion:\n  %-32s %s\n", "bash|zsh|fish", "Print a shell completion script.")
+}
+
+func groupUsage(w io.Writer, group string, commands []command) {
+	fmt.Fprintf(w, "smith %s:\n", group)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-32s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.help)
+	}
+}
+
+// usageError reports a misuse of a command that its flags cannot catch.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
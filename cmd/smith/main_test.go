@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var (
+	mux    *http.ServeMux
+	server *httptest.Server
+)
+
+func setup() {
+	mux = http.NewServeMux()
+	server = httptest.NewServer(mux)
+}
+
+func teardown() {
+	server.Close()
+}
+
+func smith(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(append([]string{"-config", os.DevNull, "-api-key", "my_api_key"}, args...), stdout, stderr, utils.RedirectClient(server.URL))
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_StacksGet(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+
+	code, stdout, stderr := smith("stacks", "get", "-o", "json", "bzr9nhz")
+	if code != exitOK {
+		t.Fatalf("smith stacks get exited with %d: %s", code, stderr)
+	}
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal([]byte(stdout), stack); err != nil || stack.ID != "bzr9nhz" {
+		t.Errorf("smith stacks get printed %s", stdout)
+	}
+}
+
+func TestRun_Output(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz/hooks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":[{"id":"h1","url":"https://example.com/hooks"}]}`))
+	})
+
+	var cases = []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"hooks", "list", "bzr9nhz"}, "ID  URL\nh1  https://example.com/hooks\n"},
+		{[]string{"hooks", "list", "-columns", "url", "bzr9nhz"}, "URL\nhttps://example.com/hooks\n"},
+		{[]string{"hooks", "list", "-o", "jsonpath={[*].id}", "bzr9nhz"}, "h1\n"},
+		{[]string{"hooks", "list", "-o", "yaml", "bzr9nhz"}, "- id: h1\n  url: https://example.com/hooks\n"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := smith(c.args...)
+		if code != exitOK || stdout != c.expected {
+			t.Errorf("smith %s exited with %d and printed\n%s%s\nwant\n%s", strings.Join(c.args, " "), code, stdout, stderr, c.expected)
+		}
+	}
+
+	if code, _, _ := smith("hooks", "list", "-o", "xml", "bzr9nhz"); code != exitUsage {
+		t.Errorf("smith with an unknown format exited with %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_StacksCreate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var received stacksmith.StackDefinition
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack_response"))
+	})
+
+	code, _, stderr := smith("stacks", "create", "-name", "My ROR stack", "-component", "ruby", "-os", "debian:wheezy", "-flavor", "rails")
+	if code != exitOK {
+		t.Fatalf("smith stacks create exited with %d: %s", code, stderr)
+	}
+	expected := new(stacksmith.StackDefinition)
+	json.Unmarshal(utils.GetJSON("create_stack_definition"), expected)
+	if received.Name != expected.Name || received.OS != expected.OS || received.Flavor != expected.Flavor ||
+		len(received.Components) != 1 || received.Components[0] != expected.Components[0] {
+		t.Errorf("smith stacks create sent %+v, want %+v", received, expected)
+	}
+
+	if code, _, _ := smith("stacks", "create", "-component", "ruby"); code != exitUsage {
+		t.Errorf("smith stacks create without name exited with %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_StacksUpdate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var received stacksmith.StackParams
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &received)
+			w.Write(utils.GetJSON("stack_response"))
+			return
+		}
+		w.Write(utils.GetJSON("stack"))
+	})
+
+	if code, _, stderr := smith("stacks", "update", "-shared", "bzr9nhz"); code != exitOK {
+		t.Fatalf("smith stacks update exited with %d: %s", code, stderr)
+	}
+	expected := stacksmith.StackParams{Name: "My ROR stack2", NotificationsEnabled: true, Shared: true}
+	if received != expected {
+		t.Errorf("smith stacks update sent %+v, want %+v", received, expected)
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":"404","error":"Stack not found"}`))
+	})
+	mux.HandleFunc("/api/v1/stacks/locked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":"401","error":"Invalid API key"}`))
+	})
+	mux.HandleFunc("/api/v1/stacks/conflict/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"status":"422","error":"Stack is being generated"}`))
+	})
+
+	var cases = []struct {
+		args []string
+		want int
+	}{
+		{[]string{"stacks", "get", "missing"}, exitNotFound},
+		{[]string{"stacks", "delete", "locked"}, exitAuth},
+		{[]string{"stacks", "regenerate", "conflict"}, exitAPI},
+		{[]string{"stacks", "get"}, exitUsage},
+		{[]string{"stacks", "explode"}, exitUsage},
+		{[]string{"galaxies"}, exitUsage},
+		{[]string{"user", "notifications", "maybe"}, exitUsage},
+		{[]string{"stacks", "create", "-f", "/nonexistent.json"}, exitFailure},
+		{[]string{"stacks", "junit", "-min-severity", "hihg", "missing"}, exitUsage},
+	}
+	for _, c := range cases {
+		if code, _, _ := smith(c.args...); code != c.want {
+			t.Errorf("smith %s exited with %d, want %d", strings.Join(c.args, " "), code, c.want)
+		}
+	}
+
+	server.Close()
+	if code, _, _ := smith("stacks", "get", "missing"); code != exitNetwork {
+		t.Errorf("smith without server exited with %d, want %d", code, exitNetwork)
+	}
+}
+
+func TestRun_Profile(t *testing.T) {
+	setup()
+	defer teardown()
+
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte(`profiles:
+  test:
+    api_key: profile_key
+    base_url: `+server.URL+`/api/v1
+    per_page: 5
+    output: json
+`), 0600)
+
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("api_key"); got != "profile_key" {
+			t.Errorf("Request api_key = %q, want profile_key", got)
+		}
+		if got := r.FormValue("per_page"); got != "5" {
+			t.Errorf("Request per_page = %q, want 5", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("list_stacks"))
+	})
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run([]string{"-config", path, "-profile", "test", "stacks", "list", "-page", "1"}, stdout, stderr, nil)
+	if code != exitOK {
+		t.Fatalf("smith stacks list exited with %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout.String(), "{") {
+		t.Errorf("smith stacks list printed %s, want JSON", stdout)
+	}
+
+	code = run([]string{"-config", path, "-profile", "missing", "stacks", "list"}, stdout, stderr, nil)
+	if code != exitUsage {
+		t.Errorf("smith with a missing profile exited with %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_PolicyCheck(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz/vulnerabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("vulnerabilities"))
+	})
+
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	ioutil.WriteFile(path, []byte(`max_severity: medium
+allow:
+  - vulnerability: CVE-2016-0800
+  - vulnerability: CVE-2016-2107
+`), 0644)
+
+	if code, stdout, stderr := smith("policy", "check", "-f", path, "bzr9nhz"); code != exitOK {
+		t.Errorf("smith policy check exited with %d: %s%s", code, stdout, stderr)
+	}
+	code, stdout, _ := smith("policy", "check", "-f", path, "-max-severity", "low", "-o", "json", "bzr9nhz")
+	if code != exitPolicy {
+		t.Errorf("smith policy check of a violating stack exited with %d", code)
+	}
+	if !strings.Contains(stdout, `"reason": "CVE-2015-7551 (medium) in ruby is above low allowed by the policy"`) {
+		t.Errorf("smith policy check printed %s", stdout)
+	}
+	if code, _, _ := smith("policy", "check", "-max-severity", "severe", "bzr9nhz"); code != exitUsage {
+		t.Errorf("smith policy check with an unknown severity exited with %d", code)
+	}
+}
+
+func TestRun_StacksVulns(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz/vulnerabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		page := r.FormValue("page")
+		w.Write([]byte(`{"total_entries":2,"total_pages":2,"items":[{"name":"CVE-2016-000` + page + `","severity":"low"}]}`))
+	})
+
+	code, stdout, stderr := smith("stacks", "vulns", "-o", "jsonpath={[*].name}", "bzr9nhz")
+	if code != exitOK || stdout != "CVE-2016-0001\nCVE-2016-0002\n" {
+		t.Errorf("smith stacks vulns exited with %d and printed %s%s", code, stdout, stderr)
+	}
+	code, stdout, stderr = smith("stacks", "vulns", "-page", "2", "-o", "jsonpath={.items[*].name}", "bzr9nhz")
+	if code != exitOK || stdout != "CVE-2016-0002\n" {
+		t.Errorf("smith stacks vulns -page 2 exited with %d and printed %s%s", code, stdout, stderr)
+	}
+}
+
+func TestRun_StacksVulnsDiff(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz/vulnerabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":[
+			{"name":"CVE-2016-4450","severity":"high","ranges":[{"component":"ruby","from":"2.2","to":"2.2.3"}]}]}`))
+	})
+
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "before.json")
+	ioutil.WriteFile(path, utils.GetJSON("stack"), 0644)
+
+	code, stdout, stderr := smith("stacks", "vulns-diff", path)
+	if code != exitOK {
+		t.Fatalf("smith stacks vulns-diff exited with %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "Vulnerabilities of bzr9nhz: 0 fixed, 1 introduced, 8 unchanged\n") || !strings.Contains(stdout, "CVE-2016-4450") {
+		t.Errorf("smith stacks vulns-diff printed %s", stdout)
+	}
+	if code, _, _ := smith("stacks", "vulns-diff", "-format", "html", path); code != exitUsage {
+		t.Errorf("smith stacks vulns-diff -format html exited with %d", code)
+	}
+	if code, _, _ := smith("stacks", "vulns-diff", "bzr9nhz"); code != exitUsage {
+		t.Errorf("smith stacks vulns-diff of a stack with itself exited with %d", code)
+	}
+}
+
+func TestRun_DiscoveryGraph(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/components/ruby/dependencies" {
+			w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":["openssl"]}`))
+			return
+		}
+		w.Write([]byte(`{"total_entries":0,"total_pages":1,"items":[]}`))
+	})
+
+	code, stdout, stderr := smith("discovery", "graph", "ruby:2.2.3")
+	if code != exitOK {
+		t.Fatalf("smith discovery graph exited with %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "digraph \"ruby:2.2.3\" {\n") || !strings.Contains(stdout, "\"ruby\" -> \"openssl\";\n") {
+		t.Errorf("smith discovery graph printed %s", stdout)
+	}
+	if code, _, _ := smith("discovery", "graph"); code != exitUsage {
+		t.Errorf("smith discovery graph without components exited with %d", code)
+	}
+}
+
+func TestRun_StacksValidate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/components", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"id":"ruby"}]}`))
+	})
+	mux.HandleFunc("/api/v1/oses", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"id":"debian"}]}`))
+	})
+	created := false
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		created = true
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack_response"))
+	})
+
+	if code, _, stderr := smith("stacks", "validate", "-name", "My ROR stack", "-component", "ruby", "-os", "debian"); code != exitOK {
+		t.Errorf("smith stacks validate exited with %d: %s", code, stderr)
+	}
+	code, _, stderr := smith("stacks", "create", "-validate", "-name", "My ROR stack", "-component", "rubby", "-os", "debain")
+	if code != exitFailure || created {
+		t.Errorf("smith stacks create -validate of an invalid definition exited with %d", code)
+	}
+	expected := "smith: os.id: unknown OS \"debain\", did you mean \"debian\"?\n" +
+		"smith: components[0].id: unknown component \"rubby\", did you mean \"ruby\"?\n"
+	if stderr != expected {
+		t.Errorf("smith stacks create -validate printed %q, want %q", stderr, expected)
+	}
+}
+
+func TestRun_StacksResolve(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/components/debian", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"debian","versions":[{"version":"wheezy","revision":9,"branch":"stable"}]}`))
+	})
+	mux.HandleFunc("/api/v1/components/ruby", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"ruby","versions":[
+			{"version":"2.3.1","revision":0,"branch":"stable"},
+			{"version":"2.2.5","revision":1,"branch":"stable"},
+			{"version":"2.2.3","revision":3,"branch":"stable"}]}`))
+	})
+
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stack.json")
+	code, stdout, stderr := smith("stacks", "resolve", "-w", path, "-name", "My ROR stack", "-component", "ruby:~2.2", "-os", "debian")
+	if code != exitOK {
+		t.Fatalf("smith stacks resolve exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "newest of 2 versions matching ~2.2") {
+		t.Errorf("smith stacks resolve printed %s", stdout)
+	}
+	data, _ := ioutil.ReadFile(path)
+	var def stacksmith.StackDefinition
+	json.Unmarshal(data, &def)
+	if def.OS.Version != "wheezy" || len(def.Components) != 1 || def.Components[0].Version != "2.2.5" {
+		t.Errorf("smith stacks resolve wrote %s", data)
+	}
+	if code, _, _ := smith("stacks", "resolve", "-strategy", "oldest", "-os", "debian"); code != exitUsage {
+		t.Errorf("smith stacks resolve -strategy oldest exited with %d", code)
+	}
+	if code, _, _ := smith("stacks", "resolve", "-strategy", "prefer-safe", "-os", "debian"); code != exitUsage {
+		t.Errorf("smith stacks resolve -strategy prefer-safe without -vulns exited with %d", code)
+	}
+}
+
+func TestRun_StacksChangelog(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+	mux.HandleFunc("/api/v1/components/debian/changelog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":[
+			{"version":"wheezy","revision":9,"branch":"stable","release_notes":"Debian 7.11"}]}`))
+	})
+
+	code, stdout, stderr := smith("stacks", "changelog", "bzr9nhz")
+	if code != exitOK {
+		t.Fatalf("smith stacks changelog exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "#### Debian (debian) wheezy-7 -> wheezy-9\n\n- **wheezy-9**\n\n  Debian 7.11\n") {
+		t.Errorf("smith stacks changelog printed %s", stdout)
+	}
+	if code, _, _ := smith("stacks", "changelog", "-format", "html", "bzr9nhz"); code != exitUsage {
+		t.Errorf("smith stacks changelog -format html exited with %d", code)
+	}
+}
+
+func TestRun_StacksAutoregen(t *testing.T) {
+	setup()
+	defer teardown()
+
+	regenerated := 0
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "POST" {
+			regenerated++
+			w.Write(utils.GetJSON("stack_response"))
+			return
+		}
+		w.Write([]byte(`{"total_entries":2,"total_pages":1,"items":[
+			{"id":"bzr9nhz","name":"My ROR stack2","outdated":true},
+			{"id":"s2","name":"sandbox","outdated":true}]}`))
+	})
+
+	code, stdout, stderr := smith("stacks", "autoregen", "-dry-run", "-exclude", "sand*", "-o", "json")
+	if code != exitOK {
+		t.Fatalf("smith stacks autoregen exited with %d: %s", code, stderr)
+	}
+	if regenerated != 0 || !strings.Contains(stdout, `"action": "would regenerate"`) || !strings.Contains(stdout, `"detail": "excluded by \"sand*\""`) {
+		t.Errorf("smith stacks autoregen -dry-run regenerated %d stacks and printed %s", regenerated, stdout)
+	}
+
+	code, stdout, _ = smith("stacks", "autoregen", "-columns", "stack_id,action,status.id")
+	if code != exitOK || regenerated != 2 || !strings.Contains(stdout, "sod64ck") {
+		t.Errorf("smith stacks autoregen exited with %d, regenerated %d stacks and printed %s", code, regenerated, stdout)
+	}
+	if code, _, _ := smith("stacks", "autoregen", "-quiet-hours", "22:00"); code != exitUsage {
+		t.Errorf("smith stacks autoregen with invalid quiet hours exited with %d", code)
+	}
+}
+
+func TestRun_StacksBulk(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var deleted []string
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/stacks/")
+		switch {
+		case id == "":
+			w.Write([]byte(`{"total_entries":3,"total_pages":1,"items":[
+				{"id":"s1","name":"web-staging"},
+				{"id":"s2","name":"db-staging"},
+				{"id":"s3","name":"web-prod"}]}`))
+		case id == "s2":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":"404","error":"Stack not found"}`))
+		default:
+			deleted = append(deleted, id)
+			w.Write([]byte(`{"id":"` + id + `","deleted":true}`))
+		}
+	})
+	defer func(r io.Reader) { stdin = r }(stdin)
+
+	stdin = strings.NewReader("n\n")
+	code, stdout, stderr := smith("stacks", "bulk", "-name", "*-staging", "delete")
+	if code != exitFailure || len(deleted) != 0 || !strings.Contains(stderr, "delete 2 stacks: s1, s2? [y/N]") || !strings.Contains(stdout, "delete not confirmed") || !strings.Contains(stderr, "smith: bulk: operation not confirmed") {
+		t.Errorf("smith stacks bulk delete answered no exited with %d, deleted %v and printed %s%s", code, deleted, stdout, stderr)
+	}
+
+	stdin = strings.NewReader("y\n")
+	code, stdout, stderr = smith("stacks", "bulk", "-name", "*-staging", "-o", "json", "delete")
+	if code != exitFailure || strings.Join(deleted, ",") != "s1" {
+		t.Errorf("smith stacks bulk delete exited with %d and deleted %v: %s", code, deleted, stderr)
+	}
+	if !strings.Contains(stdout, `"status": "succeeded"`) || !strings.Contains(stdout, `"status": "failed"`) {
+		t.Errorf("smith stacks bulk delete printed %s", stdout)
+	}
+
+	if code, _, stderr := smith("stacks", "bulk", "-yes", "delete", "s3"); code != exitOK || strings.Join(deleted, ",") != "s1,s3" || stderr != "" {
+		t.Errorf("smith stacks bulk -yes delete exited with %d and deleted %v: %s", code, deleted, stderr)
+	}
+	for _, args := range [][]string{{"explode", "s1"}, {"delete"}, {"-all", "delete", "s1"}, {"-name", "[", "delete"}} {
+		if code, _, _ := smith(append([]string{"stacks", "bulk"}, args...)...); code != exitUsage {
+			t.Errorf("smith stacks bulk %v exited with %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/JesusTinoco/go-smith/stacksmith/policy"
+	"github.com/JesusTinoco/go-smith/stacksmith/render"
+)
+
+func init() {
+	render.RegisterColumns(policy.Verdicts{}, "stack_id", "pass", "violations[*].reason")
+}
+
+var policyCommands = []command{
+	{
+		name: "check",
+		args: "STACK [STACK...]",
+		help: "Check stacks against a vulnerability policy, exiting with 7 on violations.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			file := fs.String("f", "", "read the policy from `file`, YAML or JSON")
+			maxSeverity := fs.String("max-severity", "", "most severe vulnerability allowed, overriding the policy file")
+			maxOutdatedDays := fs.Int("max-outdated-days", 0, "days a stack may stay outdated, overriding the policy file")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				p := new(policy.Policy)
+				if *file != "" {
+					var err error
+					if p, err = policy.Load(*file); err != nil {
+						return nil, nil, err
+					}
+				}
+				fs.Visit(func(f *flag.Flag) {
+					switch f.Name {
+					case "max-severity":
+						p.MaxSeverity = *maxSeverity
+					case "max-outdated-days":
+						p.MaxOutdatedDays = *maxOutdatedDays
+					}
+				})
+				if err := p.Validate(); err != nil {
+					return nil, nil, usageError(err.Error())
+				}
+
+				var verdicts policy.Verdicts
+				for _, stackID := range args {
+					verdict, resp, err := p.Check(e.client, stackID)
+					if err != nil {
+						return nil, resp, err
+					}
+					verdicts = append(verdicts, verdict)
+				}
+				return verdicts, nil, nil
+			}
+		},
+	},
+}
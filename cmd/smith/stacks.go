@@ -0,0 +1,712 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/bulk"
+	"github.com/JesusTinoco/go-smith/stacksmith/changelog"
+	"github.com/JesusTinoco/go-smith/stacksmith/dockerfile"
+	"github.com/JesusTinoco/go-smith/stacksmith/export"
+	"github.com/JesusTinoco/go-smith/stacksmith/junit"
+	"github.com/JesusTinoco/go-smith/stacksmith/outdated"
+	"github.com/JesusTinoco/go-smith/stacksmith/preflight"
+	"github.com/JesusTinoco/go-smith/stacksmith/regen"
+	"github.com/JesusTinoco/go-smith/stacksmith/render"
+	"github.com/JesusTinoco/go-smith/stacksmith/resolve"
+	"github.com/JesusTinoco/go-smith/stacksmith/sarif"
+	"github.com/JesusTinoco/go-smith/stacksmith/sbom"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+)
+
+func init() {
+	render.RegisterColumns([]resolve.Choice{}, "field", "id", "constraint", "version", "revision", "reason")
+	render.RegisterColumns([]regen.Action{}, "stack_id", "stack_name", "action", "reasons", "detail", "status.id")
+	render.RegisterColumns(bulk.Results{}, "stack_id", "status", "detail")
+}
+
+var stacksCommands = []command{
+	{
+		name: "list",
+		help: "List the stacks of the account.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if pag.Page == 0 {
+					return e.client.Stacks.ListAll()
+				}
+				return e.client.Stacks.List(pag)
+			}
+		},
+	},
+	{
+		name: "get",
+		args: "STACK",
+		help: "Show a stack.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Stacks.Get(args[0])
+		}),
+	},
+	{
+		name: "create",
+		help: "Create a stack from flags or from a StackDefinition JSON file.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			definition := definitionFlags(fs)
+			validate := fs.Bool("validate", false, "check the definition against Discovery first")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				def, err := definition()
+				if err != nil {
+					return nil, nil, err
+				}
+				if def.Name == "" || def.OS.ID == "" {
+					return nil, nil, usageError("create needs a name and an OS")
+				}
+				if *validate {
+					if err := preflight.New(e.client.Discovery).Validate(context.Background(), def); err != nil {
+						return nil, nil, err
+					}
+				}
+				return e.client.Stacks.Create(def)
+			}
+		},
+	},
+	{
+		name: "resolve",
+		help: "Pick exact versions for a stack definition whose versions are constraints like ~7.0 or 5.7.x.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			definition := definitionFlags(fs)
+			opts := new(resolve.Options)
+			strategy := fs.String("strategy", string(resolve.Newest), "`strategy` picking versions: newest or prefer-safe")
+			fs.StringVar(&opts.Branch, "branch", "", "only pick versions of `branch`")
+			vulnerabilities := fs.String("vulns", "", "avoid the vulnerabilities of `stack` with -strategy prefer-safe")
+			out := fs.String("w", "", "write the resolved StackDefinition to `file`")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				switch opts.Strategy = resolve.Strategy(*strategy); opts.Strategy {
+				case resolve.Newest:
+				case resolve.PreferSafe:
+					if *vulnerabilities == "" {
+						return nil, nil, usageError("-strategy prefer-safe needs -vulns")
+					}
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown strategy %q", *strategy))
+				}
+				def, err := definition()
+				if err != nil {
+					return nil, nil, err
+				}
+				if *vulnerabilities != "" {
+					items, resp, err := e.client.Stacks.GetAllVulnerabilities(*vulnerabilities)
+					if err != nil {
+						return nil, resp, err
+					}
+					// An empty list still tells prefer-safe that no
+					// vulnerability is known.
+					opts.Vulnerabilities = append([]stacksmith.VulnerabilityItem{}, items...)
+				}
+				res, err := resolve.New(e.client.Discovery, opts).Resolve(context.Background(), def)
+				if err != nil {
+					return nil, nil, err
+				}
+				if *out != "" {
+					data, err := json.MarshalIndent(res.Definition, "", "  ")
+					if err != nil {
+						return nil, nil, err
+					}
+					if err := ioutil.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+						return nil, nil, err
+					}
+				}
+				return res.Choices, nil, nil
+			}
+		},
+	},
+	{
+		name: "validate",
+		help: "Check a stack definition, from flags or from a StackDefinition JSON file, against Discovery.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			definition := definitionFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				def, err := definition()
+				if err != nil {
+					return nil, nil, err
+				}
+				return nil, nil, preflight.New(e.client.Discovery).Validate(context.Background(), def)
+			}
+		},
+	},
+	{
+		name: "update",
+		args: "STACK",
+		help: "Rename a stack or change its notifications and sharing.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			name := fs.String("name", "", "new stack name")
+			notifications := fs.Bool("notifications", false, "enable notifications")
+			shared := fs.Bool("shared", false, "share the stack")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				stack, resp, err := e.client.Stacks.Get(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				params := &stacksmith.StackParams{
+					Name:                 stack.Name,
+					NotificationsEnabled: stack.NotificationsEnabled,
+					Shared:               stack.Shared,
+				}
+				fs.Visit(func(f *flag.Flag) {
+					switch f.Name {
+					case "name":
+						params.Name = *name
+					case "notifications":
+						params.NotificationsEnabled = *notifications
+					case "shared":
+						params.Shared = *shared
+					}
+				})
+				return e.client.Stacks.Update(args[0], params)
+			}
+		},
+	},
+	{
+		name: "delete",
+		args: "STACK",
+		help: "Delete a stack.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Stacks.Delete(args[0])
+		}),
+	},
+	{
+		name: "regenerate",
+		args: "STACK",
+		help: "Regenerate a stack with the latest versions of its requirements.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.Stacks.Regenerate(args[0])
+		}),
+	},
+	{
+		name: "autoregen",
+		help: "Regenerate the outdated or vulnerable stacks, once or every -every until interrupted.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			config := new(regen.Config)
+			fs.BoolVar(&config.Outdated, "outdated", true, "regenerate the outdated stacks")
+			fs.StringVar(&config.MinSeverity, "min-severity", "", "regenerate the stacks vulnerable at this `severity` or above")
+			include := fs.String("include", "", "comma-separated name `patterns` of the stacks to regenerate")
+			exclude := fs.String("exclude", "", "comma-separated name `patterns` of the stacks left alone")
+			fs.IntVar(&config.MaxPerRun, "max", 0, "most regenerations per run, 0 for no limit")
+			quietHours := fs.String("quiet-hours", "", "daily `period` without regenerations, like 22:00-06:00")
+			fs.BoolVar(&config.DryRun, "dry-run", false, "report the regenerations without doing them")
+			every := fs.Duration("every", 0, "run every `interval` until interrupted")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if *include != "" {
+					config.Include = strings.Split(*include, ",")
+				}
+				if *exclude != "" {
+					config.Exclude = strings.Split(*exclude, ",")
+				}
+				if *quietHours != "" {
+					q, err := regen.ParseQuietHours(*quietHours)
+					if err != nil {
+						return nil, nil, usageError(err.Error())
+					}
+					config.QuietHours = q
+				}
+				bot, err := regen.New(e.client, config)
+				if err != nil {
+					return nil, nil, usageError(err.Error())
+				}
+				if *every <= 0 {
+					report, err := bot.Run(context.Background())
+					if err != nil {
+						return nil, nil, err
+					}
+					return report.Actions, nil, nil
+				}
+
+				ctx, stop := interruptible()
+				defer stop()
+				bot.Loop(ctx, *every, func(report *regen.Report, err error) {
+					if err != nil {
+						fmt.Fprintf(e.stderr, "smith: %v\n", err)
+						return
+					}
+					regen.WriteText(e.stdout, report)
+				})
+				return nil, nil, nil
+			}
+		},
+	},
+	{
+		name: "bulk",
+		args: "delete|regenerate|notify|mute|share|unshare [STACK...]",
+		help: "Run an operation on the given stacks, or on those selected by -all, -name or -outdated, and report the outcome for each.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			all := fs.Bool("all", false, "select every stack")
+			names := fs.String("name", "", "select the stacks whose name matches one of the comma-separated `patterns`")
+			outdatedOnly := fs.Bool("outdated", false, "select the outdated stacks")
+			opts := new(bulk.Options)
+			fs.IntVar(&opts.Concurrency, "concurrency", bulk.DefaultConcurrency, "stacks handled at a time")
+			yes := fs.Bool("yes", false, "delete without asking for confirmation")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				op, ok := bulkOperations[args[0]]
+				if !ok {
+					return nil, nil, usageError(fmt.Sprintf("unknown operation %q", args[0]))
+				}
+				filtered := *all || *names != "" || *outdatedOnly
+				if filtered == (len(args) > 1) {
+					return nil, nil, usageError("give either stacks or -all, -name or -outdated")
+				}
+				var patterns []string
+				if *names != "" {
+					patterns = strings.Split(*names, ",")
+					for _, pattern := range patterns {
+						if _, err := path.Match(pattern, ""); err != nil {
+							return nil, nil, usageError(fmt.Sprintf("invalid pattern %q", pattern))
+						}
+					}
+				}
+
+				stackIDs := args[1:]
+				if filtered {
+					var resp *http.Response
+					var err error
+					stackIDs, resp, err = bulk.Select(e.client, func(item stacksmith.StackItem) bool {
+						if *outdatedOnly && !item.Outdated {
+							return false
+						}
+						for _, pattern := range patterns {
+							if ok, _ := path.Match(pattern, item.Name); ok {
+								return true
+							}
+						}
+						return len(patterns) == 0
+					})
+					if err != nil {
+						return nil, resp, err
+					}
+				}
+
+				opts.Confirm = func(op bulk.Operation, stackIDs []string) bool {
+					if *yes {
+						return true
+					}
+					fmt.Fprintf(e.stderr, "%s %d stacks: %s? [y/N] ", op.Name, len(stackIDs), strings.Join(stackIDs, ", "))
+					answer, _ := bufio.NewReader(stdin).ReadString('\n')
+					answer = strings.ToLower(strings.TrimSpace(answer))
+					return answer == "y" || answer == "yes"
+				}
+				ctx, stop := interruptible()
+				defer stop()
+				results, err := bulk.Run(ctx, e.client, op, stackIDs, opts)
+				return results, nil, err
+			}
+		},
+	},
+	{
+		name: "vulns",
+		args: "STACK",
+		help: "List the vulnerabilities affecting a stack.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if pag.Page == 0 {
+					return e.client.Stacks.GetAllVulnerabilities(args[0])
+				}
+				return e.client.Stacks.GetVulnerabilities(args[0], pag)
+			}
+		},
+	},
+	{
+		name: "outdated",
+		help: "Report the outdated components of every stack as CSV or JSON.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			format := fs.String("format", "csv", "`format` of the report: csv or json")
+			opts := new(outdated.Options)
+			fs.IntVar(&opts.Concurrency, "concurrency", outdated.DefaultConcurrency, "requests in flight")
+			fs.BoolVar(&opts.SkipChangelog, "skip-changelog", false, "do not read changelogs to count the releases behind")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				write := outdated.WriteCSV
+				switch *format {
+				case "csv":
+				case "json":
+					write = outdated.WriteJSON
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown report format %q", *format))
+				}
+				report, err := outdated.Build(context.Background(), e.client, opts)
+				if err != nil {
+					return nil, nil, err
+				}
+				for _, stackID := range errorKeys(report.Errors) {
+					fmt.Fprintf(e.stderr, "smith: stack %s: %v\n", stackID, report.Errors[stackID])
+				}
+				return nil, nil, write(e.stdout, report)
+			}
+		},
+	},
+	{
+		name: "changelog",
+		args: "STACK",
+		help: "Show what changed between the outdated components of a stack and their latest versions.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			format := fs.String("format", changelog.Markdown, "`format` of the changelog: markdown or json")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				switch *format {
+				case changelog.Markdown, changelog.JSON:
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown changelog format %q", *format))
+				}
+				stack, resp, err := e.client.Stacks.Get(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				cl, err := changelog.Build(context.Background(), e.client.Discovery, stack)
+				if err != nil {
+					return nil, resp, err
+				}
+				for _, id := range errorKeys(cl.Errors) {
+					fmt.Fprintf(e.stderr, "smith: changelog of %s: %v\n", id, cl.Errors[id])
+				}
+				return nil, resp, changelog.Write(e.stdout, cl, *format)
+			}
+		},
+	},
+	{
+		name: "vulns-diff",
+		args: "OLD [NEW]",
+		help: "Show the vulnerabilities fixed and introduced between two stacks, or since a stack saved with get -o json.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			format := fs.String("format", vulns.Text, "`format` of the diff: text, json or markdown")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				switch *format {
+				case vulns.Text, vulns.JSON, vulns.Markdown:
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown diff format %q", *format))
+				}
+				if len(args) == 1 {
+					if _, err := os.Stat(args[0]); err != nil {
+						return nil, nil, usageError("give NEW, or a stack saved with get -o json as OLD")
+					}
+				}
+				oldStack, oldVulnerabilities, resp, err := loadStack(e, args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				newID := oldStack.ID
+				if len(args) > 1 {
+					newID = args[1]
+				}
+				newStack, newVulnerabilities, resp, err := loadStack(e, newID)
+				if err != nil {
+					return nil, resp, err
+				}
+				d := vulns.Compare(oldStack, oldVulnerabilities, newStack, newVulnerabilities)
+				return nil, resp, vulns.Write(e.stdout, d, *format)
+			}
+		},
+	},
+	{
+		name: "sarif",
+		args: "STACK",
+		help: "Print the vulnerabilities of a stack as a SARIF 2.1.0 log.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			path := fs.String("dockerfile", dockerfile.FileName, "`path` of the stack Dockerfile in the repository, parsed when it exists")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				stack, resp, err := e.client.Stacks.Get(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				vulnerabilities, resp, err := e.client.Stacks.GetAllVulnerabilities(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				opts := &sarif.Options{DockerfilePath: filepath.ToSlash(*path)}
+				if f, err := os.Open(*path); err == nil {
+					opts.Dockerfile, err = dockerfile.Parse(f)
+					f.Close()
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				return nil, resp, sarif.Write(e.stdout, sarif.New(stack, vulnerabilities, opts))
+			}
+		},
+	},
+	{
+		name: "junit",
+		args: "STACK [STACK...]",
+		help: "Print a JUnit XML report failing the outdated and vulnerable components of stacks.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			opts := new(junit.Options)
+			fs.StringVar(&opts.MinSeverity, "min-severity", "", "least severe vulnerability failing a component (default any)")
+			fs.BoolVar(&opts.IgnoreOutdated, "ignore-outdated", false, "do not fail outdated components")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if err := opts.Validate(); err != nil {
+					return nil, nil, usageError(err.Error())
+				}
+				var suites []junit.Suite
+				for _, stackID := range args {
+					stack, resp, err := e.client.Stacks.Get(stackID)
+					if err != nil {
+						return nil, resp, err
+					}
+					vulnerabilities, resp, err := e.client.Stacks.GetAllVulnerabilities(stackID)
+					if err != nil {
+						return nil, resp, err
+					}
+					suites = append(suites, junit.NewSuite(stack, vulnerabilities, opts))
+				}
+				return nil, nil, junit.Write(e.stdout, junit.New(suites...))
+			}
+		},
+	},
+	{
+		name: "sbom",
+		args: "STACK",
+		help: "Print the bill of materials of a stack as CycloneDX or SPDX JSON.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			format := fs.String("format", "cyclonedx", "`format` of the document: cyclonedx or spdx")
+			deps := fs.Bool("deps", true, "list the dependencies between components")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				write := sbom.CycloneDX
+				switch *format {
+				case "cyclonedx":
+				case "spdx":
+					write = sbom.SPDX
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown sbom format %q", *format))
+				}
+				stack, resp, err := e.client.Stacks.Get(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				opts := new(sbom.Options)
+				if *deps {
+					opts.Dependencies, err = sbom.Dependencies(context.Background(), e.client.Discovery, stack)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				data, err := write(stack, opts)
+				if err != nil {
+					return nil, resp, err
+				}
+				_, err = e.stdout.Write(data)
+				return nil, resp, err
+			}
+		},
+	},
+	{
+		name: "export",
+		args: "STACK",
+		help: "Print a docker-compose.yml or Kubernetes manifests running a stack.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			format := fs.String("format", "compose", "`format` of the manifests: compose or kubernetes")
+			opts := new(export.Options)
+			fs.StringVar(&opts.Name, "name", "", "application name (default the stack name)")
+			fs.StringVar(&opts.Image, "image", "", "application image used by Kubernetes (default NAME:latest)")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				write := export.Compose
+				switch *format {
+				case "compose":
+				case "kubernetes":
+					write = export.Kubernetes
+				default:
+					return nil, nil, usageError(fmt.Sprintf("unknown export format %q", *format))
+				}
+				stack, resp, err := e.client.Stacks.Get(args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				data, err := write(stack, opts)
+				if err != nil {
+					return nil, resp, err
+				}
+				_, err = e.stdout.Write(data)
+				return nil, resp, err
+			}
+		},
+	},
+	{
+		name: "dockerfile",
+		args: "STACK",
+		help: "Print the Dockerfile of a stack, or save it into a directory.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			dir := fs.String("d", "", "save the Dockerfile into `dir`, with a header recording its origin")
+			opts := new(dockerfile.Options)
+			fs.StringVar(&opts.FileName, "name", dockerfile.FileName, "file name used with -d")
+			fs.BoolVar(&opts.Force, "force", false, "overwrite a Dockerfile edited locally")
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				if *dir != "" {
+					path, err := dockerfile.Save(context.Background(), e.client, args[0], *dir, opts)
+					if err != nil {
+						return nil, nil, err
+					}
+					fmt.Fprintln(e.stdout, path)
+					return nil, nil, nil
+				}
+				contents, resp, err := e.client.Stacks.Dockerfile(context.Background(), args[0])
+				if err != nil {
+					return nil, resp, err
+				}
+				_, err = e.stdout.Write(contents)
+				return nil, resp, err
+			}
+		},
+	},
+}
+
+// bulkOperations are the operations of the bulk command.
+var bulkOperations = map[string]bulk.Operation{
+	"delete":     bulk.Delete,
+	"regenerate": bulk.Regenerate,
+	"notify":     bulk.SetNotifications(true),
+	"mute":       bulk.SetNotifications(false),
+	"share":      bulk.SetShared(true),
+	"unshare":    bulk.SetShared(false),
+}
+
+// stdin answers the confirmations asked by commands.
+var stdin io.Reader = os.Stdin
+
+// interruptible returns a context canceled on SIGINT or SIGTERM, and the
+// function releasing it.
+func interruptible() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+// loadStack reads the stack saved in the JSON file at arg, or else fetches
+// the stack of ID arg with its vulnerabilities.
+func loadStack(e *env, arg string) (*stacksmith.Stack, []stacksmith.VulnerabilityItem, *http.Response, error) {
+	if data, err := ioutil.ReadFile(arg); err == nil {
+		stack := new(stacksmith.Stack)
+		if err := json.Unmarshal(data, stack); err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %v", arg, err)
+		}
+		return stack, nil, nil, nil
+	}
+	stack, resp, err := e.client.Stacks.Get(arg)
+	if err != nil {
+		return nil, nil, resp, err
+	}
+	vulnerabilities, resp, err := e.client.Stacks.GetAllVulnerabilities(arg)
+	return stack, vulnerabilities, resp, err
+}
+
+// errorKeys returns the sorted keys of errs.
+func errorKeys(errs map[string]error) []string {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// noFlags adapts a command without flags.
+func noFlags(fn runFunc) func(*flag.FlagSet) runFunc {
+	return func(*flag.FlagSet) runFunc {
+		return fn
+	}
+}
+
+// paginationFlags adds -page and -per-page. A zero page means every page,
+// for the commands able to follow them.
+func paginationFlags(fs *flag.FlagSet) *stacksmith.PaginationParams {
+	pag := new(stacksmith.PaginationParams)
+	fs.IntVar(&pag.Page, "page", 0, "page to show (default all pages when supported, else 1)")
+	fs.IntVar(&pag.PerPage, "per-page", 100, "entries per page")
+	return pag
+}
+
+func firstPage(pag *stacksmith.PaginationParams) *stacksmith.PaginationParams {
+	if pag.Page == 0 {
+		return &stacksmith.PaginationParams{Page: 1, PerPage: pag.PerPage}
+	}
+	return pag
+}
+
+// componentsFlag collects repeated -component flags.
+type componentsFlag []stacksmith.ComponentItem
+
+func (c *componentsFlag) String() string {
+	var items []string
+	for _, item := range *c {
+		items = append(items, item.ID+":"+item.Version)
+	}
+	return strings.Join(items, ",")
+}
+
+func (c *componentsFlag) Set(value string) error {
+	*c = append(*c, parseComponent(value))
+	return nil
+}
+
+// definitionFlags defines the flags describing a StackDefinition and
+// returns the function building it.
+func definitionFlags(fs *flag.FlagSet) func() (*stacksmith.StackDefinition, error) {
+	file := fs.String("f", "", "read the StackDefinition from `file`")
+	name := fs.String("name", "", "stack name")
+	var components componentsFlag
+	fs.Var(&components, "component", "component as `id[:version]`, repeatable")
+	osFlag := fs.String("os", "", "OS as `id[:version]`")
+	flavor := fs.String("flavor", "", "flavor ID")
+	return func() (*stacksmith.StackDefinition, error) {
+		def := new(stacksmith.StackDefinition)
+		if *file != "" {
+			data, err := ioutil.ReadFile(*file)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(data, def); err != nil {
+				return nil, fmt.Errorf("%s: %v", *file, err)
+			}
+		}
+		if *name != "" {
+			def.Name = *name
+		}
+		def.Components = append(def.Components, components...)
+		if *osFlag != "" {
+			def.OS = parseComponent(*osFlag)
+		}
+		if *flavor != "" {
+			def.Flavor = *flavor
+		}
+		return def, nil
+	}
+}
+
+// parseComponent reads "id[:version]", the version defaulting to latest.
+func parseComponent(value string) stacksmith.ComponentItem {
+	parts := strings.SplitN(value, ":", 2)
+	item := stacksmith.ComponentItem{ID: parts[0], Version: "latest"}
+	if len(parts) == 2 && parts[1] != "" {
+		item.Version = parts[1]
+	}
+	return item
+}
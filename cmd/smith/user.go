@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+var userCommands = []command{
+	{
+		name: "notifications",
+		args: "on|off",
+		help: "Enable or disable the email notifications.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			var enabled bool
+			switch args[0] {
+			case "on":
+				enabled = true
+			case "off":
+				enabled = false
+			default:
+				return nil, nil, usageError("notifications takes on or off")
+			}
+			return e.client.User.UpdateNotifications(&stacksmith.EmailNotifications{EmailNotificationsEnabled: enabled})
+		}),
+	},
+	{
+		name: "slack-channels",
+		help: "List the Slack channels with an integration.",
+		flags: func(fs *flag.FlagSet) runFunc {
+			pag := paginationFlags(fs)
+			return func(e *env, args []string) (interface{}, *http.Response, error) {
+				return e.client.User.ListSlackChannels(firstPage(pag))
+			}
+		},
+	},
+	{
+		name: "slack-remove",
+		args: "CHANNEL",
+		help: "Remove a Slack channel integration.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.User.RemoveSlackChannel(args[0])
+		}),
+	},
+	{
+		name: "slack-test",
+		args: "CHANNEL",
+		help: "Send a test notification to a Slack channel.",
+		flags: noFlags(func(e *env, args []string) (interface{}, *http.Response, error) {
+			return e.client.User.TestSlackIntegration(args[0])
+		}),
+	},
+}
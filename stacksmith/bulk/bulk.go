@@ -0,0 +1,266 @@
+// Package bulk runs an operation, like a deletion or a regeneration, on many
+// stacks at once, a few at a time, and reports the outcome for each stack
+// instead of stopping at the first error.
+//
+// Requests go through the client as usual, so that the rate limit and
+// retries of its transport apply to them; see stacksmith.RateLimitTransport.
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/internal/parallel"
+)
+
+// DefaultConcurrency is the number of stacks handled at a time by default.
+const DefaultConcurrency = 4
+
+// Operation is an action on one stack.
+type Operation struct {
+	Name string
+	// Destructive operations run only once Options.Confirm approves them.
+	Destructive bool
+	// Do runs the operation on a stack, aborting its requests once ctx is
+	// done. It returns a Skip error when the stack needs nothing done.
+	Do func(ctx context.Context, client *stacksmith.Client, stackID string) (interface{}, *http.Response, error)
+}
+
+// ErrNotConfirmed is returned by Run when Options.Confirm does not approve
+// a destructive operation.
+var ErrNotConfirmed = errors.New("bulk: operation not confirmed")
+
+// Skip is the error returned by Operation.Do for stacks left alone, telling
+// why.
+type Skip string
+
+func (s Skip) Error() string {
+	return string(s)
+}
+
+// Delete deletes stacks.
+var Delete = Operation{
+	Name:        "delete",
+	Destructive: true,
+	Do: func(ctx context.Context, client *stacksmith.Client, stackID string) (interface{}, *http.Response, error) {
+		return client.Stacks.DeleteContext(ctx, stackID)
+	},
+}
+
+// Regenerate regenerates stacks with the latest versions of their
+// requirements.
+var Regenerate = Operation{
+	Name: "regenerate",
+	Do: func(ctx context.Context, client *stacksmith.Client, stackID string) (interface{}, *http.Response, error) {
+		return client.Stacks.RegenerateContext(ctx, stackID)
+	},
+}
+
+// SetNotifications enables or disables the notifications of stacks, skipping
+// those already set.
+func SetNotifications(enabled bool) Operation {
+	name := "disable notifications"
+	if enabled {
+		name = "enable notifications"
+	}
+	return update(name, func(params *stacksmith.StackParams) bool {
+		changed := params.NotificationsEnabled != enabled
+		params.NotificationsEnabled = enabled
+		return changed
+	})
+}
+
+// SetShared shares or unshares stacks, skipping those already set.
+func SetShared(shared bool) Operation {
+	name := "unshare"
+	if shared {
+		name = "share"
+	}
+	return update(name, func(params *stacksmith.StackParams) bool {
+		changed := params.Shared != shared
+		params.Shared = shared
+		return changed
+	})
+}
+
+// update returns the operation reading a stack, changing its parameters with
+// change and updating the stack when change tells they changed.
+func update(name string, change func(*stacksmith.StackParams) bool) Operation {
+	return Operation{
+		Name: name,
+		Do: func(ctx context.Context, client *stacksmith.Client, stackID string) (interface{}, *http.Response, error) {
+			stack, resp, err := client.Stacks.GetContext(ctx, stackID)
+			if err != nil {
+				return nil, resp, err
+			}
+			params := &stacksmith.StackParams{
+				Name:                 stack.Name,
+				NotificationsEnabled: stack.NotificationsEnabled,
+				Shared:               stack.Shared,
+			}
+			if !change(params) {
+				return nil, resp, Skip("nothing to " + name)
+			}
+			return client.Stacks.UpdateContext(ctx, stackID, params)
+		},
+	}
+}
+
+// Options tunes Run.
+type Options struct {
+	// Concurrency defaults to DefaultConcurrency.
+	Concurrency int
+	// Confirm is asked before running a destructive operation with the
+	// stacks it would run on. When it returns false, or is nil, every stack
+	// is skipped.
+	Confirm func(op Operation, stackIDs []string) bool
+}
+
+// Statuses of a Result.
+const (
+	Succeeded = "succeeded"
+	Skipped   = "skipped"
+	Failed    = "failed"
+)
+
+// Result is the outcome of an operation on a stack.
+type Result struct {
+	StackID string `json:"stack_id"`
+	Status  string `json:"status"`
+	// Detail tells why the stack was skipped or the operation failed.
+	Detail string `json:"detail,omitempty"`
+	// Value is what the operation returned, like the
+	// *stacksmith.StatusGeneration of a regeneration.
+	Value interface{} `json:"value,omitempty"`
+	// Err is the error of a failed or skipped operation, like a
+	// stacksmith.APIError, a Skip or the error of the context.
+	Err error `json:"-"`
+	// Response is the last HTTP response of the operation, if any.
+	Response *http.Response `json:"-"`
+}
+
+// Results are the outcomes of an operation, in the order of the stacks.
+type Results []Result
+
+// Count returns the number of results with status.
+func (r Results) Count(status string) int {
+	n := 0
+	for _, result := range r {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns the failed results as Errors, or nil when none failed.
+func (r Results) Err() error {
+	var errs Errors
+	for _, result := range r {
+		if result.Status == Failed {
+			errs = append(errs, result)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Errors is the error aggregating the failed results of a run.
+type Errors []Result
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, result := range e {
+		messages[i] = result.StackID + ": " + result.Err.Error()
+	}
+	if len(e) == 1 {
+		return "bulk: " + messages[0]
+	}
+	return fmt.Sprintf("bulk: %d stacks failed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Select returns the IDs of the stacks of the account that filter accepts.
+func Select(client *stacksmith.Client, filter func(stacksmith.StackItem) bool) ([]string, *http.Response, error) {
+	items, resp, err := client.Stacks.ListAll()
+	if err != nil {
+		return nil, resp, err
+	}
+	ids := []string{}
+	for _, item := range items {
+		if filter(item) {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids, resp, nil
+}
+
+// Run runs op on each of stackIDs, at most Options.Concurrency at a time,
+// and returns one result per stack, duplicates left out. Failures do not
+// stop the run; Results.Err aggregates them. Once ctx is done no operation
+// starts, the stacks left are skipped with the error of ctx, and Run returns
+// that error along with the results. A destructive operation that is not
+// confirmed skips every stack and returns ErrNotConfirmed.
+func Run(ctx context.Context, client *stacksmith.Client, op Operation, stackIDs []string, opts *Options) (Results, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+
+	seen := make(map[string]bool)
+	results := Results{}
+	for _, id := range stackIDs {
+		if !seen[id] {
+			seen[id] = true
+			results = append(results, Result{StackID: id})
+		}
+	}
+	if len(results) == 0 {
+		return results, ctx.Err()
+	}
+
+	if op.Destructive {
+		ids := make([]string, len(results))
+		for i, result := range results {
+			ids[i] = result.StackID
+		}
+		if o.Confirm == nil || !o.Confirm(op, ids) {
+			for i := range results {
+				results[i].Status, results[i].Err = Skipped, Skip(op.Name+" not confirmed")
+				results[i].Detail = results[i].Err.Error()
+			}
+			return results, ErrNotConfirmed
+		}
+	}
+
+	err := parallel.ForEach(ctx, len(results), o.Concurrency, func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		r := &results[i]
+		r.Value, r.Response, r.Err = op.Do(ctx, client, r.StackID)
+		switch err := r.Err.(type) {
+		case nil:
+			r.Status = Succeeded
+		case Skip:
+			r.Status, r.Value, r.Detail = Skipped, nil, err.Error()
+		default:
+			r.Status, r.Value, r.Detail = Failed, nil, err.Error()
+		}
+	})
+	for i := range results {
+		if results[i].Status == "" {
+			results[i].Status, results[i].Err = Skipped, err
+			results[i].Detail = "not started: " + err.Error()
+		}
+	}
+	return results, err
+}
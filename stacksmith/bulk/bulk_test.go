@@ -0,0 +1,216 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testServer serves three stacks: s1 with notifications enabled, s2 shared
+// and s3 neither. Requests on s4 fail and s5 does not exist. The requests
+// changing a stack are recorded as "METHOD id".
+func testServer() (*stacksmith.Client, func() []string, func()) {
+	var mu sync.Mutex
+	var calls []string
+	stacks := map[string]string{
+		"s1": `{"id":"s1","name":"web","notifications_enabled":true,"shared":false}`,
+		"s2": `{"id":"s2","name":"db","notifications_enabled":false,"shared":true}`,
+		"s3": `{"id":"s3","name":"sandbox","notifications_enabled":false,"shared":false}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/stacks/"), "/regenerate")
+		if id == "" {
+			fmt.Fprint(w, `{"total_entries":3,"total_pages":1,"items":[`+stacks["s1"]+`,`+stacks["s2"]+`,`+stacks["s3"]+`]}`)
+			return
+		}
+		if r.Method != "GET" {
+			mu.Lock()
+			calls = append(calls, r.Method+" "+id)
+			mu.Unlock()
+		}
+		switch {
+		case id == "s4":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"status":"500","error":"Internal error"}`)
+		case stacks[id] == "":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status":"404","error":"Stack not found"}`)
+		case r.Method == "GET":
+			fmt.Fprint(w, stacks[id])
+		case r.Method == "DELETE":
+			fmt.Fprint(w, `{"id":"`+id+`","deleted":true}`)
+		default:
+			fmt.Fprintf(w, `{"id":"new-%s","stack_url":"https://stacksmith.bitnami.com/api/v1/stacks/new-%s"}`, id, id)
+		}
+	})
+	server := httptest.NewServer(mux)
+	recorded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sorted := append([]string{}, calls...)
+		sort.Strings(sorted)
+		return sorted
+	}
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), recorded, server.Close
+}
+
+func statuses(results Results) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.StackID+" "+r.Status)
+	}
+	return s
+}
+
+func TestRun(t *testing.T) {
+	client, calls, teardown := testServer()
+	defer teardown()
+
+	results, err := Run(context.Background(), client, Regenerate, []string{"s1", "s4", "s2", "s1", "s5"}, &Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := []string{"s1 succeeded", "s4 failed", "s2 succeeded", "s5 failed"}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Run returned %v, want %v", got, want)
+	}
+	if status, ok := results[0].Value.(*stacksmith.StatusGeneration); !ok || status.ID != "new-s1" {
+		t.Errorf("Run returned value %#v for s1, want generation new-s1", results[0].Value)
+	}
+	if _, ok := results[1].Err.(stacksmith.APIError); !ok || results[1].Response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Run returned error %#v for s4, want an APIError with its response", results[1].Err)
+	}
+	if got := calls(); !reflect.DeepEqual(got, []string{"POST s1", "POST s2", "POST s4", "POST s5"}) {
+		t.Errorf("Run regenerated %v", got)
+	}
+
+	if n := results.Count(Failed); n != 2 {
+		t.Errorf("Count(Failed) = %d, want 2", n)
+	}
+	errs, ok := results.Err().(Errors)
+	if !ok || len(errs) != 2 || errs[0].StackID != "s4" || errs[1].StackID != "s5" {
+		t.Fatalf("Err returned %#v, want the errors of s4 and s5", results.Err())
+	}
+	if msg := errs.Error(); !strings.HasPrefix(msg, "bulk: 2 stacks failed: s4: ") || !strings.Contains(msg, "; s5: ") {
+		t.Errorf("Error returned %q", msg)
+	}
+	if err := results[:1].Err(); err != nil {
+		t.Errorf("Err returned %v with no failure", err)
+	}
+}
+
+func TestRun_update(t *testing.T) {
+	client, calls, teardown := testServer()
+	defer teardown()
+
+	results, _ := Run(context.Background(), client, SetNotifications(true), []string{"s1", "s2", "s3"}, nil)
+	if got, want := statuses(results), []string{"s1 skipped", "s2 succeeded", "s3 succeeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run(SetNotifications(true)) returned %v, want %v", got, want)
+	}
+	if results[0].Detail != "nothing to enable notifications" {
+		t.Errorf("Run skipped s1 for %q", results[0].Detail)
+	}
+
+	results, _ = Run(context.Background(), client, SetShared(false), []string{"s1", "s2", "s3"}, nil)
+	if got, want := statuses(results), []string{"s1 skipped", "s2 succeeded", "s3 skipped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run(SetShared(false)) returned %v, want %v", got, want)
+	}
+	if got := calls(); !reflect.DeepEqual(got, []string{"PATCH s2", "PATCH s2", "PATCH s3"}) {
+		t.Errorf("Run updated %v", got)
+	}
+}
+
+func TestRun_confirm(t *testing.T) {
+	client, calls, teardown := testServer()
+	defer teardown()
+
+	results, err := Run(context.Background(), client, Delete, []string{"s1", "s2"}, nil)
+	if err != ErrNotConfirmed {
+		t.Errorf("Run without confirmation returned error %v, want %v", err, ErrNotConfirmed)
+	}
+	if got, want := statuses(results), []string{"s1 skipped", "s2 skipped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run without confirmation returned %v, want %v", got, want)
+	}
+
+	var asked []string
+	confirm := func(answer bool) *Options {
+		return &Options{Confirm: func(op Operation, stackIDs []string) bool {
+			asked = append(asked, op.Name+" "+strings.Join(stackIDs, ","))
+			return answer
+		}}
+	}
+	Run(context.Background(), client, Delete, []string{"s1", "s2"}, confirm(false))
+	results, err = Run(context.Background(), client, Delete, []string{"s1", "s2"}, confirm(true))
+	if err != nil {
+		t.Errorf("Run with confirmation returned error: %v", err)
+	}
+	if got, want := statuses(results), []string{"s1 succeeded", "s2 succeeded"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run with confirmation returned %v, want %v", got, want)
+	}
+	if want := []string{"delete s1,s2", "delete s1,s2"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("Run asked %v, want %v", asked, want)
+	}
+	if got := calls(); !reflect.DeepEqual(got, []string{"DELETE s1", "DELETE s2"}) {
+		t.Errorf("Run deleted %v", got)
+	}
+
+	// Operations that are not destructive are not confirmed.
+	Run(context.Background(), client, Regenerate, []string{"s1"}, confirm(false))
+	if len(asked) != 2 {
+		t.Errorf("Run asked to confirm a regeneration")
+	}
+}
+
+func TestRun_canceled(t *testing.T) {
+	client, calls, teardown := testServer()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op := Operation{Name: "regenerate", Do: func(ctx context.Context, client *stacksmith.Client, stackID string) (interface{}, *http.Response, error) {
+		if stackID == "s2" {
+			cancel()
+		}
+		return Regenerate.Do(ctx, client, stackID)
+	}}
+	results, err := Run(ctx, client, op, []string{"s1", "s2", "s3"}, &Options{Concurrency: 1})
+	if err != context.Canceled {
+		t.Errorf("Run returned error %v, want %v", err, context.Canceled)
+	}
+	// The request of s2 is aborted and s3 never started.
+	if got, want := statuses(results), []string{"s1 succeeded", "s2 failed", "s3 skipped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run returned %v, want %v", got, want)
+	}
+	if !strings.Contains(results[1].Detail, context.Canceled.Error()) {
+		t.Errorf("Run failed s2 with %q, want the cancellation", results[1].Detail)
+	}
+	if results[2].Err != context.Canceled {
+		t.Errorf("Run skipped s3 with %v, want %v", results[2].Err, context.Canceled)
+	}
+	if got := calls(); !reflect.DeepEqual(got, []string{"POST s1"}) {
+		t.Errorf("Run regenerated %v", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	client, _, teardown := testServer()
+	defer teardown()
+
+	ids, _, err := Select(client, func(item stacksmith.StackItem) bool { return !item.NotificationsEnabled })
+	if err != nil {
+		t.Fatalf("Select returned error: %v", err)
+	}
+	if want := []string{"s2", "s3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Select returned %v, want %v", ids, want)
+	}
+}
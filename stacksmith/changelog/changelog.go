@@ -0,0 +1,131 @@
+// Package changelog combines the Discovery changelogs of the outdated
+// components of a stack, from the version in use to the latest, so that
+// reviewers can see what a regeneration would change.
+package changelog
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// PerPage is the page size used to read changelogs.
+const PerPage = 100
+
+// Changelog is the combined changelog of a stack.
+type Changelog struct {
+	StackID    string      `json:"stack_id"`
+	StackName  string      `json:"stack_name"`
+	Components []Component `json:"components"`
+	// Errors holds the components whose changelog could not be read.
+	Errors map[string]error `json:"-"`
+}
+
+// Component is the changelog of an outdated component.
+type Component struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Branch string `json:"branch,omitempty"`
+	// From and To are the version in use and the latest, with their
+	// revisions, like "2.2.3-3".
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is a release of a component, newest first.
+type Entry struct {
+	Version         string `json:"version"`
+	Revision        int    `json:"revision"`
+	Branch          string `json:"branch,omitempty"`
+	PublishedAt     string `json:"published_at,omitempty"`
+	ReleaseNotes    string `json:"release_notes,omitempty"`
+	ReleaseNotesURL string `json:"release_notes_url,omitempty"`
+}
+
+// FullVersion is the version with its revision, "2.2.3-3".
+func (e Entry) FullVersion() string {
+	return version.Format(e.Version, e.Revision)
+}
+
+// Build reads the changelog of every outdated component of stack, its OS
+// first, keeping the releases after the version in use up to the latest.
+// Components whose changelog cannot be read are left out and listed in
+// Changelog.Errors; the error returned is for ctx.
+func Build(ctx context.Context, discovery *stacksmith.DiscoveryService, stack *stacksmith.Stack) (*Changelog, error) {
+	cl := &Changelog{StackID: stack.ID, StackName: stack.Name, Components: []Component{}, Errors: make(map[string]error)}
+	components := append([]stacksmith.Component{}, stack.Components...)
+	sort.SliceStable(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+	for _, c := range append([]stacksmith.Component{stack.Os}, components...) {
+		if c.ID == "" || !c.Outdated {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		component, err := Read(discovery, c)
+		if err != nil {
+			cl.Errors[c.ID] = err
+			continue
+		}
+		cl.Components = append(cl.Components, *component)
+	}
+	return cl, nil
+}
+
+// Read returns the changelog of c from its version to its latest, following
+// every page. Releases of other branches are left out.
+func Read(discovery *stacksmith.DiscoveryService, c stacksmith.Component) (*Component, error) {
+	current, err := version.FromComponent(c)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := version.Latest(c)
+	if err != nil {
+		return nil, err
+	}
+	component := &Component{
+		ID:      c.ID,
+		Name:    c.Name,
+		Branch:  c.Branch,
+		From:    current.Full(),
+		To:      latest.Full(),
+		Entries: []Entry{},
+	}
+
+	rangeParams := &stacksmith.RangeParams{From: c.Version, To: c.Latest.Version}
+	for page := 1; ; page++ {
+		changelog, _, err := discovery.GetChangelogFrom(c.ID, rangeParams, &stacksmith.PaginationParams{Page: page, PerPage: PerPage})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range changelog.Items {
+			if c.Branch != "" && item.Branch != "" && item.Branch != c.Branch {
+				continue
+			}
+			v, err := version.New(item.Version, item.Revision)
+			if err != nil || !current.Less(v) || latest.Less(v) {
+				continue
+			}
+			component.Entries = append(component.Entries, Entry{
+				Version:         item.Version,
+				Revision:        item.Revision,
+				Branch:          item.Branch,
+				PublishedAt:     item.PublishedAt,
+				ReleaseNotes:    item.ReleaseNotes,
+				ReleaseNotesURL: item.ReleaseNotesURL,
+			})
+		}
+		if page >= changelog.TotalPAges || len(changelog.Items) == 0 {
+			break
+		}
+	}
+	sort.SliceStable(component.Entries, func(i, j int) bool {
+		a, _ := version.New(component.Entries[i].Version, component.Entries[i].Revision)
+		b, _ := version.New(component.Entries[j].Version, component.Entries[j].Revision)
+		return b.Less(a)
+	})
+	return component, nil
+}
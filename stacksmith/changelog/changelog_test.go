@@ -0,0 +1,128 @@
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// testStack returns the fixture stack with ruby 2.2.3-3 outdated by 2.3.1-0,
+// debian wheezy-7 by wheezy-9 and nodejs, whose changelog is unavailable.
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	ruby := &stack.Components[0]
+	ruby.Outdated = true
+	ruby.Latest.Version, ruby.Latest.Revision = "2.3.1", 0
+	stack.Components = append(stack.Components, stacksmith.Component{ID: "nodejs", Name: "Node.js", Version: "6.3.0", Outdated: true})
+	stack.Components[1].Latest.Version = "6.3.1"
+	return stack
+}
+
+// testServer serves the changelogs of ruby, on two pages, and debian. It
+// fails for the others.
+func testServer(t *testing.T) (*stacksmith.Client, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/components/ruby/changelog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("from") != "2.2.3" || r.FormValue("to") != "2.3.1" {
+			t.Errorf("ruby changelog requested from %q to %q", r.FormValue("from"), r.FormValue("to"))
+		}
+		if r.FormValue("page") == "1" {
+			fmt.Fprint(w, `{"total_entries":5,"total_pages":2,"items":[
+				{"version":"2.3.1","revision":0,"branch":"stable","published_at":"2016-08-03T10:00:00.000Z",
+				 "release_notes":"Ruby 2.3.1 fixes many bugs.\n\nSee the NEWS file for *details*.","release_notes_url":"https://www.ruby-lang.org/en/news/2016/04/26/ruby-2-3-1-released/"},
+				{"version":"2.3.0","revision":0,"branch":"stable","published_at":"2016-01-07T10:00:00.000Z","release_notes":"","release_notes_url":null},
+				{"version":"2.3.0","revision":0,"branch":"testing","published_at":"2015-12-25T10:00:00.000Z","release_notes":"preview"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"total_entries":5,"total_pages":2,"items":[
+			{"version":"2.2.5","revision":0,"branch":"stable","published_at":"2016-04-26T10:00:00.000Z","release_notes":"Security fixes for OpenSSL | CVE-2016-2107"},
+			{"version":"2.2.3","revision":3,"branch":"stable","published_at":"2016-03-01T10:00:00.000Z","release_notes":"Current"}]}`)
+	})
+	mux.HandleFunc("/api/v1/components/debian/changelog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_entries":3,"total_pages":1,"items":[
+			{"version":"wheezy","revision":9,"branch":"stable","published_at":"2016-09-01T10:00:00.000Z","release_notes":"Debian 7.11"},
+			{"version":"wheezy","revision":8,"branch":"stable","published_at":"2016-08-01T10:00:00.000Z","release_notes":"Debian 7.10"},
+			{"version":"wheezy","revision":7,"branch":"stable","published_at":"2016-06-01T10:00:00.000Z","release_notes":"Debian 7.9"}]}`)
+	})
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"status":"500","error":"Internal error"}`)
+	})
+	server := httptest.NewServer(mux)
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), server.Close
+}
+
+func TestBuild(t *testing.T) {
+	client, closeServer := testServer(t)
+	defer closeServer()
+
+	cl, err := Build(context.Background(), client.Discovery, testStack(t))
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+	var got []string
+	for _, c := range cl.Components {
+		for _, e := range c.Entries {
+			got = append(got, c.ID+" "+e.FullVersion())
+		}
+	}
+	expected := []string{"debian wheezy-9", "debian wheezy-8", "ruby 2.3.1-0", "ruby 2.3.0-0", "ruby 2.2.5-0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Build returned %q, want %q", got, expected)
+	}
+	if len(cl.Errors) != 1 || cl.Errors["nodejs"] == nil {
+		t.Errorf("Build returned errors %v", cl.Errors)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Build(ctx, client.Discovery, testStack(t)); err != context.Canceled {
+		t.Errorf("Build with a canceled context returned %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	client, closeServer := testServer(t)
+	defer closeServer()
+	cl, _ := Build(context.Background(), client.Discovery, testStack(t))
+
+	for format, golden := range map[string]string{Markdown: "changelog.md", JSON: "changelog.json"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, cl, format); err != nil {
+			t.Fatalf("Write(%s) returned error: %v", format, err)
+		}
+		path := filepath.Join("testdata", golden)
+		if *update {
+			ioutil.WriteFile(path, buf.Bytes(), 0644)
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(want) {
+			t.Errorf("Write(%s) wrote:\n%s", format, buf.String())
+		}
+	}
+	if err := Write(ioutil.Discard, cl, "html"); err == nil {
+		t.Errorf("Write in an unknown format returned no error")
+	}
+}
@@ -0,0 +1,113 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats understood by Write.
+const (
+	Markdown = "markdown"
+	JSON     = "json"
+)
+
+// Write writes cl to w in format.
+func Write(w io.Writer, cl *Changelog, format string) error {
+	switch format {
+	case Markdown:
+		return WriteMarkdown(w, cl)
+	case JSON:
+		return WriteJSON(w, cl)
+	}
+	return fmt.Errorf("changelog: unknown format %q", format)
+}
+
+// WriteMarkdown writes cl as one section per component listing its
+// releases, with their notes and links, suited to review comments.
+func WriteMarkdown(w io.Writer, cl *Changelog) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Changelog of %s (%s)\n", markdownEscape(cl.StackName), markdownEscape(cl.StackID))
+	if len(cl.Components) == 0 && len(cl.Errors) == 0 {
+		b.WriteString("\nNo outdated components.\n")
+	}
+	for _, c := range cl.Components {
+		name := c.ID
+		if c.Name != "" && c.Name != c.ID {
+			name = c.Name + " (" + c.ID + ")"
+		}
+		fmt.Fprintf(&b, "\n#### %s %s -> %s\n\n", markdownEscape(name), markdownEscape(c.From), markdownEscape(c.To))
+		if len(c.Entries) == 0 {
+			b.WriteString("No releases listed in the changelog.\n")
+			continue
+		}
+		spaced := false
+		for i, e := range c.Entries {
+			notes := strings.TrimSpace(e.ReleaseNotes)
+			if i > 0 && (spaced || notes != "") {
+				b.WriteString("\n")
+			}
+			spaced = notes != ""
+			fmt.Fprintf(&b, "- **%s**", markdownEscape(e.FullVersion()))
+			if len(e.PublishedAt) >= len("2006-01-02") {
+				fmt.Fprintf(&b, " (%s)", e.PublishedAt[:len("2006-01-02")])
+			}
+			if e.ReleaseNotesURL != "" {
+				fmt.Fprintf(&b, " [release notes](%s)", e.ReleaseNotesURL)
+			}
+			b.WriteString("\n")
+			if notes == "" {
+				continue
+			}
+			b.WriteString("\n")
+			for _, line := range strings.Split(notes, "\n") {
+				if line = strings.TrimRight(line, " \t\r"); line == "" {
+					b.WriteString("\n")
+					continue
+				}
+				fmt.Fprintf(&b, "  %s\n", markdownEscape(line))
+			}
+		}
+	}
+	if len(cl.Errors) > 0 {
+		ids := make([]string, 0, len(cl.Errors))
+		for id := range cl.Errors {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		b.WriteString("\n#### Unavailable\n\n")
+		for _, id := range ids {
+			fmt.Fprintf(&b, "- %s: %s\n", markdownEscape(id), markdownEscape(cl.Errors[id].Error()))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes cl as indented JSON, errors as messages.
+func WriteJSON(w io.Writer, cl *Changelog) error {
+	out := struct {
+		*Changelog
+		Errors map[string]string `json:"errors,omitempty"`
+	}{Changelog: cl}
+	if len(cl.Errors) > 0 {
+		out.Errors = make(map[string]string)
+		for id, err := range cl.Errors {
+			out.Errors[id] = err.Error()
+		}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+var markdownReplacer = strings.NewReplacer("|", `\|`, "<", "&lt;", "*", `\*`, "_", `\_`, "`", "\\`")
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
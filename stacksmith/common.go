@@ -1,5 +1,16 @@
 package stacksmith
 
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/sling"
+)
+
+// MaxPerPage is the largest page the API serves, used when following every
+// page of a list.
+const MaxPerPage = 100
+
 // PaginationParams ...
 type PaginationParams struct {
 	Page    int `url:"page,omitempty"`
@@ -11,3 +22,15 @@ type StatusDeletion struct {
 	ID      string `json:"id"`
 	Deleted bool   `json:"deleted"`
 }
+
+// do sends the request of sl with ctx, decoding the response into success
+// or an APIError.
+func do(ctx context.Context, sl *sling.Sling, success interface{}) (*http.Response, error) {
+	req, err := sl.Request()
+	if err != nil {
+		return nil, err
+	}
+	apiError := new(APIError)
+	resp, err := sl.Do(req.WithContext(ctx), success, apiError)
+	return resp, relevantError(err, *apiError)
+}
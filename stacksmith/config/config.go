@@ -0,0 +1,303 @@
+// Package config loads named profiles, one per Stacksmith account, and
+// builds configured clients from them.
+//
+// The configuration file lives at $SMITH_CONFIG, or by default at
+// smith/config.yaml under the user configuration directory
+// (~/.config/smith/config.yaml on Linux):
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    api_key_env: PROD_STACKSMITH_KEY
+//	    per_page: 50
+//	    retry:
+//	      max_retries: 3
+//	      backoff: 500ms
+//	    rate_limit:
+//	      rate: 5
+//	      burst: 10
+//	    output: table
+//	  staging:
+//	    api_key_file: ~/.secrets/stacksmith-staging
+//	    base_url: https://stacksmith.staging.example.com/api/v1/
+//
+// Environment variables override the file: SMITH_PROFILE picks the profile,
+// and SMITH_API_KEY (or STACKSMITH_API_KEY), SMITH_BASE_URL, SMITH_PER_PAGE,
+// SMITH_MAX_RETRIES, SMITH_TIMEOUT and SMITH_OUTPUT override its settings.
+// SMITH_API_KEY leaves alone the key of a profile with a base_url, unless
+// SMITH_BASE_URL is set too.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultProfileName is the profile used when none is selected.
+const DefaultProfileName = "default"
+
+// Config holds the profiles of a configuration file.
+type Config struct {
+	DefaultProfile string              `yaml:"default_profile,omitempty"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+}
+
+// Profile holds the settings of one Stacksmith account.
+type Profile struct {
+	Name string `yaml:"-"`
+
+	// The API key is read from the first source set among APIKey,
+	// APIKeyEnv, APIKeyFile and APIKeyCommand.
+	APIKey        string `yaml:"api_key,omitempty"`
+	APIKeyEnv     string `yaml:"api_key_env,omitempty"`
+	APIKeyFile    string `yaml:"api_key_file,omitempty"`
+	APIKeyCommand string `yaml:"api_key_command,omitempty"`
+
+	// BaseURL replaces the public Stacksmith API.
+	BaseURL string `yaml:"base_url,omitempty"`
+	// PerPage is the default page size of list commands.
+	PerPage int `yaml:"per_page,omitempty"`
+	// Timeout bounds every request. Zero means no timeout.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Retry   RetryPolicy   `yaml:"retry,omitempty"`
+	// RateLimit bounds the requests sent by the client, retries included.
+	RateLimit RateLimit `yaml:"rate_limit,omitempty"`
+	// Output is the default output format of the command-line tool.
+	Output string `yaml:"output,omitempty"`
+}
+
+// RetryPolicy configures the stacksmith.RetryTransport of a client.
+type RetryPolicy struct {
+	MaxRetries int           `yaml:"max_retries,omitempty"`
+	Backoff    time.Duration `yaml:"backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// RateLimit configures the stacksmith.RateLimitTransport of a client.
+type RateLimit struct {
+	// Rate is the number of requests per second, 0 for no limit.
+	Rate  float64 `yaml:"rate,omitempty"`
+	Burst int     `yaml:"burst,omitempty"`
+}
+
+// DefaultPath returns $SMITH_CONFIG, or else smith/config.yaml in the user
+// configuration directory.
+func DefaultPath() (string, error) {
+	if path := os.Getenv("SMITH_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smith", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. A missing file gives an empty
+// configuration, so that environment variables alone are enough.
+func Load(path string) (*Config, error) {
+	c := &Config{Profiles: make(map[string]*Profile)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("config: %s: %v", path, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			p = new(Profile)
+			c.Profiles[name] = p
+		}
+		p.Name = name
+	}
+	return c, nil
+}
+
+// LoadDefault reads the configuration file at DefaultPath.
+func LoadDefault() (*Config, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Profile returns the named profile with the environment overrides applied.
+// An empty name selects $SMITH_PROFILE, then DefaultProfile, then "default".
+// Only a profile asked for by name has to exist in the file.
+func (c *Config) Profile(name string) (*Profile, error) {
+	explicit := name != ""
+	if name == "" {
+		name = os.Getenv("SMITH_PROFILE")
+		explicit = name != ""
+	}
+	if name == "" {
+		name = c.DefaultProfile
+		explicit = name != ""
+	}
+	if name == "" {
+		name = DefaultProfileName
+	}
+
+	p := &Profile{Name: name}
+	if found, ok := c.Profiles[name]; ok {
+		*p = *found
+	} else if explicit {
+		return nil, fmt.Errorf("config: no profile named %q", name)
+	}
+	if err := p.applyEnv(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// applyEnv applies the environment overrides. The API key of the
+// environment is not sent to the base URL of a profile, which has its own
+// key, unless SMITH_BASE_URL replaces that URL as well.
+func (p *Profile) applyEnv() error {
+	baseURL := os.Getenv("SMITH_BASE_URL")
+	if key := firstEnv("SMITH_API_KEY", "STACKSMITH_API_KEY"); key != "" && (p.BaseURL == "" || baseURL != "") {
+		p.APIKey = key
+	}
+	if baseURL != "" {
+		p.BaseURL = baseURL
+	}
+	if output := os.Getenv("SMITH_OUTPUT"); output != "" {
+		p.Output = output
+	}
+	if perPage := os.Getenv("SMITH_PER_PAGE"); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil {
+			return fmt.Errorf("config: SMITH_PER_PAGE: %v", err)
+		}
+		p.PerPage = n
+	}
+	if retries := os.Getenv("SMITH_MAX_RETRIES"); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil {
+			return fmt.Errorf("config: SMITH_MAX_RETRIES: %v", err)
+		}
+		p.Retry.MaxRetries = n
+	}
+	if timeout := os.Getenv("SMITH_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("config: SMITH_TIMEOUT: %v", err)
+		}
+		p.Timeout = d
+	}
+	return nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Key returns the API key of the profile, read from its source.
+func (p *Profile) Key() (string, error) {
+	switch {
+	case p.APIKey != "":
+		return p.APIKey, nil
+	case p.APIKeyEnv != "":
+		if key := os.Getenv(p.APIKeyEnv); key != "" {
+			return key, nil
+		}
+		return "", fmt.Errorf("config: profile %s: $%s is empty", p.Name, p.APIKeyEnv)
+	case p.APIKeyFile != "":
+		data, err := ioutil.ReadFile(expandHome(p.APIKeyFile))
+		if err != nil {
+			return "", fmt.Errorf("config: profile %s: %v", p.Name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case p.APIKeyCommand != "":
+		out, err := exec.Command("sh", "-c", p.APIKeyCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("config: profile %s: api_key_command: %v", p.Name, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", fmt.Errorf("config: profile %s has no API key", p.Name)
+}
+
+// NewClient builds a Client for the profile. httpClient, when given, is
+// copied and its transport wrapped with the rate limit and retry policy.
+func (p *Profile) NewClient(httpClient *http.Client) (*stacksmith.Client, error) {
+	key, err := p.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := new(http.Client)
+	if httpClient != nil {
+		*configured = *httpClient
+	}
+	if p.Timeout > 0 {
+		configured.Timeout = p.Timeout
+	}
+	if p.RateLimit.Rate > 0 {
+		configured.Transport = &stacksmith.RateLimitTransport{
+			Base:  configured.Transport,
+			Rate:  p.RateLimit.Rate,
+			Burst: p.RateLimit.Burst,
+		}
+	}
+	if p.Retry.MaxRetries > 0 {
+		configured.Transport = &stacksmith.RetryTransport{
+			Base:       configured.Transport,
+			MaxRetries: p.Retry.MaxRetries,
+			Backoff:    p.Retry.Backoff,
+			MaxBackoff: p.Retry.MaxBackoff,
+		}
+	}
+
+	if p.BaseURL != "" {
+		return stacksmith.NewClientWithBaseURL(p.BaseURL, key, configured), nil
+	}
+	return stacksmith.NewClient(key, configured), nil
+}
+
+// NewClient builds a Client from the named profile of the default
+// configuration file. An empty name selects the default profile.
+func NewClient(profile string) (*stacksmith.Client, error) {
+	c, err := LoadDefault()
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	return p.NewClient(nil)
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfig = `default_profile: prod
+profiles:
+  prod:
+    api_key_env: TEST_PROD_KEY
+    per_page: 50
+    timeout: 10s
+    retry:
+      max_retries: 3
+      backoff: 500ms
+    rate_limit:
+      rate: 5
+      burst: 10
+    output: yaml
+  staging:
+    api_key_file: staging.key
+    base_url: https://stacksmith.staging.example.com/api/v1/
+  local:
+    api_key_command: echo local_key
+`
+
+func writeConfig(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func clearEnv() {
+	for _, name := range []string{"SMITH_PROFILE", "SMITH_API_KEY", "STACKSMITH_API_KEY", "SMITH_BASE_URL",
+		"SMITH_PER_PAGE", "SMITH_MAX_RETRIES", "SMITH_TIMEOUT", "SMITH_OUTPUT", "TEST_PROD_KEY"} {
+		os.Unsetenv(name)
+	}
+}
+
+func TestConfig_Profile(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+	dir, _ := ioutil.TempDir("", "smith")
+	defer os.RemoveAll(dir)
+
+	c, err := Load(writeConfig(t, dir))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	prod, err := c.Profile("")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	expected := Profile{Name: "prod", APIKeyEnv: "TEST_PROD_KEY", PerPage: 50, Timeout: 10 * time.Second,
+		Retry: RetryPolicy{MaxRetries: 3, Backoff: 500 * time.Millisecond}, RateLimit: RateLimit{Rate: 5, Burst: 10},
+		Output: "yaml"}
+	if *prod != expected {
+		t.Errorf("Profile returned %+v, want %+v", *prod, expected)
+	}
+	if _, err := prod.Key(); err == nil {
+		t.Errorf("Key returned no error with $TEST_PROD_KEY unset")
+	}
+	os.Setenv("TEST_PROD_KEY", "prod_key")
+	if key, err := prod.Key(); key != "prod_key" || err != nil {
+		t.Errorf("Key returned %q, %v, want prod_key", key, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "staging.key"), []byte("staging_key\n"), 0600)
+	os.Setenv("SMITH_PROFILE", "staging")
+	staging, err := c.Profile("")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	staging.APIKeyFile = filepath.Join(dir, staging.APIKeyFile)
+	if key, err := staging.Key(); key != "staging_key" || err != nil {
+		t.Errorf("Key returned %q, %v, want staging_key", key, err)
+	}
+
+	local, _ := c.Profile("local")
+	if key, err := local.Key(); key != "local_key" || err != nil {
+		t.Errorf("Key returned %q, %v, want local_key", key, err)
+	}
+
+	os.Setenv("SMITH_API_KEY", "env_key")
+	os.Setenv("SMITH_PER_PAGE", "20")
+	os.Setenv("SMITH_OUTPUT", "json")
+	overridden, _ := c.Profile("prod")
+	if key, _ := overridden.Key(); key != "env_key" || overridden.PerPage != 20 || overridden.Output != "json" {
+		t.Errorf("Profile with overrides returned %+v", overridden)
+	}
+
+	os.Setenv("SMITH_PROFILE", "")
+	staging, _ = c.Profile("staging")
+	if staging.APIKey != "" || staging.BaseURL != "https://stacksmith.staging.example.com/api/v1/" {
+		t.Errorf("Profile with a base_url took the key of $SMITH_API_KEY: %+v", staging)
+	}
+	os.Setenv("SMITH_BASE_URL", "https://stacksmith.example.com/api/v1/")
+	staging, _ = c.Profile("staging")
+	if staging.APIKey != "env_key" || staging.BaseURL != "https://stacksmith.example.com/api/v1/" {
+		t.Errorf("Profile with $SMITH_BASE_URL returned %+v", staging)
+	}
+
+	if _, err := c.Profile("missing"); err == nil {
+		t.Errorf("Profile of a missing profile returned no error")
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	c, err := Load(filepath.Join(os.TempDir(), "smith-does-not-exist.yaml"))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	os.Setenv("STACKSMITH_API_KEY", "env_key")
+	p, err := c.Profile("")
+	if err != nil || p.Name != DefaultProfileName {
+		t.Fatalf("Profile returned %+v, %v", p, err)
+	}
+	if key, _ := p.Key(); key != "env_key" {
+		t.Errorf("Key returned %q, want env_key", key)
+	}
+}
+
+func TestProfile_NewClient(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.URL.Path != "/api/v1/stacks/stack1" || r.FormValue("api_key") != "my_api_key" {
+			t.Errorf("Client requested %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"status":"502","error":"Bad gateway"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"stack1"}`))
+	}))
+	defer server.Close()
+
+	p := &Profile{Name: "test", APIKey: "my_api_key", BaseURL: server.URL + "/api/v1",
+		Retry: RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond}, RateLimit: RateLimit{Rate: 100}}
+	client, err := p.NewClient(nil)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	stack, _, err := client.Stacks.Get("stack1")
+	if err != nil || stack.ID != "stack1" || attempts != 2 {
+		t.Errorf("Stacks.Get returned %+v, %v after %d attempts", stack, err, attempts)
+	}
+
+	if _, err := (&Profile{Name: "empty"}).NewClient(nil); err == nil {
+		t.Errorf("NewClient without API key returned no error")
+	}
+}
@@ -0,0 +1,297 @@
+// Package depgraph resolves the transitive dependencies of components and
+// stack definitions into a graph, and writes it as Graphviz DOT or JSON.
+//
+// Discovery only returns the direct dependencies of a component. A Resolver
+// follows them level by level, a bounded number of requests at a time, and
+// remembers every component it has looked up.
+package depgraph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/internal/parallel"
+)
+
+// DefaultConcurrency is the number of requests in flight when a Resolver is
+// created with a concurrency of zero.
+const DefaultConcurrency = 4
+
+// Resolver looks up dependencies, each component once.
+type Resolver struct {
+	discovery   *stacksmith.DiscoveryService
+	concurrency int
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+// NewResolver returns a Resolver sending at most concurrency requests at a
+// time to discovery.
+func NewResolver(discovery *stacksmith.DiscoveryService, concurrency int) *Resolver {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	return &Resolver{discovery: discovery, concurrency: concurrency, cache: make(map[string][]string)}
+}
+
+// Graph is the dependency graph of a component or a stack definition.
+type Graph struct {
+	Name string
+	// Roots are the components asked for, in order.
+	Roots []string
+	Nodes map[string]*Node
+}
+
+// Node is a component of a Graph.
+type Node struct {
+	ID string `json:"id"`
+	// Version is the version asked for by the stack definition, empty for
+	// components only reached as dependencies.
+	Version      string   `json:"version,omitempty"`
+	Dependencies []string `json:"dependencies"`
+	// Depth is the length of the shortest path from a root, 0 for roots.
+	Depth int `json:"depth"`
+}
+
+// Component resolves the dependencies of the component id.
+func (r *Resolver) Component(ctx context.Context, id string) (*Graph, error) {
+	return r.Resolve(ctx, id, []stacksmith.ComponentItem{{ID: id}})
+}
+
+// Definition resolves the dependencies of the OS and components of def.
+func (r *Resolver) Definition(ctx context.Context, def *stacksmith.StackDefinition) (*Graph, error) {
+	var roots []stacksmith.ComponentItem
+	if def.OS.ID != "" {
+		roots = append(roots, def.OS)
+	}
+	return r.Resolve(ctx, def.Name, append(roots, def.Components...))
+}
+
+// Resolve builds the graph named name of roots and everything they depend
+// on. It fails if the dependencies of a component cannot be looked up.
+func (r *Resolver) Resolve(ctx context.Context, name string, roots []stacksmith.ComponentItem) (*Graph, error) {
+	g := &Graph{Name: name, Nodes: make(map[string]*Node)}
+	var level []string
+	for _, root := range roots {
+		if n, ok := g.Nodes[root.ID]; ok {
+			if n.Version == "" {
+				n.Version = root.Version
+			}
+			continue
+		}
+		g.Roots = append(g.Roots, root.ID)
+		g.Nodes[root.ID] = &Node{ID: root.ID, Version: root.Version}
+		level = append(level, root.ID)
+	}
+
+	for depth := 1; len(level) > 0; depth++ {
+		deps := make([][]string, len(level))
+		errs := make([]error, len(level))
+		if err := parallel.ForEach(ctx, len(level), r.concurrency, func(i int) {
+			deps[i], errs[i] = r.dependencies(level[i])
+		}); err != nil {
+			return nil, err
+		}
+		var next []string
+		for i, id := range level {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("depgraph: dependencies of %s: %v", id, errs[i])
+			}
+			g.Nodes[id].Dependencies = deps[i]
+			for _, dep := range deps[i] {
+				if _, ok := g.Nodes[dep]; !ok {
+					g.Nodes[dep] = &Node{ID: dep, Depth: depth}
+					next = append(next, dep)
+				}
+			}
+		}
+		level = next
+	}
+	return g, nil
+}
+
+// dependencies returns the sorted direct dependencies of id.
+func (r *Resolver) dependencies(id string) ([]string, error) {
+	r.mu.Lock()
+	deps, ok := r.cache[id]
+	r.mu.Unlock()
+	if ok {
+		return deps, nil
+	}
+
+	dependencies, _, err := r.discovery.GetDependenciesFrom(id)
+	if err != nil {
+		return nil, err
+	}
+	deps = make([]string, 0, len(dependencies.Items))
+	seen := make(map[string]bool)
+	for _, dep := range dependencies.Items {
+		if dep != "" && !seen[dep] {
+			seen[dep] = true
+			deps = append(deps, dep)
+		}
+	}
+	sort.Strings(deps)
+
+	r.mu.Lock()
+	r.cache[id] = deps
+	r.mu.Unlock()
+	return deps, nil
+}
+
+// IDs returns the IDs of the nodes of g, sorted.
+func (g *Graph) IDs() []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CycleError is returned when ordering a graph with cycles.
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	cycles := make([]string, len(e.Cycles))
+	for i, cycle := range e.Cycles {
+		cycles[i] = strings.Join(cycle, " -> ") + " -> " + cycle[0]
+	}
+	return "depgraph: dependency cycle: " + strings.Join(cycles, ", ")
+}
+
+// Order returns the components of g, every one after its dependencies and
+// ties broken by ID, or a *CycleError when g has cycles.
+func (g *Graph) Order() ([]string, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for id, n := range g.Nodes {
+		pending[id] = len(n.Dependencies)
+		for _, dep := range n.Dependencies {
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+	var ready, order []string
+	for _, id := range g.IDs() {
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		added := false
+		for _, dependent := range dependents[id] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+				added = true
+			}
+		}
+		if added {
+			sort.Strings(ready)
+		}
+	}
+	return order, nil
+}
+
+// Cycles returns the cycles of g, each as the components it goes through
+// starting from the smallest ID. A component depending on itself is a
+// cycle of one.
+func (g *Graph) Cycles() [][]string {
+	// Tarjan's strongly connected components.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+	var visit func(id string)
+	visit = func(id string) {
+		index[id], low[id] = len(index), len(index)
+		stack = append(stack, id)
+		onStack[id] = true
+		selfLoop := false
+		for _, dep := range g.Nodes[id].Dependencies {
+			if dep == id {
+				selfLoop = true
+			}
+			if _, ok := g.Nodes[dep]; !ok {
+				continue
+			}
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				if low[dep] < low[id] {
+					low[id] = low[dep]
+				}
+			} else if onStack[dep] && index[dep] < low[id] {
+				low[id] = index[dep]
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			cycles = append(cycles, g.cycleThrough(component))
+		}
+	}
+	for _, id := range g.IDs() {
+		if _, seen := index[id]; !seen {
+			visit(id)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// cycleThrough returns the shortest cycle going through the smallest ID of
+// the strongly connected component.
+func (g *Graph) cycleThrough(component []string) []string {
+	in := make(map[string]bool)
+	start := component[0]
+	for _, id := range component {
+		in[id] = true
+		if id < start {
+			start = id
+		}
+	}
+	parent := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.Nodes[id].Dependencies {
+			if dep == start {
+				var cycle []string
+				for ; id != ""; id = parent[id] {
+					cycle = append([]string{id}, cycle...)
+				}
+				return cycle
+			}
+			if _, seen := parent[dep]; !seen && in[dep] {
+				parent[dep] = id
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return []string{start}
+}
@@ -0,0 +1,149 @@
+package depgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testDependencies is the catalog served by testServer. php and apache
+// depend on each other through mod_php.
+var testDependencies = map[string][]string{
+	"rails":   {"ruby", "nodejs"},
+	"ruby":    {"openssl", "libyaml", "zlib"},
+	"nodejs":  {"openssl", "zlib"},
+	"openssl": {"zlib"},
+	"libyaml": {},
+	"zlib":    {},
+	"debian":  {},
+	"php":     {"mod_php", "openssl"},
+	"mod_php": {"apache"},
+	"apache":  {"mod_php", "openssl"},
+	"loop":    {"loop"},
+}
+
+// testServer serves testDependencies, counting the requests of each
+// component and the most requests in flight at once.
+func testServer() (*stacksmith.Client, map[string]int, *int32, func()) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	var inFlight, maxInFlight int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/components/"), "/dependencies")
+		mu.Lock()
+		requests[id]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		deps, ok := testDependencies[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status":"404","error":"Not found"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(stacksmith.Dependencies{TotalEntries: len(deps), TotalPages: 1, Items: deps})
+	})
+	server := httptest.NewServer(mux)
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), requests, &maxInFlight, server.Close
+}
+
+func TestResolve(t *testing.T) {
+	client, requests, maxInFlight, closeServer := testServer()
+	defer closeServer()
+	r := NewResolver(client.Discovery, 2)
+
+	g, err := r.Definition(context.Background(), &stacksmith.StackDefinition{
+		Name:       "My ROR stack",
+		OS:         stacksmith.ComponentItem{ID: "debian", Version: "wheezy"},
+		Components: []stacksmith.ComponentItem{{ID: "rails"}, {ID: "ruby", Version: "2.2.3"}},
+	})
+	if err != nil {
+		t.Fatalf("Definition returned error: %v", err)
+	}
+	if *maxInFlight != 2 {
+		t.Errorf("Definition sent %d requests at a time, want 2", *maxInFlight)
+	}
+	if !reflect.DeepEqual(g.Roots, []string{"debian", "rails", "ruby"}) {
+		t.Errorf("Definition returned roots %v", g.Roots)
+	}
+	depths := map[string]int{"debian": 0, "rails": 0, "ruby": 0, "nodejs": 1, "openssl": 1, "libyaml": 1, "zlib": 1}
+	for id, depth := range depths {
+		if n := g.Nodes[id]; n == nil || n.Depth != depth {
+			t.Errorf("node %s is %+v, want depth %d", id, n, depth)
+		}
+	}
+	if len(g.Nodes) != len(depths) || g.Nodes["ruby"].Version != "2.2.3" {
+		t.Errorf("Definition returned nodes %v", g.IDs())
+	}
+
+	order, err := g.Order()
+	expected := []string{"debian", "libyaml", "zlib", "openssl", "nodejs", "ruby", "rails"}
+	if err != nil || !reflect.DeepEqual(order, expected) {
+		t.Errorf("Order returned %v, %v, want %v", order, err, expected)
+	}
+
+	if _, err := r.Component(context.Background(), "nodejs"); err != nil {
+		t.Fatalf("Component returned error: %v", err)
+	}
+	for id, n := range requests {
+		if n != 1 {
+			t.Errorf("dependencies of %s requested %d times", id, n)
+		}
+	}
+}
+
+func TestResolve_Cycles(t *testing.T) {
+	client, _, _, closeServer := testServer()
+	defer closeServer()
+	r := NewResolver(client.Discovery, 0)
+
+	g, err := r.Resolve(context.Background(), "cycles", []stacksmith.ComponentItem{{ID: "php"}, {ID: "loop"}})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	expected := [][]string{{"apache", "mod_php"}, {"loop"}}
+	if cycles := g.Cycles(); !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Cycles returned %v, want %v", cycles, expected)
+	}
+	_, err = g.Order()
+	if _, ok := err.(*CycleError); !ok || err.Error() != "depgraph: dependency cycle: apache -> mod_php -> apache, loop -> loop" {
+		t.Errorf("Order returned error %v", err)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	client, _, _, closeServer := testServer()
+	defer closeServer()
+	r := NewResolver(client.Discovery, 1)
+
+	if _, err := r.Component(context.Background(), "unknown"); err == nil || !strings.HasPrefix(err.Error(), "depgraph: dependencies of unknown: ") {
+		t.Errorf("Component returned error %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Component(ctx, "rails"); err != context.Canceled {
+		t.Errorf("Component with a canceled context returned %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package depgraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT writes g as a Graphviz digraph. Roots are drawn as boxes and the
+// edges of cycles in red.
+func WriteDOT(w io.Writer, g *Graph) error {
+	inCycle := make(map[[2]string]bool)
+	for _, cycle := range g.Cycles() {
+		for i, id := range cycle {
+			inCycle[[2]string{id, cycle[(i+1)%len(cycle)]}] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", strconv.Quote(g.Name))
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=ellipse];")
+	for _, id := range g.IDs() {
+		n := g.Nodes[id]
+		label := id
+		if n.Version != "" {
+			label += "\n" + n.Version
+		}
+		attrs := "label=" + strconv.Quote(label)
+		if n.Depth == 0 {
+			attrs += ", shape=box"
+		}
+		fmt.Fprintf(bw, "  %s [%s];\n", strconv.Quote(id), attrs)
+	}
+	for _, id := range g.IDs() {
+		for _, dep := range g.Nodes[id].Dependencies {
+			attrs := ""
+			if inCycle[[2]string{id, dep}] {
+				attrs = " [color=red]"
+			}
+			fmt.Fprintf(bw, "  %s -> %s%s;\n", strconv.Quote(id), strconv.Quote(dep), attrs)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteJSON writes g as indented JSON: its nodes sorted by ID, the
+// topological order when g has no cycles and its cycles otherwise.
+func WriteJSON(w io.Writer, g *Graph) error {
+	out := struct {
+		Name   string     `json:"name"`
+		Roots  []string   `json:"roots"`
+		Nodes  []*Node    `json:"nodes"`
+		Order  []string   `json:"order,omitempty"`
+		Cycles [][]string `json:"cycles,omitempty"`
+	}{Name: g.Name, Roots: g.Roots, Nodes: []*Node{}}
+	if out.Roots == nil {
+		out.Roots = []string{}
+	}
+	for _, id := range g.IDs() {
+		out.Nodes = append(out.Nodes, g.Nodes[id])
+	}
+	order, err := g.Order()
+	if cycles, ok := err.(*CycleError); ok {
+		out.Cycles = cycles.Cycles
+	} else {
+		out.Order = order
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,55 @@
+package depgraph
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testGraph() *Graph {
+	return &Graph{Name: "My ROR stack", Roots: []string{"debian", "ruby"}, Nodes: map[string]*Node{
+		"debian":  {ID: "debian", Version: "wheezy", Dependencies: []string{}},
+		"ruby":    {ID: "ruby", Version: "2.2.3", Dependencies: []string{"openssl", "zlib"}},
+		"openssl": {ID: "openssl", Dependencies: []string{"zlib"}, Depth: 1},
+		"zlib":    {ID: "zlib", Dependencies: []string{}, Depth: 1},
+	}}
+}
+
+func testCyclicGraph() *Graph {
+	return &Graph{Name: "php", Roots: []string{"php"}, Nodes: map[string]*Node{
+		"php":     {ID: "php", Dependencies: []string{"mod_php"}},
+		"mod_php": {ID: "mod_php", Dependencies: []string{"apache"}, Depth: 1},
+		"apache":  {ID: "apache", Dependencies: []string{"mod_php"}, Depth: 2},
+	}}
+}
+
+func testGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		ioutil.WriteFile(path, got, 0644)
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, got:\n%s", name, got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for name, write := range map[string]func(io.Writer, *Graph) error{".dot": WriteDOT, ".json": WriteJSON} {
+		for golden, g := range map[string]*Graph{"graph": testGraph(), "cycle": testCyclicGraph()} {
+			var buf bytes.Buffer
+			if err := write(&buf, g); err != nil {
+				t.Fatalf("writing %s%s returned error: %v", golden, name, err)
+			}
+			testGolden(t, golden+name, buf.Bytes())
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package stacksmith
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -108,12 +109,17 @@ func (s *DiscoveryService) GetComponent(componentName string) (*Item, *http.Resp
 // GetChangelogFrom Retrieve the changelog for a component
 // https://stacksmith.bitnami.com/api/v1/#!/Discovery/get_components_id_changelog
 func (s *DiscoveryService) GetChangelogFrom(componentName string,
+	rangeParam *RangeParams, pageParam *PaginationParams) (*Changelog, *http.Response, error) {
+	return s.GetChangelogFromContext(context.Background(), componentName, rangeParam, pageParam)
+}
+
+// GetChangelogFromContext Retrieve the changelog for a component, aborting the request once ctx is done.
+func (s *DiscoveryService) GetChangelogFromContext(ctx context.Context, componentName string,
 	rangeParam *RangeParams, pageParam *PaginationParams) (*Changelog, *http.Response, error) {
 	changelog := new(Changelog)
-	apiError := new(APIError)
 	path := fmt.Sprintf("components/%s/changelog", componentName)
-	resp, err := s.sling.New().Get(path).QueryStruct(rangeParam).QueryStruct(pageParam).Receive(changelog, apiError)
-	return changelog, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Get(path).QueryStruct(rangeParam).QueryStruct(pageParam), changelog)
+	return changelog, resp, err
 }
 
 // GetDependenciesFrom Retrieve the component ID of the component dependencies
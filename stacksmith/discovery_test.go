@@ -1,6 +1,7 @@
 package stacksmith
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -158,4 +159,10 @@ func TestDiscoveryService_GetChangelogFrom(t *testing.T) {
 	if !reflect.DeepEqual(changelogRecieved, changelogExpected) {
 		t.Errorf("Discovery.GetChangelogFrom returned %+v, want %+v", changelogRecieved, changelogExpected)
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Discovery.GetChangelogFromContext(ctx, "go", rangeParams, pag); err == nil {
+		t.Errorf("Discovery.GetChangelogFromContext with a canceled context returned no error")
+	}
 }
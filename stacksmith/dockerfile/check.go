@@ -0,0 +1,157 @@
+package dockerfile
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// MismatchType is the kind of a difference between a Dockerfile and its stack.
+type MismatchType int
+
+// Mismatch types.
+const (
+	// StackMismatch: the Dockerfile claims to come from another stack.
+	StackMismatch MismatchType = iota
+	// OSMismatch: the base image is not the OS of the stack.
+	OSMismatch
+	// MissingComponent: a component of the stack is not installed.
+	MissingComponent
+	// ExtraComponent: an installed package is not a component of the stack.
+	ExtraComponent
+	// VersionMismatch: a component is installed at another version or revision.
+	VersionMismatch
+	// ChecksumMismatch: a component is installed from another package.
+	ChecksumMismatch
+)
+
+var mismatchTypes = []string{"stack", "os", "missing", "extra", "version", "checksum"}
+
+func (t MismatchType) String() string {
+	if int(t) < len(mismatchTypes) {
+		return mismatchTypes[t]
+	}
+	return "unknown"
+}
+
+// Mismatch is one difference between a Dockerfile and its stack.
+type Mismatch struct {
+	Type MismatchType
+	// Component is the component ID, empty for StackMismatch and OSMismatch.
+	Component string
+	// Want is what the stack says and Got what the Dockerfile has.
+	Want string
+	Got  string
+	// Line is the line of the Dockerfile at fault, when known.
+	Line int
+}
+
+func (m Mismatch) String() string {
+	subject := m.Type.String()
+	if m.Component != "" {
+		subject += " " + m.Component
+	}
+	var s string
+	switch m.Type {
+	case MissingComponent:
+		s = fmt.Sprintf("%s: want %s, not installed", subject, m.Want)
+	case ExtraComponent:
+		s = fmt.Sprintf("%s: %s installed, not in the stack", subject, m.Got)
+	default:
+		s = fmt.Sprintf("%s: want %s, got %s", subject, m.Want, m.Got)
+	}
+	if m.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", m.Line, s)
+	}
+	return s
+}
+
+// Check compares d with stack, the stack it claims to come from, and
+// returns the mismatches, none when they agree.
+func Check(d *Dockerfile, stack *stacksmith.Stack) []Mismatch {
+	var mismatches []Mismatch
+	if d.StackID != "" && d.StackID != stack.ID {
+		mismatches = append(mismatches, Mismatch{Type: StackMismatch, Want: stack.ID, Got: d.StackID})
+	}
+	if m, ok := checkOS(d.From, stack.Os); !ok {
+		mismatches = append(mismatches, m)
+	}
+
+	installed := make(map[string]Installed)
+	for _, c := range d.Components {
+		installed[c.ID] = c
+	}
+	declared := make(map[string]bool)
+	for _, want := range stack.Components {
+		declared[want.ID] = true
+		wantVersion := version.Format(want.Version, want.Revision)
+		got, ok := installed[want.ID]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Type: MissingComponent, Component: want.ID, Want: wantVersion})
+		case got.Version != want.Version || got.Revision != want.Revision:
+			mismatches = append(mismatches, Mismatch{Type: VersionMismatch, Component: want.ID,
+				Want: wantVersion, Got: version.Format(got.Version, got.Revision), Line: got.Line})
+		case want.Checksum != "" && got.Checksum != "" && !strings.EqualFold(want.Checksum, got.Checksum):
+			mismatches = append(mismatches, Mismatch{Type: ChecksumMismatch, Component: want.ID,
+				Want: want.Checksum, Got: got.Checksum, Line: got.Line})
+		}
+	}
+	for _, got := range d.Components {
+		if !declared[got.ID] {
+			mismatches = append(mismatches, Mismatch{Type: ExtraComponent, Component: got.ID, Got: got.String(), Line: got.Line})
+		}
+	}
+	return mismatches
+}
+
+// osImages names the OS of the base images whose name does not start with
+// it.
+var osImages = map[string]string{
+	"minideb":        "debian",
+	"minideb-extras": "debian",
+}
+
+// imageOS returns the OS ID of the image name: that of osImages, or the
+// start of its last path element, "debian" for "debian-buildpack".
+func imageOS(name string) string {
+	base := path.Base(name)
+	if id, ok := osImages[base]; ok {
+		return id
+	}
+	if i := strings.Index(base, "-"); i > 0 {
+		return base[:i]
+	}
+	return base
+}
+
+// checkOS matches the base image against the OS of the stack: the image
+// must be one of the OS, as told by imageOS, and its tag, when it looks like
+// "wheezy-r7", must name the OS version and revision.
+func checkOS(from Image, os stacksmith.Component) (Mismatch, bool) {
+	if os.ID == "" {
+		return Mismatch{}, true
+	}
+	want := os.ID + " " + version.Format(os.Version, os.Revision)
+	m := Mismatch{Type: OSMismatch, Want: want, Got: from.String()}
+	if imageOS(from.Name) != os.ID {
+		return m, false
+	}
+	release, revision := from.Tag, -1
+	if i := strings.LastIndex(from.Tag, "-r"); i >= 0 {
+		if n, err := strconv.Atoi(from.Tag[i+2:]); err == nil {
+			release, revision = from.Tag[:i], n
+		}
+	}
+	if release != "" && release != "latest" && release != os.Version {
+		return m, false
+	}
+	if revision >= 0 && revision != os.Revision {
+		return m, false
+	}
+	return Mismatch{}, true
+}
@@ -0,0 +1,62 @@
+package dockerfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	stack := testStack(t)
+
+	d := parseTestdata(t)
+	if mismatches := Check(d, stack); len(mismatches) != 0 {
+		t.Errorf("Check of the generated Dockerfile returned %v", mismatches)
+	}
+
+	d.StackID = "other"
+	d.From.Tag = "jessie-r2"
+	d.Components[0].Revision = 1
+	d.Components = append(d.Components, Installed{ID: "nodejs", Version: "6.5.0", Revision: 0, Line: 20})
+	var got []string
+	for _, m := range Check(d, stack) {
+		got = append(got, m.String())
+	}
+	expected := []string{
+		"stack: want bzr9nhz, got other",
+		"os: want debian wheezy-7, got gcr.io/stacksmith-images/debian-buildpack:jessie-r2",
+		"line 17: version ruby: want 2.2.3-3, got 2.2.3-1",
+		"line 20: extra nodejs: nodejs-6.5.0-0 installed, not in the stack",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Check returned %q, want %q", got, expected)
+	}
+
+	d.Components = nil
+	d.From = Image{Name: "bitnami/minideb", Tag: "jessie"}
+	mismatches := Check(d, stack)
+	if len(mismatches) != 3 || mismatches[1].Type != OSMismatch || mismatches[2].Type != MissingComponent {
+		t.Errorf("Check returned %v", mismatches)
+	}
+}
+
+func TestCheckOS(t *testing.T) {
+	os := testStack(t).Os
+	var cases = []struct {
+		from Image
+		ok   bool
+	}{
+		{Image{Name: "gcr.io/stacksmith-images/debian-buildpack", Tag: "wheezy-r7"}, true},
+		{Image{Name: "gcr.io/stacksmith-images/debian-buildpack", Tag: "wheezy-r6"}, false},
+		{Image{Name: "bitnami/minideb", Tag: "wheezy"}, true},
+		{Image{Name: "bitnami/minideb-extras", Tag: "latest"}, true},
+		{Image{Name: "bitnami/minideb", Tag: "jessie"}, false},
+		{Image{Name: "debian", Tag: "wheezy"}, true},
+		{Image{Name: "ubuntu", Tag: "14.04"}, false},
+		{Image{Name: "gcr.io/stacksmith-images/ubuntu-buildpack", Tag: "wheezy-r7"}, false},
+	}
+	for _, c := range cases {
+		if _, ok := checkOS(c.from, os); ok != c.ok {
+			t.Errorf("checkOS(%s) returned %t, want %t", c.from, ok, c.ok)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package dockerfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Dockerfile is what Parse finds in a Dockerfile generated by Stacksmith.
+type Dockerfile struct {
+	// From is the base image of the first stage.
+	From Image
+	// Components are the packages installed with bitnami-pkg.
+	Components []Installed
+	// Expose lists the exposed ports, such as "3000" or "53/udp".
+	Expose []string
+	Env    map[string]string
+	// StackID is the STACKSMITH_STACK_ID variable set by Stacksmith.
+	StackID string
+}
+
+// Image is an image reference, split at its tag.
+type Image struct {
+	Name string
+	Tag  string
+}
+
+func (i Image) String() string {
+	if i.Tag == "" {
+		return i.Name
+	}
+	return i.Name + ":" + i.Tag
+}
+
+// Installed is a package installed by a "bitnami-pkg install" or
+// "bitnami-pkg unpack" command, named like "ruby-2.2.3-3".
+type Installed struct {
+	ID       string
+	Version  string
+	Revision int
+	Checksum string
+	// Line is where the RUN instruction starts.
+	Line int
+}
+
+func (c Installed) String() string {
+	return fmt.Sprintf("%s-%s-%d", c.ID, c.Version, c.Revision)
+}
+
+// Parse reads a Dockerfile. Instructions it does not know about are skipped.
+func Parse(r io.Reader) (*Dockerfile, error) {
+	d := &Dockerfile{Env: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	var instruction string
+	start := 0
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if instruction == "" {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			start = n
+		} else if strings.HasPrefix(line, "#") {
+			// Comments may sit between continuation lines.
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			instruction += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		if err := d.add(instruction+line, start); err != nil {
+			return nil, err
+		}
+		instruction = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if instruction != "" {
+		if err := d.add(instruction, start); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *Dockerfile) add(instruction string, line int) error {
+	fields := strings.SplitN(strings.TrimSpace(instruction), " ", 2)
+	keyword, args := strings.ToUpper(fields[0]), ""
+	if len(fields) > 1 {
+		args = strings.TrimSpace(fields[1])
+	}
+
+	switch keyword {
+	case "FROM":
+		if d.From.Name != "" {
+			return nil
+		}
+		words := strings.Fields(args)
+		for len(words) > 0 && strings.HasPrefix(words[0], "--") {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			return fmt.Errorf("dockerfile: line %d: FROM without image", line)
+		}
+		d.From = parseImage(words[0])
+	case "EXPOSE":
+		d.Expose = append(d.Expose, strings.Fields(args)...)
+	case "ENV":
+		words, err := splitWords(args)
+		if err != nil {
+			return fmt.Errorf("dockerfile: line %d: %v", line, err)
+		}
+		if len(words) > 0 && !strings.Contains(words[0], "=") {
+			// Legacy form: ENV KEY value with spaces
+			d.setEnv(words[0], strings.Join(words[1:], " "))
+			return nil
+		}
+		for _, word := range words {
+			i := strings.Index(word, "=")
+			if i < 0 {
+				return fmt.Errorf("dockerfile: line %d: ENV %s has no value", line, word)
+			}
+			d.setEnv(word[:i], word[i+1:])
+		}
+	case "RUN":
+		words, err := splitWords(args)
+		if err != nil {
+			return fmt.Errorf("dockerfile: line %d: %v", line, err)
+		}
+		d.Components = append(d.Components, installed(words, line)...)
+	}
+	return nil
+}
+
+func (d *Dockerfile) setEnv(key, value string) {
+	d.Env[key] = value
+	if key == "STACKSMITH_STACK_ID" {
+		d.StackID = value
+	}
+}
+
+func parseImage(ref string) Image {
+	// A colon before the last slash belongs to a registry port.
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return Image{Name: ref[:i], Tag: ref[i+1:]}
+	}
+	return Image{Name: ref}
+}
+
+// installed finds the bitnami-pkg commands among the words of a RUN
+// instruction.
+func installed(words []string, line int) []Installed {
+	var found []Installed
+	for i := 0; i+2 < len(words); i++ {
+		if words[i] != "bitnami-pkg" || (words[i+1] != "install" && words[i+1] != "unpack") {
+			continue
+		}
+		c, ok := parsePackage(strings.TrimSuffix(words[i+2], ";"))
+		if !ok {
+			continue
+		}
+		c.Line = line
+		for j := i + 3; j < len(words) && !commandSeparator(words[j-1]); j++ {
+			if words[j] == "--checksum" && j+1 < len(words) {
+				c.Checksum = words[j+1]
+			} else if strings.HasPrefix(words[j], "--checksum=") {
+				c.Checksum = strings.TrimPrefix(words[j], "--checksum=")
+			}
+		}
+		found = append(found, c)
+		i += 2
+	}
+	return found
+}
+
+// commandSeparator tells whether word ends a shell command.
+func commandSeparator(word string) bool {
+	switch word {
+	case "&&", "||", "|":
+		return true
+	}
+	return strings.HasSuffix(word, ";")
+}
+
+// packageName matches "id-version-revision", the ID ending before the first
+// dash followed by a digit: "mysql-client-5.7.14-0".
+var packageName = regexp.MustCompile(`^([a-z][a-z0-9.]*(?:-[a-z][a-z0-9.]*)*)-(\d.*)-(\d+)$`)
+
+func parsePackage(name string) (Installed, bool) {
+	m := packageName.FindStringSubmatch(name)
+	if m == nil {
+		return Installed{}, false
+	}
+	revision, _ := strconv.Atoi(m[3])
+	return Installed{ID: m[1], Version: m[2], Revision: revision}, true
+}
+
+// splitWords splits shell words, honoring quotes and backslash escapes.
+// A ";" glued to a word is not split off.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,70 @@
+package dockerfile
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseTestdata(t *testing.T) *Dockerfile {
+	f, err := os.Open("testdata/Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	d := parseTestdata(t)
+
+	expected := &Dockerfile{
+		From: Image{Name: "gcr.io/stacksmith-images/debian-buildpack", Tag: "wheezy-r7"},
+		Components: []Installed{{
+			ID: "ruby", Version: "2.2.3", Revision: 3, Line: 17,
+			Checksum: "ac6a6ae84c695ddc540fcbca14b60273ce607c1464d2f374088ad2834cec0ccb",
+		}},
+		Expose: []string{"3000", "3001/udp"},
+		Env: map[string]string{
+			"STACKSMITH_STACK_ID":      "bzr9nhz",
+			"STACKSMITH_STACK_NAME":    "Ruby on Rails for bitnami",
+			"STACKSMITH_STACK_PRIVATE": "1",
+			"PATH":                     "/opt/bitnami/ruby/bin:$PATH",
+			"RAILS_ENV":                "development",
+		},
+		StackID: "bzr9nhz",
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Parse returned %+v, want %+v", d, expected)
+	}
+}
+
+func TestParse_Packages(t *testing.T) {
+	d, err := Parse(strings.NewReader(`FROM --platform=linux/amd64 localhost:5000/minideb
+RUN bitnami-pkg unpack mysql-client-5.7.14-0 && bitnami-pkg install jetty-9.3.11.v20160721-0 --checksum=86bb0918
+RUN bitnami-pkg install apache-2.4.17-1-2; bitnami-pkg install not-a-package
+`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if d.From != (Image{Name: "localhost:5000/minideb"}) {
+		t.Errorf("Parse returned base image %+v", d.From)
+	}
+	var got []string
+	for _, c := range d.Components {
+		got = append(got, c.String()+" "+c.Checksum)
+	}
+	expected := []string{"mysql-client-5.7.14-0 ", "jetty-9.3.11.v20160721-0 86bb0918", "apache-2.4.17-1-2 "}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Parse found components %q, want %q", got, expected)
+	}
+
+	if _, err := Parse(strings.NewReader("ENV NAME=\"unterminated\n")); err == nil {
+		t.Errorf("Parse of an unterminated quote returned no error")
+	}
+}
@@ -0,0 +1,282 @@
+// Package dockerfile saves the Dockerfiles generated by Stacksmith, parses
+// them and checks them against the stacks they come from.
+//
+// A saved Dockerfile starts with a header recording where it comes from:
+//
+//	# stacksmith: generated for stack bzr9nhz, regenerate the stack instead of editing
+//	# stacksmith-stack: bzr9nhz
+//	# stacksmith-os: debian 8-1
+//	# stacksmith-component: rails 4.2.7-0
+//	# stacksmith-checksum: sha256:5d41402abc4b2a76b9719d911017c592...
+//
+// The checksum covers the file without its header lines, so that a later
+// Save can tell whether the file was edited since.
+//
+// Parse reads the base image, the packages installed with bitnami-pkg, the
+// exposed ports and the environment of a Dockerfile, and Check reports where
+// they disagree with Stack.Os and Stack.Components.
+package dockerfile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// FileName is the name Save gives to Dockerfiles.
+const FileName = "Dockerfile"
+
+const headerPrefix = "# stacksmith"
+
+var (
+	// ErrNoHeader is returned for a Dockerfile without a Stacksmith header.
+	ErrNoHeader = errors.New("dockerfile: no stacksmith header")
+	// ErrModified is returned for a Dockerfile edited since it was saved.
+	ErrModified = errors.New("dockerfile: edited since it was generated")
+)
+
+// Header is the provenance recorded at the top of a saved Dockerfile.
+type Header struct {
+	StackID    string
+	OS         Version
+	Components []Version
+	// Checksum is the hex SHA-256 of the Dockerfile without its header.
+	Checksum string
+}
+
+// Version is a component ID with the version and revision it was generated at.
+type Version struct {
+	ID       string
+	Version  string
+	Revision int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%s %s-%d", v.ID, v.Version, v.Revision)
+}
+
+func newVersion(c stacksmith.Component) Version {
+	return Version{ID: c.ID, Version: c.Version, Revision: c.Revision}
+}
+
+// NewHeader returns the header of the Dockerfile generated for stack.
+func NewHeader(stack *stacksmith.Stack, contents []byte) Header {
+	h := Header{
+		StackID:  stack.ID,
+		OS:       newVersion(stack.Os),
+		Checksum: checksum(stripHeader(contents)),
+	}
+	for _, c := range stack.Components {
+		h.Components = append(h.Components, newVersion(c))
+	}
+	return h
+}
+
+func (h Header) lines() []string {
+	lines := []string{
+		fmt.Sprintf("%s: generated for stack %s, regenerate the stack instead of editing", headerPrefix, h.StackID),
+		headerPrefix + "-stack: " + h.StackID,
+	}
+	if h.OS.ID != "" {
+		lines = append(lines, headerPrefix+"-os: "+h.OS.String())
+	}
+	for _, c := range h.Components {
+		lines = append(lines, headerPrefix+"-component: "+c.String())
+	}
+	return append(lines, headerPrefix+"-checksum: sha256:"+h.Checksum)
+}
+
+// directive matches the parser directives, such as "# syntax=...", that
+// must stay on the first lines of a Dockerfile.
+var directive = regexp.MustCompile(`^#\s*[A-Za-z]+\s*=`)
+
+// Stamp returns contents with the header inserted after its parser
+// directives, replacing any previous header.
+func Stamp(contents []byte, h Header) []byte {
+	lines := splitLines(stripHeader(contents))
+	n := 0
+	for n < len(lines) && directive.MatchString(lines[n]) {
+		n++
+	}
+	var buf bytes.Buffer
+	for _, line := range lines[:n] {
+		buf.WriteString(line + "\n")
+	}
+	for _, line := range h.lines() {
+		buf.WriteString(line + "\n")
+	}
+	for _, line := range lines[n:] {
+		buf.WriteString(line + "\n")
+	}
+	return buf.Bytes()
+}
+
+// ReadHeader parses the header of a saved Dockerfile and checks it against
+// the rest of the file. It returns ErrNoHeader when there is no header and
+// ErrModified, along with the header, when the checksum does not match.
+func ReadHeader(data []byte) (*Header, error) {
+	h := new(Header)
+	found := false
+	for _, line := range splitLines(data) {
+		if !strings.HasPrefix(line, headerPrefix+"-") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := line[len(headerPrefix)+1:i], strings.TrimSpace(line[i+1:])
+		found = true
+		switch key {
+		case "stack":
+			h.StackID = value
+		case "os":
+			h.OS = parseVersion(value)
+		case "component":
+			h.Components = append(h.Components, parseVersion(value))
+		case "checksum":
+			h.Checksum = strings.TrimPrefix(value, "sha256:")
+		}
+	}
+	if !found {
+		return nil, ErrNoHeader
+	}
+	if h.Checksum != checksum(stripHeader(data)) {
+		return h, ErrModified
+	}
+	return h, nil
+}
+
+// parseVersion reads "id version-revision", the revision being optional.
+func parseVersion(s string) Version {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Version{}
+	}
+	v := Version{ID: fields[0]}
+	if len(fields) > 1 {
+		v.Version = fields[1]
+		if i := strings.LastIndex(v.Version, "-"); i >= 0 {
+			if revision, err := strconv.Atoi(v.Version[i+1:]); err == nil {
+				v.Version, v.Revision = v.Version[:i], revision
+			}
+		}
+	}
+	return v
+}
+
+func stripHeader(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range splitLines(data) {
+		if !strings.HasPrefix(line, headerPrefix) {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func splitLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Options tunes Write and Save.
+type Options struct {
+	// FileName replaces FileName.
+	FileName string
+	// Force overwrites files edited locally or not written by this package.
+	Force bool
+}
+
+// Write stamps contents, the Dockerfile generated for stack, and writes it
+// atomically into dir. An existing file is only replaced when it still is
+// as saved, unless opts.Force is set; else the error is an *os.PathError
+// wrapping ErrModified or ErrNoHeader. Write returns the path of the file.
+func Write(dir string, stack *stacksmith.Stack, contents []byte, opts *Options) (string, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	name := opts.FileName
+	if name == "" {
+		name = FileName
+	}
+	path := filepath.Join(dir, name)
+
+	if !opts.Force {
+		existing, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return path, err
+		}
+		if err == nil {
+			if _, err := ReadHeader(existing); err != nil {
+				return path, &os.PathError{Op: "write", Path: path, Err: err}
+			}
+		}
+	}
+
+	return path, writeAtomic(path, Stamp(contents, NewHeader(stack, contents)))
+}
+
+// writeAtomic writes to a temporary file of the same directory and renames
+// it, so that readers never see a partial file.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save fetches the stack and its Dockerfile and writes them into dir as
+// Write does.
+func Save(ctx context.Context, client *stacksmith.Client, stackID, dir string, opts *Options) (string, error) {
+	stack, _, err := client.Stacks.Get(stackID)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	contents, _, err := client.Stacks.Dockerfile(ctx, stackID)
+	if err != nil {
+		return "", err
+	}
+	return Write(dir, stack, contents, opts)
+}
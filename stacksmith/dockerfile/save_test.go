@@ -0,0 +1,129 @@
+package dockerfile
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+const generated = `# syntax=docker/dockerfile:1
+FROM bitnami/minideb-extras:jessie-r2
+RUN bitnami-pkg install ruby-2.3.1-1 --checksum 0c9ef4c5
+EXPOSE 3000
+`
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func TestStampAndReadHeader(t *testing.T) {
+	stack := testStack(t)
+	h := NewHeader(stack, []byte(generated))
+	stamped := Stamp([]byte(generated), h)
+
+	if !strings.HasPrefix(string(stamped), "# syntax=docker/dockerfile:1\n# stacksmith: generated for stack "+stack.ID) {
+		t.Errorf("Stamp did not keep the parser directive first:\n%s", stamped)
+	}
+	if restamped := Stamp(stamped, h); string(restamped) != string(stamped) {
+		t.Errorf("Stamp of a stamped file returned:\n%s", restamped)
+	}
+
+	read, err := ReadHeader(stamped)
+	if err != nil {
+		t.Fatalf("ReadHeader returned error: %v", err)
+	}
+	if !reflect.DeepEqual(*read, h) {
+		t.Errorf("ReadHeader returned %+v, want %+v", *read, h)
+	}
+	if len(read.Components) != len(stack.Components) || read.OS.ID != stack.Os.ID {
+		t.Errorf("ReadHeader returned %+v for stack %+v", *read, stack)
+	}
+
+	edited := strings.Replace(string(stamped), "EXPOSE 3000", "EXPOSE 8080", 1)
+	if _, err := ReadHeader([]byte(edited)); err != ErrModified {
+		t.Errorf("ReadHeader of an edited file returned %v, want ErrModified", err)
+	}
+	if _, err := ReadHeader([]byte(generated)); err != ErrNoHeader {
+		t.Errorf("ReadHeader without header returned %v, want ErrNoHeader", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockerfile")
+	defer os.RemoveAll(dir)
+	stack := testStack(t)
+
+	path, err := Write(dir, stack, []byte(generated), nil)
+	if err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if path != filepath.Join(dir, FileName) {
+		t.Errorf("Write returned path %s", path)
+	}
+	if _, err := Write(dir, stack, []byte(generated+"USER bitnami\n"), nil); err != nil {
+		t.Errorf("Write over an unedited file returned error: %v", err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, append(data, "RUN echo local\n"...), 0644)
+	_, err = Write(dir, stack, []byte(generated), nil)
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != ErrModified {
+		t.Errorf("Write over an edited file returned %v, want ErrModified", err)
+	}
+	if _, err := Write(dir, stack, []byte(generated), &Options{Force: true}); err != nil {
+		t.Errorf("Write with Force returned error: %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "Dockerfile.app"), []byte(generated), 0644)
+	_, err = Write(dir, stack, []byte(generated), &Options{FileName: "Dockerfile.app"})
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != ErrNoHeader {
+		t.Errorf("Write over a handwritten file returned %v, want ErrNoHeader", err)
+	}
+
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Write left %d files in the directory, want 2", len(entries))
+	}
+}
+
+func TestSave(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz.dockerfile", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(generated))
+	})
+
+	dir, _ := ioutil.TempDir("", "dockerfile")
+	defer os.RemoveAll(dir)
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+
+	path, err := Save(context.Background(), client, "bzr9nhz", dir, nil)
+	if err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	h, err := ReadHeader(data)
+	if err != nil || h.StackID != "bzr9nhz" {
+		t.Errorf("Save wrote a file with header %+v, %v", h, err)
+	}
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"gopkg.in/yaml.v2"
+)
+
+// Compose returns the docker-compose.yml running stack, the application
+// being built from the Dockerfile next to it. Only the application
+// publishes its ports on the host; the services are reached by it through
+// the project network. Variables without a value, such as passwords, must be
+// set in the environment of docker-compose.
+func Compose(stack *stacksmith.Stack, opts *Options) ([]byte, error) {
+	p := NewProject(stack, opts)
+
+	services := yaml.MapSlice{{Key: p.App.Name, Value: composeService(&p.App, true)}}
+	var volumes yaml.MapSlice
+	for _, s := range append([]Service{p.App}, p.Services...) {
+		for _, v := range s.Volumes {
+			volumes = append(volumes, yaml.MapItem{Key: v.Name, Value: yaml.MapSlice{}})
+		}
+	}
+	for i := range p.Services {
+		services = append(services, yaml.MapItem{Key: p.Services[i].Name, Value: composeService(&p.Services[i], false)})
+	}
+
+	doc := yaml.MapSlice{
+		{Key: "version", Value: "2"},
+		{Key: "services", Value: services},
+	}
+	if len(volumes) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "volumes", Value: volumes})
+	}
+	return yaml.Marshal(doc)
+}
+
+func composeService(s *Service, build bool) yaml.MapSlice {
+	var service yaml.MapSlice
+	if build {
+		service = append(service, yaml.MapItem{Key: "build", Value: "."})
+	}
+	if s.Image != "" {
+		service = append(service, yaml.MapItem{Key: "image", Value: s.Image})
+	}
+	if len(s.Ports) > 0 && build {
+		ports := make([]string, len(s.Ports))
+		for i, port := range s.Ports {
+			// The protocol keeps YAML 1.1 parsers from reading "22:22" as
+			// a base 60 number.
+			ports[i] = fmt.Sprintf("%d:%d/%s", port.Port, port.Port, strings.ToLower(protocol(port)))
+		}
+		service = append(service, yaml.MapItem{Key: "ports", Value: ports})
+	} else if len(s.Ports) > 0 {
+		expose := make([]string, len(s.Ports))
+		for i, port := range s.Ports {
+			expose[i] = fmt.Sprintf("%d/%s", port.Port, strings.ToLower(protocol(port)))
+		}
+		service = append(service, yaml.MapItem{Key: "expose", Value: expose})
+	}
+	if len(s.Env) > 0 {
+		var env yaml.MapSlice
+		for _, name := range s.EnvNames() {
+			value := s.Env[name]
+			if value == "" {
+				value = fmt.Sprintf("${%s:?set %s}", name, name)
+			}
+			env = append(env, yaml.MapItem{Key: name, Value: value})
+		}
+		service = append(service, yaml.MapItem{Key: "environment", Value: env})
+	}
+	if len(s.Volumes) > 0 {
+		volumes := make([]string, len(s.Volumes))
+		for i, v := range s.Volumes {
+			volumes[i] = v.Name + ":" + v.Path
+		}
+		service = append(service, yaml.MapItem{Key: "volumes", Value: volumes})
+	}
+	if len(s.DependsOn) > 0 {
+		service = append(service, yaml.MapItem{Key: "depends_on", Value: s.DependsOn})
+	}
+	return service
+}
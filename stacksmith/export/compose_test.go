@@ -0,0 +1,11 @@
+package export
+
+import "testing"
+
+func TestCompose(t *testing.T) {
+	got, err := Compose(testStack(t), nil)
+	if err != nil {
+		t.Fatalf("Compose returned error: %v", err)
+	}
+	testGolden(t, "docker-compose.yml", got)
+}
@@ -0,0 +1,232 @@
+// Package export turns a stack into the scaffolding needed to run it: a
+// docker-compose.yml, or Kubernetes Deployments and Services.
+//
+// The application container is built from the stack Dockerfile and holds
+// its runtimes and frameworks. Components of the "service" category, such as
+// databases, get a container of their own unless their template says they
+// run inside the application, as web servers do. Ports, volumes and
+// environment come from a registry of per-component templates, which
+// callers can extend with Register.
+//
+// The output only depends on the stack and the templates, so it can be
+// compared with golden files.
+package export
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// ServiceCategory is the Component.Category of the components that run in
+// a container of their own.
+const ServiceCategory = "service"
+
+// Placeholder is the value given in Kubernetes Secrets to the environment
+// variables users must set, such as passwords. Compose files require a
+// variable of the same name from the environment instead.
+const Placeholder = "change-me"
+
+// Port is a port a component listens on.
+type Port struct {
+	Port int
+	// Protocol is "TCP" when empty.
+	Protocol string
+}
+
+// Volume is a directory a component keeps its data in.
+type Volume struct {
+	// Name is unique within the stack, like "mysql-data".
+	Name string
+	Path string
+}
+
+// Template holds what a component needs to run.
+type Template struct {
+	// Image is the image of a service container, tagged with the component
+	// version. Empty means "bitnami/<component ID>".
+	Image   string
+	Ports   []Port
+	Volumes []Volume
+	// Env are the variables the component reads. Empty values are
+	// placeholders the user has to fill.
+	Env map[string]string
+	// InApp runs a service component inside the application container.
+	InApp bool
+}
+
+// Registry maps component IDs to their templates.
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{templates: make(map[string]Template)}
+}
+
+// Register sets the template of a component.
+func (r *Registry) Register(componentID string, t Template) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[componentID] = t
+}
+
+// Lookup returns the template of a component.
+func (r *Registry) Lookup(componentID string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[componentID]
+	return t, ok
+}
+
+// DefaultRegistry holds the templates of common components.
+var DefaultRegistry = NewRegistry()
+
+// Register sets the template of a component in DefaultRegistry.
+func Register(componentID string, t Template) {
+	DefaultRegistry.Register(componentID, t)
+}
+
+// Options tunes the export.
+type Options struct {
+	// Name of the application. Empty means the stack name.
+	Name string
+	// Image of the application, used by Kubernetes. Empty means "<name>:latest".
+	Image string
+	// Registry replaces DefaultRegistry.
+	Registry *Registry
+}
+
+// Project is the set of containers running a stack.
+type Project struct {
+	Name     string
+	App      Service
+	Services []Service
+}
+
+// Service is one container of a Project.
+type Service struct {
+	Name string
+	// Image is empty for the application when it is built from the Dockerfile.
+	Image      string
+	Components []string
+	Ports      []Port
+	Volumes    []Volume
+	Env        map[string]string
+	DependsOn  []string
+}
+
+// EnvNames returns the sorted names of the environment variables.
+func (s *Service) EnvNames() []string {
+	names := make([]string, 0, len(s.Env))
+	for name := range s.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProject lays stack out in containers. Each service container is
+// named after its component, and the application is given <ID>_HOST and
+// <ID>_PORT variables to reach it.
+func NewProject(stack *stacksmith.Stack, opts *Options) *Project {
+	if opts == nil {
+		opts = new(Options)
+	}
+	registry := opts.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	name := opts.Name
+	if name == "" {
+		name = stack.Name
+	}
+	name = dnsName(name)
+	if name == "" {
+		name = dnsName(stack.ID)
+	}
+
+	p := &Project{Name: name}
+	p.App = Service{Name: name, Image: opts.Image, Env: make(map[string]string)}
+	components := append([]stacksmith.Component(nil), stack.Components...)
+	sort.SliceStable(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+
+	for _, c := range components {
+		t, _ := registry.Lookup(c.ID)
+		if c.Category != ServiceCategory || t.InApp {
+			p.App.Components = append(p.App.Components, c.ID)
+			p.App.Ports = appendPorts(p.App.Ports, t.Ports)
+			p.App.Volumes = append(p.App.Volumes, t.Volumes...)
+			for k, v := range t.Env {
+				p.App.Env[k] = v
+			}
+			continue
+		}
+
+		image := t.Image
+		if image == "" {
+			image = "bitnami/" + c.ID
+		}
+		if c.Version != "" {
+			image += ":" + c.Version
+		}
+		service := Service{
+			Name:       dnsName(c.ID),
+			Image:      image,
+			Components: []string{c.ID},
+			Ports:      t.Ports,
+			Volumes:    t.Volumes,
+			Env:        make(map[string]string),
+		}
+		for k, v := range t.Env {
+			service.Env[k] = v
+		}
+		p.Services = append(p.Services, service)
+
+		prefix := envName(c.ID)
+		p.App.Env[prefix+"_HOST"] = service.Name
+		if len(t.Ports) > 0 {
+			p.App.Env[prefix+"_PORT"] = strconv.Itoa(t.Ports[0].Port)
+		}
+		p.App.DependsOn = append(p.App.DependsOn, service.Name)
+	}
+	return p
+}
+
+func appendPorts(ports []Port, more []Port) []Port {
+	for _, port := range more {
+		found := false
+		for _, existing := range ports {
+			if existing == port {
+				found = true
+			}
+		}
+		if !found {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+var notDNS = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dnsName turns s into a lowercase DNS label, as Kubernetes names must be.
+func dnsName(s string) string {
+	s = strings.Trim(notDNS.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
+
+var notEnv = regexp.MustCompile(`[^A-Z0-9]+`)
+
+func envName(s string) string {
+	return strings.Trim(notEnv.ReplaceAllString(strings.ToUpper(s), "_"), "_")
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	data, err := ioutil.ReadFile("testdata/stack.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(data, stack); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+// testGolden compares got with testdata/name, or rewrites it with -update.
+func testGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s differs, got:\n%s", name, got)
+	}
+}
+
+func TestNewProject(t *testing.T) {
+	p := NewProject(testStack(t), nil)
+
+	if p.Name != "ruby-on-rails-for-bitnami" {
+		t.Errorf("NewProject named the project %q", p.Name)
+	}
+	if expected := []string{"apache", "rails", "ruby"}; !reflect.DeepEqual(p.App.Components, expected) {
+		t.Errorf("NewProject put %v in the application, want %v", p.App.Components, expected)
+	}
+	if expected := []Port{{Port: 80}, {Port: 443}, {Port: 3000}}; !reflect.DeepEqual(p.App.Ports, expected) {
+		t.Errorf("NewProject gave the application ports %v, want %v", p.App.Ports, expected)
+	}
+	var services []string
+	for _, s := range p.Services {
+		services = append(services, s.Image)
+	}
+	if expected := []string{"bitnami/mysql:5.7.14", "bitnami/redis:3.2.3"}; !reflect.DeepEqual(services, expected) {
+		t.Errorf("NewProject returned services %v, want %v", services, expected)
+	}
+	if p.App.Env["MYSQL_HOST"] != "mysql" || p.App.Env["REDIS_PORT"] != "6379" {
+		t.Errorf("NewProject gave the application env %v", p.App.Env)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("redis", Template{Image: "example/redis", Ports: []Port{{Port: 7000}}})
+	registry.Register("apache", Template{})
+
+	p := NewProject(testStack(t), &Options{Name: "app", Registry: registry})
+	if len(p.Services) != 3 || p.Services[2].Image != "example/redis:3.2.3" || p.App.Env["REDIS_PORT"] != "7000" {
+		t.Errorf("NewProject with a custom registry returned %+v", p)
+	}
+	if _, ok := DefaultRegistry.Lookup("mysql"); !ok {
+		t.Errorf("DefaultRegistry has no mysql template")
+	}
+}
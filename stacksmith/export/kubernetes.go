@@ -0,0 +1,201 @@
+package export
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultStorage is the size requested by the PersistentVolumeClaims.
+const DefaultStorage = "8Gi"
+
+// Kubernetes returns the manifests running stack, as one YAML stream: a
+// Deployment per container, a Service per container listening on ports, a
+// PersistentVolumeClaim per volume and a Secret per container holding the
+// variables users must set, with Placeholder values to replace before
+// applying them. The application image must be pushed as opts.Image,
+// "<name>:latest" by default.
+func Kubernetes(stack *stacksmith.Stack, opts *Options) ([]byte, error) {
+	p := NewProject(stack, opts)
+	if p.App.Image == "" {
+		p.App.Image = p.Name + ":latest"
+	}
+
+	var docs []yaml.MapSlice
+	for _, s := range append([]Service{p.App}, p.Services...) {
+		for _, v := range s.Volumes {
+			docs = append(docs, persistentVolumeClaim(p.Name, v))
+		}
+		if secrets := secretNames(&s); len(secrets) > 0 {
+			docs = append(docs, secret(p.Name, &s, secrets))
+		}
+		docs = append(docs, deployment(p.Name, &s))
+		if len(s.Ports) > 0 {
+			docs = append(docs, service(p.Name, &s))
+		}
+	}
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func metadata(project, name string) yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "name", Value: name},
+		{Key: "labels", Value: labels(project, name)},
+	}
+}
+
+func labels(project, name string) yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "app.kubernetes.io/name", Value: name},
+		{Key: "app.kubernetes.io/part-of", Value: project},
+	}
+}
+
+func deployment(project string, s *Service) yaml.MapSlice {
+	container := yaml.MapSlice{
+		{Key: "name", Value: s.Name},
+		{Key: "image", Value: s.Image},
+	}
+	if len(s.Ports) > 0 {
+		var ports []yaml.MapSlice
+		for _, port := range s.Ports {
+			ports = append(ports, yaml.MapSlice{
+				{Key: "containerPort", Value: port.Port},
+				{Key: "protocol", Value: protocol(port)},
+			})
+		}
+		container = append(container, yaml.MapItem{Key: "ports", Value: ports})
+	}
+	if len(s.Env) > 0 {
+		var env []yaml.MapSlice
+		for _, name := range s.EnvNames() {
+			value := s.Env[name]
+			if value == "" {
+				env = append(env, yaml.MapSlice{{Key: "name", Value: name}, {Key: "valueFrom", Value: yaml.MapSlice{
+					{Key: "secretKeyRef", Value: yaml.MapSlice{{Key: "name", Value: secretName(s)}, {Key: "key", Value: name}}},
+				}}})
+				continue
+			}
+			env = append(env, yaml.MapSlice{{Key: "name", Value: name}, {Key: "value", Value: value}})
+		}
+		container = append(container, yaml.MapItem{Key: "env", Value: env})
+	}
+
+	pod := yaml.MapSlice{{Key: "containers", Value: []yaml.MapSlice{container}}}
+	if len(s.Volumes) > 0 {
+		var mounts, volumes []yaml.MapSlice
+		for _, v := range s.Volumes {
+			mounts = append(mounts, yaml.MapSlice{{Key: "name", Value: v.Name}, {Key: "mountPath", Value: v.Path}})
+			volumes = append(volumes, yaml.MapSlice{
+				{Key: "name", Value: v.Name},
+				{Key: "persistentVolumeClaim", Value: yaml.MapSlice{{Key: "claimName", Value: v.Name}}},
+			})
+		}
+		container = append(container, yaml.MapItem{Key: "volumeMounts", Value: mounts})
+		pod = yaml.MapSlice{
+			{Key: "containers", Value: []yaml.MapSlice{container}},
+			{Key: "volumes", Value: volumes},
+		}
+	}
+
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "apps/v1"},
+		{Key: "kind", Value: "Deployment"},
+		{Key: "metadata", Value: metadata(project, s.Name)},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "replicas", Value: 1},
+			{Key: "selector", Value: yaml.MapSlice{{Key: "matchLabels", Value: labels(project, s.Name)}}},
+			{Key: "template", Value: yaml.MapSlice{
+				{Key: "metadata", Value: yaml.MapSlice{{Key: "labels", Value: labels(project, s.Name)}}},
+				{Key: "spec", Value: pod},
+			}},
+		}},
+	}
+}
+
+// secretNames returns the sorted names of the variables of s without a
+// value, kept in its Secret.
+func secretNames(s *Service) []string {
+	var names []string
+	for _, name := range s.EnvNames() {
+		if s.Env[name] == "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func secretName(s *Service) string {
+	return s.Name + "-secrets"
+}
+
+func secret(project string, s *Service, names []string) yaml.MapSlice {
+	var data yaml.MapSlice
+	for _, name := range names {
+		data = append(data, yaml.MapItem{Key: name, Value: Placeholder})
+	}
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Secret"},
+		{Key: "metadata", Value: metadata(project, secretName(s))},
+		{Key: "type", Value: "Opaque"},
+		{Key: "stringData", Value: data},
+	}
+}
+
+func service(project string, s *Service) yaml.MapSlice {
+	var ports []yaml.MapSlice
+	for _, port := range s.Ports {
+		ports = append(ports, yaml.MapSlice{
+			{Key: "name", Value: strings.ToLower(protocol(port)) + "-" + strconv.Itoa(port.Port)},
+			{Key: "port", Value: port.Port},
+			{Key: "targetPort", Value: port.Port},
+			{Key: "protocol", Value: protocol(port)},
+		})
+	}
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Service"},
+		{Key: "metadata", Value: metadata(project, s.Name)},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "selector", Value: labels(project, s.Name)},
+			{Key: "ports", Value: ports},
+		}},
+	}
+}
+
+func persistentVolumeClaim(project string, v Volume) yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "PersistentVolumeClaim"},
+		{Key: "metadata", Value: metadata(project, v.Name)},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "accessModes", Value: []string{"ReadWriteOnce"}},
+			{Key: "resources", Value: yaml.MapSlice{
+				{Key: "requests", Value: yaml.MapSlice{{Key: "storage", Value: DefaultStorage}}},
+			}},
+		}},
+	}
+}
+
+func protocol(port Port) string {
+	if port.Protocol == "" {
+		return "TCP"
+	}
+	return strings.ToUpper(port.Protocol)
+}
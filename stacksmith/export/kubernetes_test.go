@@ -0,0 +1,11 @@
+package export
+
+import "testing"
+
+func TestKubernetes(t *testing.T) {
+	got, err := Kubernetes(testStack(t), &Options{Image: "registry.example.com/rails:1.0"})
+	if err != nil {
+		t.Fatalf("Kubernetes returned error: %v", err)
+	}
+	testGolden(t, "kubernetes.yaml", got)
+}
@@ -0,0 +1,63 @@
+package export
+
+func init() {
+	web := []Port{{Port: 80}, {Port: 443}}
+	for id, t := range map[string]Template{
+		// Services in a container of their own.
+		"mysql": {
+			Ports:   []Port{{Port: 3306}},
+			Volumes: []Volume{{Name: "mysql-data", Path: "/bitnami/mysql"}},
+			Env:     map[string]string{"MYSQL_ROOT_PASSWORD": ""},
+		},
+		"mariadb": {
+			Ports:   []Port{{Port: 3306}},
+			Volumes: []Volume{{Name: "mariadb-data", Path: "/bitnami/mariadb"}},
+			Env:     map[string]string{"MARIADB_ROOT_PASSWORD": ""},
+		},
+		"postgresql": {
+			Ports:   []Port{{Port: 5432}},
+			Volumes: []Volume{{Name: "postgresql-data", Path: "/bitnami/postgresql"}},
+			Env:     map[string]string{"POSTGRESQL_PASSWORD": ""},
+		},
+		"mongodb": {
+			Ports:   []Port{{Port: 27017}},
+			Volumes: []Volume{{Name: "mongodb-data", Path: "/bitnami/mongodb"}},
+			Env:     map[string]string{"MONGODB_ROOT_PASSWORD": ""},
+		},
+		"redis": {
+			Ports:   []Port{{Port: 6379}},
+			Volumes: []Volume{{Name: "redis-data", Path: "/bitnami/redis"}},
+			Env:     map[string]string{"REDIS_PASSWORD": ""},
+		},
+		"memcached": {Ports: []Port{{Port: 11211}}},
+		"rabbitmq": {
+			Ports:   []Port{{Port: 5672}, {Port: 15672}},
+			Volumes: []Volume{{Name: "rabbitmq-data", Path: "/bitnami/rabbitmq"}},
+			Env:     map[string]string{"RABBITMQ_PASSWORD": ""},
+		},
+		"elasticsearch": {
+			Ports:   []Port{{Port: 9200}, {Port: 9300}},
+			Volumes: []Volume{{Name: "elasticsearch-data", Path: "/bitnami/elasticsearch"}},
+		},
+
+		// Servers running inside the application container.
+		"apache": {Ports: web, InApp: true},
+		"nginx":  {Ports: web, InApp: true},
+		"tomcat": {Ports: []Port{{Port: 8080}}, InApp: true},
+		"jetty":  {Ports: []Port{{Port: 8080}}, InApp: true},
+
+		// Runtimes and frameworks.
+		"ruby":    {Ports: []Port{{Port: 3000}}},
+		"rails":   {Ports: []Port{{Port: 3000}}, Env: map[string]string{"RAILS_ENV": "production", "SECRET_KEY_BASE": ""}},
+		"node":    {Ports: []Port{{Port: 3000}}, Env: map[string]string{"NODE_ENV": "production"}},
+		"express": {Ports: []Port{{Port: 3000}}, Env: map[string]string{"NODE_ENV": "production"}},
+		"python":  {Ports: []Port{{Port: 8000}}},
+		"django":  {Ports: []Port{{Port: 8000}}, Env: map[string]string{"DJANGO_SECRET_KEY": ""}},
+		"php":     {Ports: []Port{{Port: 9000}}},
+		"laravel": {Ports: []Port{{Port: 3000}}, Env: map[string]string{"APP_KEY": ""}},
+		"java":    {Ports: []Port{{Port: 8080}}},
+		"go":      {Ports: []Port{{Port: 8080}}},
+	} {
+		Register(id, t)
+	}
+}
@@ -1,6 +1,7 @@
 package stacksmith
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -20,12 +21,15 @@ func newHooksService(sling *sling.Sling) *HooksService {
 
 // HooksList ...
 type HooksList struct {
-	TotalEntries int `json:"total_entries"`
-	TotalPages   int `json:"total_pages"`
-	Items        []struct {
-		ID  string `json:"id"`
-		URL string `json:"url"`
-	} `json:"items"`
+	TotalEntries int    `json:"total_entries"`
+	TotalPages   int    `json:"total_pages"`
+	Items        []Hook `json:"items"`
+}
+
+// Hook ...
+type Hook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
 }
 
 // TestHook ...
@@ -58,41 +62,77 @@ type HookParams struct {
 // List List all hooks for this stack.
 // https://stacksmith.bitnami.com/api/v1/#!/Stack_Hooks/get_stacks_stack_id_hooks
 func (s *HooksService) List(stackID string, params *PaginationParams) (*HooksList, *http.Response, error) {
+	return s.ListContext(context.Background(), stackID, params)
+}
+
+// ListContext List all hooks for this stack, aborting the request once ctx is done.
+func (s *HooksService) ListContext(ctx context.Context, stackID string, params *PaginationParams) (*HooksList, *http.Response, error) {
 	hooksList := new(HooksList)
-	apiError := new(APIError)
 	path := fmt.Sprintf("%s/hooks", stackID)
-	resp, err := s.sling.New().Get(path).QueryStruct(params).Receive(hooksList, apiError)
-	return hooksList, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Get(path).QueryStruct(params), hooksList)
+	return hooksList, resp, err
+}
+
+// ListAll List all hooks for this stack, following every page.
+func (s *HooksService) ListAll(stackID string) ([]Hook, *http.Response, error) {
+	return s.ListAllContext(context.Background(), stackID)
+}
+
+// ListAllContext List all hooks for this stack, following every page until ctx is done.
+func (s *HooksService) ListAllContext(ctx context.Context, stackID string) ([]Hook, *http.Response, error) {
+	var hooks []Hook
+	for page := 1; ; page++ {
+		hooksList, resp, err := s.ListContext(ctx, stackID, &PaginationParams{Page: page, PerPage: MaxPerPage})
+		if err != nil {
+			return hooks, resp, err
+		}
+		hooks = append(hooks, hooksList.Items...)
+		if page >= hooksList.TotalPages || len(hooksList.Items) == 0 {
+			return hooks, resp, nil
+		}
+	}
 }
 
 // Register Register a URL as a hook that will be triggered when there are updates for your stacks.
 // https://stacksmith.bitnami.com/api/v1/#!/Stack_Hooks/post_stacks_stack_id_hooks
 func (s *HooksService) Register(stackID string, params *HookParams) (*ResponseGeneration, *http.Response, error) {
+	return s.RegisterContext(context.Background(), stackID, params)
+}
+
+// RegisterContext Register a URL as a hook, aborting the request once ctx is done.
+func (s *HooksService) RegisterContext(ctx context.Context, stackID string, params *HookParams) (*ResponseGeneration, *http.Response, error) {
 	status := new(ResponseGeneration)
-	apiError := new(APIError)
 	path := fmt.Sprintf("%s/hooks", stackID)
-	resp, err := s.sling.New().Post(path).BodyJSON(params).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Post(path).BodyJSON(params), status)
+	return status, resp, err
 }
 
 // Delete Delete a hook
 // https://stacksmith.bitnami.com/api/v1/#!/Stack_Hooks/delete_stacks_stack_id_hooks_id
 func (s *HooksService) Delete(stackID string, hookID string) (*StatusDeletion, *http.Response, error) {
+	return s.DeleteContext(context.Background(), stackID, hookID)
+}
+
+// DeleteContext Delete a hook, aborting the request once ctx is done.
+func (s *HooksService) DeleteContext(ctx context.Context, stackID string, hookID string) (*StatusDeletion, *http.Response, error) {
 	status := new(StatusDeletion)
-	apiError := new(APIError)
 	path := fmt.Sprintf("%s/hooks/%s", stackID, hookID)
-	resp, err := s.sling.New().Delete(path).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Delete(path), status)
+	return status, resp, err
 }
 
 // Update Update the URL for a previously registered hook.
 // https://stacksmith.bitnami.com/api/v1/#!/Stack_Hooks/patch_stacks_stack_id_hooks_id
 func (s *HooksService) Update(stackID string, hookID string, params *HookParams) (*ResponseGeneration, *http.Response, error) {
+	return s.UpdateContext(context.Background(), stackID, hookID, params)
+}
+
+// UpdateContext Update the URL for a previously registered hook, aborting the request once ctx is done.
+func (s *HooksService) UpdateContext(ctx context.Context, stackID string, hookID string, params *HookParams) (*ResponseGeneration, *http.Response, error) {
 	status := new(ResponseGeneration)
-	apiError := new(APIError)
 	path := fmt.Sprintf("%s/hooks/%s", stackID, hookID)
-	resp, err := s.sling.New().Patch(path).BodyJSON(params).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Patch(path).BodyJSON(params), status)
+	return status, resp, err
 }
 
 // Test Send a test payload to the URL endpoint.
@@ -0,0 +1,62 @@
+package stacksmith
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHooksService_ListAll(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/stacks/stack1/hooks", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"per_page": "100", "api_key": "my_api_key"})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"total_entries":2,"total_pages":2,"items":[{"id":"h%s","url":"https://example.com/%s"}]}`,
+			r.FormValue("page"), r.FormValue("page"))
+	})
+
+	hooksRecieved, _, err := client.Hooks.ListAll("stack1")
+	if err != nil {
+		t.Errorf("Hooks.ListAll returned error: %v", err.Error())
+	}
+
+	hooksExpected := []Hook{{ID: "h1", URL: "https://example.com/1"}, {ID: "h2", URL: "https://example.com/2"}}
+	if !reflect.DeepEqual(hooksRecieved, hooksExpected) {
+		t.Errorf("Hooks.ListAll returned %+v, want %+v", hooksRecieved, hooksExpected)
+	}
+}
+
+func TestHooksService_Context(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/stacks/stack1/hooks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_entries":1,"total_pages":1,"items":[{"id":"h1","url":"https://example.com/1"}]}`)
+	})
+
+	hooks, _, err := client.Hooks.ListAllContext(context.Background(), "stack1")
+	if err != nil || len(hooks) != 1 {
+		t.Errorf("Hooks.ListAllContext returned %+v, %v", hooks, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Hooks.ListAllContext(ctx, "stack1"); err == nil {
+		t.Errorf("Hooks.ListAllContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Hooks.RegisterContext(ctx, "stack1", &HookParams{URL: "https://example.com/2"}); err == nil {
+		t.Errorf("Hooks.RegisterContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Hooks.UpdateContext(ctx, "stack1", "h1", &HookParams{URL: "https://example.com/2"}); err == nil {
+		t.Errorf("Hooks.UpdateContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Hooks.DeleteContext(ctx, "stack1", "h1"); err == nil {
+		t.Errorf("Hooks.DeleteContext with a canceled context returned no error")
+	}
+}
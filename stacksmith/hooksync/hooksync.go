@@ -0,0 +1,306 @@
+// Package hooksync makes the hooks registered on stacks match a desired set
+// of hook URLs.
+package hooksync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// Desired maps stack IDs to the hook URLs they should have. A stack mapped to
+// no URL loses all its hooks; stacks missing from the map are left alone.
+type Desired map[string][]string
+
+// Filter selects stacks.
+type Filter func(stacksmith.StackItem) bool
+
+// NameMatches returns a Filter selecting the stacks whose name matches any
+// of the shell patterns, as understood by path.Match.
+func NameMatches(patterns ...string) Filter {
+	return func(stack stacksmith.StackItem) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, stack.Name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ActionType is the kind of change an Action makes.
+type ActionType string
+
+// Action types.
+const (
+	Register ActionType = "register"
+	Update   ActionType = "update"
+	Delete   ActionType = "delete"
+)
+
+// Action is one change to the hooks of a stack.
+type Action struct {
+	Type    ActionType `json:"type"`
+	StackID string     `json:"stack_id"`
+	HookID  string     `json:"hook_id,omitempty"`
+	URL     string     `json:"url,omitempty"`
+	OldURL  string     `json:"old_url,omitempty"`
+}
+
+func (a Action) String() string {
+	switch a.Type {
+	case Register:
+		return fmt.Sprintf("+ %s", a.URL)
+	case Update:
+		return fmt.Sprintf("~ %s -> %s (hook %s)", a.OldURL, a.URL, a.HookID)
+	default:
+		return fmt.Sprintf("- %s (hook %s)", a.OldURL, a.HookID)
+	}
+}
+
+// Plan holds the actions needed to reach a Desired state.
+type Plan struct {
+	Desired Desired
+	// Actions are grouped by stack, in the order they are applied.
+	Actions []Action
+	// Errors holds the stacks whose hooks could not be listed.
+	Errors map[string]error
+}
+
+// Empty reports whether the plan has nothing to do.
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0 && len(p.Errors) == 0
+}
+
+// String renders the plan as a diff, for dry runs.
+func (p *Plan) String() string {
+	buf := new(bytes.Buffer)
+	current := ""
+	for _, a := range p.Actions {
+		if a.StackID != current {
+			current = a.StackID
+			fmt.Fprintf(buf, "stack %s:\n", current)
+		}
+		fmt.Fprintf(buf, "  %s\n", a)
+	}
+	for _, stackID := range erroredStacks(p.Errors) {
+		fmt.Fprintf(buf, "stack %s:\n  ! %v\n", stackID, p.Errors[stackID])
+	}
+	return buf.String()
+}
+
+// StackResult reports what Apply did on one stack.
+type StackResult struct {
+	StackID string
+	Applied []Action
+	// Skipped holds the actions found to be already done.
+	Skipped []Action
+	// Err stops the stack at the first failure; other stacks go on.
+	Err error
+}
+
+// Syncer plans and applies hook changes through a Client.
+type Syncer struct {
+	client *stacksmith.Client
+}
+
+// New returns a Syncer using client.
+func New(client *stacksmith.Client) *Syncer {
+	return &Syncer{client: client}
+}
+
+// ForStacks returns the Desired state giving urls to every stack matched by filter.
+func (s *Syncer) ForStacks(filter Filter, urls ...string) (Desired, error) {
+	stacks, _, err := s.client.Stacks.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	desired := make(Desired)
+	for _, stack := range stacks {
+		if filter == nil || filter(stack) {
+			desired[stack.ID] = urls
+		}
+	}
+	return desired, nil
+}
+
+// Plan compares desired with the hooks currently registered.
+func (s *Syncer) Plan(ctx context.Context, desired Desired) (*Plan, error) {
+	plan := &Plan{Desired: desired, Errors: make(map[string]error)}
+	for _, stackID := range stackIDs(desired) {
+		if err := ctx.Err(); err != nil {
+			return plan, err
+		}
+		hooks, _, err := s.client.Hooks.ListAllContext(ctx, stackID)
+		if err != nil {
+			plan.Errors[stackID] = err
+			continue
+		}
+		plan.Actions = append(plan.Actions, diff(stackID, hooks, desired[stackID])...)
+	}
+	return plan, nil
+}
+
+// Apply carries out the plan. Each stack is listed again first, so actions
+// already done are skipped and applying the same plan twice is harmless. An
+// update of a hook deleted in the meantime registers its URL instead, and is
+// reported as a Register action.
+func (s *Syncer) Apply(ctx context.Context, plan *Plan) ([]StackResult, error) {
+	var results []StackResult
+	for _, stackID := range planStacks(plan) {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		results = append(results, s.applyStack(ctx, stackID, plan))
+	}
+	return results, nil
+}
+
+func (s *Syncer) applyStack(ctx context.Context, stackID string, plan *Plan) StackResult {
+	result := StackResult{StackID: stackID}
+	if err, ok := plan.Errors[stackID]; ok {
+		result.Err = err
+		return result
+	}
+
+	hooks, _, err := s.client.Hooks.ListAllContext(ctx, stackID)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	byID := make(map[string]string)
+	byURL := make(map[string]bool)
+	for _, hook := range hooks {
+		byID[hook.ID] = hook.URL
+		byURL[hook.URL] = true
+	}
+
+	for _, a := range plan.Actions {
+		if a.StackID != stackID {
+			continue
+		}
+		switch a.Type {
+		case Register:
+			if byURL[a.URL] {
+				result.Skipped = append(result.Skipped, a)
+				continue
+			}
+			_, _, err = s.client.Hooks.RegisterContext(ctx, stackID, &stacksmith.HookParams{URL: a.URL})
+		case Update:
+			url, ok := byID[a.HookID]
+			if ok && url == a.URL || !ok && byURL[a.URL] {
+				result.Skipped = append(result.Skipped, a)
+				continue
+			}
+			if !ok {
+				// The hook was deleted since the plan: register its new URL
+				// instead.
+				a = Action{Type: Register, StackID: stackID, URL: a.URL}
+				_, _, err = s.client.Hooks.RegisterContext(ctx, stackID, &stacksmith.HookParams{URL: a.URL})
+				break
+			}
+			_, _, err = s.client.Hooks.UpdateContext(ctx, stackID, a.HookID, &stacksmith.HookParams{URL: a.URL})
+		case Delete:
+			if _, ok := byID[a.HookID]; !ok {
+				result.Skipped = append(result.Skipped, a)
+				continue
+			}
+			_, _, err = s.client.Hooks.DeleteContext(ctx, stackID, a.HookID)
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("%s: %v", a, err)
+			return result
+		}
+		if a.URL != "" {
+			byURL[a.URL] = true
+		}
+		result.Applied = append(result.Applied, a)
+	}
+	return result
+}
+
+// diff returns the actions turning hooks into urls. Hooks no longer wanted
+// are updated to the URLs not yet registered before any is deleted, so that
+// rotating an endpoint keeps the hook IDs.
+func diff(stackID string, hooks []stacksmith.Hook, urls []string) []Action {
+	wanted := make(map[string]bool)
+	for _, url := range urls {
+		wanted[url] = true
+	}
+
+	kept := make(map[string]bool)
+	var extra []stacksmith.Hook
+	for _, hook := range hooks {
+		if wanted[hook.URL] && !kept[hook.URL] {
+			kept[hook.URL] = true
+			continue
+		}
+		extra = append(extra, hook)
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		if extra[i].URL != extra[j].URL {
+			return extra[i].URL < extra[j].URL
+		}
+		return extra[i].ID < extra[j].ID
+	})
+
+	var missing []string
+	for url := range wanted {
+		if !kept[url] {
+			missing = append(missing, url)
+		}
+	}
+	sort.Strings(missing)
+
+	var actions []Action
+	for len(extra) > 0 && len(missing) > 0 {
+		actions = append(actions, Action{Type: Update, StackID: stackID, HookID: extra[0].ID, URL: missing[0], OldURL: extra[0].URL})
+		extra, missing = extra[1:], missing[1:]
+	}
+	for _, url := range missing {
+		actions = append(actions, Action{Type: Register, StackID: stackID, URL: url})
+	}
+	for _, hook := range extra {
+		actions = append(actions, Action{Type: Delete, StackID: stackID, HookID: hook.ID, OldURL: hook.URL})
+	}
+	return actions
+}
+
+func planStacks(plan *Plan) []string {
+	seen := make(map[string]bool)
+	var stacks []string
+	for _, a := range plan.Actions {
+		if !seen[a.StackID] {
+			seen[a.StackID] = true
+			stacks = append(stacks, a.StackID)
+		}
+	}
+	for _, stackID := range erroredStacks(plan.Errors) {
+		if !seen[stackID] {
+			stacks = append(stacks, stackID)
+		}
+	}
+	return stacks
+}
+
+func stackIDs(desired Desired) []string {
+	var keys []string
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func erroredStacks(errs map[string]error) []string {
+	var keys []string
+	for k := range errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+package hooksync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// fakeHooks serves the Stack Hooks endpoints from memory.
+type fakeHooks struct {
+	mu     sync.Mutex
+	hooks  map[string][]stacksmith.Hook
+	nextID int
+	calls  []string
+}
+
+func (f *fakeHooks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/api/v1/stacks/" {
+		w.Write(utils.GetJSON("list_stacks"))
+		return
+	}
+
+	// /api/v1/stacks/{stack}/hooks[/{hook}]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/stacks/"), "/")
+	stackID := parts[0]
+	if stackID == "broken" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":"404","error":"stack not found"}`))
+		return
+	}
+	params := new(stacksmith.HookParams)
+	body, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(body, params)
+
+	switch {
+	case r.Method == "GET":
+		json.NewEncoder(w).Encode(stacksmith.HooksList{TotalEntries: len(f.hooks[stackID]), TotalPages: 1, Items: f.hooks[stackID]})
+		return
+	case r.Method == "POST":
+		f.nextID++
+		hook := stacksmith.Hook{ID: fmt.Sprintf("new%d", f.nextID), URL: params.URL}
+		f.hooks[stackID] = append(f.hooks[stackID], hook)
+		f.calls = append(f.calls, "POST "+stackID)
+	case r.Method == "PATCH":
+		for i, hook := range f.hooks[stackID] {
+			if hook.ID == parts[2] {
+				f.hooks[stackID][i].URL = params.URL
+			}
+		}
+		f.calls = append(f.calls, "PATCH "+stackID+"/"+parts[2])
+	case r.Method == "DELETE":
+		var kept []stacksmith.Hook
+		for _, hook := range f.hooks[stackID] {
+			if hook.ID != parts[2] {
+				kept = append(kept, hook)
+			}
+		}
+		f.hooks[stackID] = kept
+		f.calls = append(f.calls, "DELETE "+stackID+"/"+parts[2])
+	}
+	w.Write([]byte(`{}`))
+}
+
+func setup(hooks map[string][]stacksmith.Hook) (*Syncer, *fakeHooks, func()) {
+	fake := &fakeHooks{hooks: hooks}
+	server := httptest.NewServer(fake)
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+	return New(client), fake, server.Close
+}
+
+func TestSyncer_PlanAndApply(t *testing.T) {
+	syncer, fake, teardown := setup(map[string][]stacksmith.Hook{
+		"s1": {{ID: "h1", URL: "https://old.example.com"}, {ID: "h2", URL: "https://keep.example.com"}},
+		"s2": {{ID: "h3", URL: "https://gone.example.com"}},
+	})
+	defer teardown()
+
+	desired := Desired{
+		"s1":     {"https://keep.example.com", "https://new.example.com"},
+		"s2":     {},
+		"s3":     {"https://new.example.com"},
+		"broken": {"https://new.example.com"},
+	}
+
+	plan, err := syncer.Plan(context.Background(), desired)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	actionsExpected := []Action{
+		{Type: Update, StackID: "s1", HookID: "h1", URL: "https://new.example.com", OldURL: "https://old.example.com"},
+		{Type: Delete, StackID: "s2", HookID: "h3", OldURL: "https://gone.example.com"},
+		{Type: Register, StackID: "s3", URL: "https://new.example.com"},
+	}
+	if !reflect.DeepEqual(plan.Actions, actionsExpected) {
+		t.Errorf("Plan returned %+v, want %+v", plan.Actions, actionsExpected)
+	}
+	if _, ok := plan.Errors["broken"]; !ok {
+		t.Errorf("Plan did not report the error on stack broken")
+	}
+
+	diffExpected := `stack s1:
+  ~ https://old.example.com -> https://new.example.com (hook h1)
+stack s2:
+  - https://gone.example.com (hook h3)
+stack s3:
+  + https://new.example.com
+stack broken:
+  ! stacksmith: 404 stack not found
+`
+	if plan.String() != diffExpected {
+		t.Errorf("Plan.String returned\n%s\nwant\n%s", plan.String(), diffExpected)
+	}
+
+	results, err := syncer.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if len(results) != 4 || results[3].StackID != "broken" || results[3].Err == nil {
+		t.Errorf("Apply returned %+v", results)
+	}
+	callsExpected := []string{"PATCH s1/h1", "DELETE s2/h3", "POST s3"}
+	if !reflect.DeepEqual(fake.calls, callsExpected) {
+		t.Errorf("Apply made calls %v, want %v", fake.calls, callsExpected)
+	}
+
+	// Applying the same plan again finds everything done.
+	results, _ = syncer.Apply(context.Background(), plan)
+	for _, result := range results[:3] {
+		if len(result.Applied) != 0 || len(result.Skipped) != 1 {
+			t.Errorf("second Apply on %s applied %+v", result.StackID, result.Applied)
+		}
+	}
+	if len(fake.calls) != len(callsExpected) {
+		t.Errorf("second Apply made calls %v", fake.calls[len(callsExpected):])
+	}
+
+	delete(desired, "broken")
+	plan, _ = syncer.Plan(context.Background(), desired)
+	if !plan.Empty() {
+		t.Errorf("Plan after Apply returned %+v, want an empty plan", plan)
+	}
+}
+
+func TestSyncer_ApplyDeletedHook(t *testing.T) {
+	syncer, fake, teardown := setup(map[string][]stacksmith.Hook{
+		"s1": {{ID: "h1", URL: "https://old.example.com"}},
+	})
+	defer teardown()
+
+	plan, _ := syncer.Plan(context.Background(), Desired{"s1": {"https://new.example.com"}})
+	fake.hooks["s1"] = nil
+
+	results, err := syncer.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	appliedExpected := []Action{{Type: Register, StackID: "s1", URL: "https://new.example.com"}}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Applied, appliedExpected) || len(results[0].Skipped) != 0 {
+		t.Errorf("Apply returned %+v, want %+v applied", results, appliedExpected)
+	}
+	if !reflect.DeepEqual(fake.calls, []string{"POST s1"}) {
+		t.Errorf("Apply made calls %v, want [POST s1]", fake.calls)
+	}
+
+	// The URL is now registered, so applying again skips the update.
+	results, _ = syncer.Apply(context.Background(), plan)
+	if len(results[0].Applied) != 0 || len(results[0].Skipped) != 1 || len(fake.calls) != 1 {
+		t.Errorf("second Apply returned %+v and made calls %v", results, fake.calls)
+	}
+}
+
+func TestSyncer_ApplyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			w.Write([]byte(`{"total_entries":0,"total_pages":1,"items":[]}`))
+			return
+		}
+		// Cancel while the registration is in flight. The body is read so
+		// that the server notices the client going away.
+		ioutil.ReadAll(r.Body)
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	syncer := New(stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)))
+
+	plan := &Plan{Actions: []Action{{Type: Register, StackID: "s1", URL: "https://new.example.com"}}}
+	results, _ := syncer.Apply(ctx, plan)
+	if len(results) != 1 || results[0].Err == nil || len(results[0].Applied) != 0 {
+		t.Errorf("Apply canceled during a registration returned %+v", results)
+	}
+}
+
+func TestSyncer_ForStacks(t *testing.T) {
+	syncer, _, teardown := setup(nil)
+	defer teardown()
+
+	desired, err := syncer.ForStacks(NameMatches("Debian*", "Ubuntu*"), "https://new.example.com")
+	if err != nil {
+		t.Fatalf("ForStacks returned error: %v", err)
+	}
+	if urls, ok := desired["0z9nqu8"]; !ok || !reflect.DeepEqual(urls, []string{"https://new.example.com"}) {
+		t.Errorf("ForStacks returned %+v, want stack 0z9nqu8 included", desired)
+	}
+	if _, ok := desired["nw5bkf3"]; ok {
+		t.Errorf("ForStacks included stack nw5bkf3, named Node.js 6.4.0 on Debian")
+	}
+}
@@ -0,0 +1,41 @@
+// Package parallel runs calls a bounded number at a time, for the packages
+// fanning requests out over many stacks or components.
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn with 0 to n-1, at most concurrency calls at a time, and
+// stops starting calls once ctx is done. It returns once the calls started
+// have returned, with the error of ctx.
+func ForEach(ctx context.Context, n, concurrency int, fn func(i int)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,56 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	running, most := 0, 0
+	err := ForEach(context.Background(), 10, 3, func(i int) {
+		mu.Lock()
+		seen[i] = true
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Errorf("ForEach returned error: %v", err)
+	}
+	if len(seen) != 10 {
+		t.Errorf("ForEach called %d indexes, want 10", len(seen))
+	}
+	if most > 3 {
+		t.Errorf("ForEach ran %d calls at a time, want at most 3", most)
+	}
+}
+
+func TestForEach_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var called []int
+	err := ForEach(ctx, 5, 1, func(i int) {
+		mu.Lock()
+		called = append(called, i)
+		mu.Unlock()
+		if i == 1 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("ForEach returned %v, want %v", err, context.Canceled)
+	}
+	if len(called) != 2 {
+		t.Errorf("ForEach called %v after the cancellation at 1", called)
+	}
+}
@@ -0,0 +1,216 @@
+// Package junit reports checks over stacks as JUnit XML, which CI systems
+// render natively.
+//
+// Each stack is a test suite and each of its components, the OS included, a
+// test case. A case fails when its component is outdated or affected by a
+// vulnerability, and the failure lists why.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+)
+
+// Failure types.
+const (
+	TypeVulnerable = "vulnerable"
+	TypeOutdated   = "outdated"
+)
+
+// Report is the <testsuites> document.
+type Report struct {
+	XMLName  xml.Name `xml:"testsuites"`
+	Name     string   `xml:"name,attr"`
+	Tests    int      `xml:"tests,attr"`
+	Failures int      `xml:"failures,attr"`
+	Errors   int      `xml:"errors,attr"`
+	Suites   []Suite  `xml:"testsuite"`
+}
+
+// Suite holds the cases of one stack.
+type Suite struct {
+	Name       string     `xml:"name,attr"`
+	ID         string     `xml:"id,attr,omitempty"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Errors     int        `xml:"errors,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Time       string     `xml:"time,attr"`
+	Timestamp  string     `xml:"timestamp,attr,omitempty"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Cases      []Case     `xml:"testcase"`
+}
+
+// Property describes the stack of a suite.
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Case is the check of one component.
+type Case struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+}
+
+// Failure tells why a component fails.
+type Failure struct {
+	Message string `xml:"message,attr"`
+	// Type is TypeVulnerable, TypeOutdated or both, comma separated.
+	Type string `xml:"type,attr"`
+	Text string `xml:",cdata"`
+}
+
+// Options tunes NewSuite.
+type Options struct {
+	// MinSeverity is the least severe vulnerability failing a component.
+	// Empty means any.
+	MinSeverity string
+	// IgnoreOutdated does not fail outdated components.
+	IgnoreOutdated bool
+}
+
+// Validate checks the severity of o.
+func (o *Options) Validate() error {
+	if o.MinSeverity != "" && !vulns.KnownSeverity(o.MinSeverity) {
+		return fmt.Errorf("junit: unknown severity %q", o.MinSeverity)
+	}
+	return nil
+}
+
+// New returns the report of suites.
+func New(suites ...Suite) *Report {
+	r := &Report{Name: "stacksmith", Suites: suites}
+	for _, s := range suites {
+		r.Tests += s.Tests
+		r.Failures += s.Failures
+		r.Errors += s.Errors
+	}
+	return r
+}
+
+// Failed tells whether any case fails.
+func (r *Report) Failed() bool {
+	return r.Failures+r.Errors > 0
+}
+
+// NewSuite checks the components of stack. vulnerabilities, as returned by
+// Stacks.GetAllVulnerabilities, add to those attached to the components
+// when their ranges affect the component version, as merged by vulns.Merge.
+func NewSuite(stack *stacksmith.Stack, vulnerabilities []stacksmith.VulnerabilityItem, opts *Options) Suite {
+	if opts == nil {
+		opts = new(Options)
+	}
+	s := Suite{Name: stack.Name, ID: stack.ID, Time: "0"}
+	if s.Name == "" {
+		s.Name = stack.ID
+	}
+	for _, p := range []Property{
+		{Name: "stack.id", Value: stack.ID},
+		{Name: "stack.status", Value: stack.Status},
+		{Name: "stack.flavor", Value: stack.Flavor.ID},
+	} {
+		if p.Value != "" {
+			s.Properties = append(s.Properties, p)
+		}
+	}
+	for _, date := range []string{stack.RegeneratedAt, stack.GeneratedAt} {
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			s.Timestamp = t.UTC().Format("2006-01-02T15:04:05")
+			break
+		}
+	}
+
+	byComponent := make(map[string][]stacksmith.VulnerabilityItem)
+	for _, e := range vulns.Merge(stack, vulnerabilities) {
+		byComponent[e.Component] = append(byComponent[e.Component], e.Item)
+	}
+
+	components := append([]stacksmith.Component(nil), stack.Components...)
+	sort.SliceStable(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+	if stack.Os.ID != "" {
+		components = append([]stacksmith.Component{stack.Os}, components...)
+	}
+	for _, c := range components {
+		tc := newCase(stack, c, byComponent[c.ID], opts)
+		s.Cases = append(s.Cases, tc)
+		s.Tests++
+		if tc.Failure != nil {
+			s.Failures++
+		}
+	}
+	return s
+}
+
+func newCase(stack *stacksmith.Stack, c stacksmith.Component, items []stacksmith.VulnerabilityItem, opts *Options) Case {
+	current := version.Format(c.Version, c.Revision)
+	tc := Case{Name: c.ID + " " + current, ClassName: "stacksmith." + stack.ID, Time: "0"}
+
+	var types, messages, lines []string
+	if c.Outdated && !opts.IgnoreOutdated {
+		types = append(types, TypeOutdated)
+		latest := version.Format(c.Latest.Version, c.Latest.Revision)
+		messages = append(messages, "outdated, latest is "+latest)
+		lines = append(lines, fmt.Sprintf("%s %s is outdated, latest is %s", c.ID, current, latest))
+	}
+
+	var vulnerable []stacksmith.VulnerabilityItem
+	for _, v := range items {
+		if opts.MinSeverity != "" && vulns.Rank(v.Severity) < vulns.Rank(opts.MinSeverity) {
+			continue
+		}
+		vulnerable = append(vulnerable, v)
+	}
+	sort.SliceStable(vulnerable, func(i, j int) bool {
+		if ri, rj := vulns.Rank(vulnerable[i].Severity), vulns.Rank(vulnerable[j].Severity); ri != rj {
+			return ri > rj
+		}
+		return vulnerable[i].Name < vulnerable[j].Name
+	})
+	if len(vulnerable) > 0 {
+		types = append(types, TypeVulnerable)
+		word := "vulnerabilities"
+		if len(vulnerable) == 1 {
+			word = "vulnerability"
+		}
+		messages = append(messages, fmt.Sprintf("%d %s, up to %s severity", len(vulnerable), word, strings.ToLower(vulnerable[0].Severity)))
+		for _, v := range vulnerable {
+			line := fmt.Sprintf("%s (%s)", v.Name, strings.ToLower(v.Severity))
+			for _, r := range v.Ranges {
+				if r.Component == c.ID {
+					line += fmt.Sprintf(": versions %s to %s", r.From, r.To)
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if len(types) > 0 {
+		tc.Failure = &Failure{
+			Message: strings.Join(messages, "; "),
+			Type:    strings.Join(types, ","),
+			Text:    strings.Join(lines, "\n"),
+		}
+	}
+	return tc
+}
+
+// Write writes r to w as indented XML.
+func Write(w io.Writer, r *Report) error {
+	data, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
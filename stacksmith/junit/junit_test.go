@@ -0,0 +1,100 @@
+package junit
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	stack.Os.Outdated = true
+	stack.Os.Latest.Version = "jessie"
+	stack.Os.Latest.Revision = 8
+	return stack
+}
+
+func TestWrite(t *testing.T) {
+	var listed stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2016-2107","severity":"high","ranges":[{"component":"ruby","from":"2.2","to":"2.2.4:3"}]},
+		{"name":"CVE-2016-9999","severity":"critical","ranges":[{"component":"ruby","from":"2.2","to":"2.2.5"}]},
+		{"name":"CVE-2015-7551","severity":"critical","ranges":[{"component":"ruby","from":"2.0","to":"2.2.3:2"}]},
+		{"name":"CVE-2016-4450","severity":"high","ranges":[{"component":"nginx","from":"1.10","to":"1.10.0"}]}]}`), &listed)
+
+	clean := &stacksmith.Stack{ID: "clean", Name: "Clean stack", Components: []stacksmith.Component{{ID: "nginx", Version: "1.10.1", Revision: 0}}}
+	var buf bytes.Buffer
+	report := New(NewSuite(testStack(t), listed.Items, nil), NewSuite(clean, listed.Items, nil))
+	if err := Write(&buf, report); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	path := filepath.Join("testdata", "report.xml")
+	if *update {
+		ioutil.WriteFile(path, buf.Bytes(), 0644)
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Write wrote:\n%s", buf.String())
+	}
+
+	var decoded Report
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Write wrote invalid XML: %v", err)
+	}
+	if decoded.Tests != 3 || decoded.Failures != 2 || len(decoded.Suites) != 2 || !report.Failed() {
+		t.Errorf("report counts %d tests and %d failures in %d suites", decoded.Tests, decoded.Failures, len(decoded.Suites))
+	}
+}
+
+func TestNewSuite_Options(t *testing.T) {
+	var cases = []struct {
+		opts    Options
+		failure *Failure
+	}{
+		{Options{MinSeverity: "critical"}, nil},
+		{Options{MinSeverity: "high"}, &Failure{
+			Message: "2 vulnerabilities, up to high severity",
+			Type:    TypeVulnerable,
+			Text:    "CVE-2016-0800 (high): versions 2.2 to 2.2.4:3\nCVE-2016-2107 (high): versions 2.2 to 2.2.5:0",
+		}},
+	}
+	for _, c := range cases {
+		s := NewSuite(testStack(t), nil, &c.opts)
+		got := s.Cases[1].Failure
+		if (got == nil) != (c.failure == nil) || (got != nil && *got != *c.failure) {
+			t.Errorf("NewSuite(%+v) failed ruby with %+v, want %+v", c.opts, got, c.failure)
+		}
+	}
+
+	s := NewSuite(testStack(t), nil, &Options{MinSeverity: "critical", IgnoreOutdated: true})
+	if s.Failures != 0 || New(s).Failed() {
+		t.Errorf("NewSuite ignoring outdated components failed %d cases", s.Failures)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	for _, severity := range []string{"", "low", "Critical"} {
+		if err := (&Options{MinSeverity: severity}).Validate(); err != nil {
+			t.Errorf("Validate(%q) returned error: %v", severity, err)
+		}
+	}
+	if err := (&Options{MinSeverity: "hihg"}).Validate(); err == nil {
+		t.Errorf("Validate of an unknown severity returned no error")
+	}
+}
@@ -0,0 +1,119 @@
+// Package manifest keeps stacks described in YAML or JSON manifests in sync
+// with the stacks of an account.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest describes the stacks of an account.
+type Manifest struct {
+	Stacks []StackSpec `json:"stacks" yaml:"stacks"`
+}
+
+// StackSpec describes one stack. Stacks are identified by name.
+type StackSpec struct {
+	Name          string      `json:"name" yaml:"name"`
+	Components    []Component `json:"components" yaml:"components"`
+	OS            Component   `json:"os" yaml:"os"`
+	Flavor        string      `json:"flavor,omitempty" yaml:"flavor,omitempty"`
+	Notifications bool        `json:"notifications" yaml:"notifications"`
+	Shared        bool        `json:"shared" yaml:"shared"`
+	// Hooks lists the hook URLs of the stack. Leaving it out leaves the hooks
+	// alone, carrying them over to the new stack when the stack is replaced,
+	// while an empty list removes them all.
+	Hooks []string `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+// Component is a component or OS requirement. An empty version means "latest".
+type Component struct {
+	ID      string `json:"id" yaml:"id"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// Definition returns the StackDefinition creating the stack.
+func (s *StackSpec) Definition() *stacksmith.StackDefinition {
+	def := &stacksmith.StackDefinition{
+		Name:   s.Name,
+		OS:     stacksmith.ComponentItem{ID: s.OS.ID, Version: versionOrLatest(s.OS.Version)},
+		Flavor: s.Flavor,
+	}
+	for _, c := range s.Components {
+		def.Components = append(def.Components, stacksmith.ComponentItem{ID: c.ID, Version: versionOrLatest(c.Version)})
+	}
+	return def
+}
+
+// Params returns the StackParams holding the properties updated in place.
+func (s *StackSpec) Params() *stacksmith.StackParams {
+	return &stacksmith.StackParams{
+		Name:                 s.Name,
+		NotificationsEnabled: s.Notifications,
+		Shared:               s.Shared,
+	}
+}
+
+// Parse decodes a manifest. JSON documents are recognised by their leading
+// brace, anything else is read as YAML.
+func Parse(data []byte) (*Manifest, error) {
+	m := new(Manifest)
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		err = json.Unmarshal(data, m)
+	} else {
+		err = yaml.Unmarshal(data, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %v", err)
+	}
+	return m, m.Validate()
+}
+
+// Load reads and parses the manifest at path.
+func Load(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return m, nil
+}
+
+// Validate checks that every stack has a unique name, an OS and identified components.
+func (m *Manifest) Validate() error {
+	names := make(map[string]bool)
+	for i, s := range m.Stacks {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("manifest: stacks[%d] has no name", i)
+		case names[s.Name]:
+			return fmt.Errorf("manifest: stack %q is declared twice", s.Name)
+		case s.OS.ID == "":
+			return fmt.Errorf("manifest: stack %q has no os", s.Name)
+		}
+		names[s.Name] = true
+		for j, c := range s.Components {
+			if c.ID == "" {
+				return fmt.Errorf("manifest: stack %q: components[%d] has no id", s.Name, j)
+			}
+		}
+	}
+	return nil
+}
+
+func versionOrLatest(version string) string {
+	if version == "" {
+		return "latest"
+	}
+	return version
+}
@@ -0,0 +1,45 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Load("testdata/stacks.yaml")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	fromJSON, err := Parse([]byte(`{"stacks":[
+		{"name":"My ROR stack2","components":[{"id":"ruby","version":"2.2.3"}],"os":{"id":"debian"},
+		 "flavor":"rails","hooks":["https://hooks.example.com/ror"]}]}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON.Stacks[0], m.Stacks[0]) {
+		t.Errorf("Parse returned %+v, want %+v", fromJSON.Stacks[0], m.Stacks[0])
+	}
+
+	definitionExpected := &stacksmith.StackDefinition{
+		Name:       "My ROR stack2",
+		Components: []stacksmith.ComponentItem{{ID: "ruby", Version: "2.2.3"}},
+		OS:         stacksmith.ComponentItem{ID: "debian", Version: "latest"},
+		Flavor:     "rails",
+	}
+	if def := m.Stacks[0].Definition(); !reflect.DeepEqual(def, definitionExpected) {
+		t.Errorf("Definition returned %+v, want %+v", def, definitionExpected)
+	}
+
+	for _, invalid := range []string{
+		`stacks: [{os: {id: debian}}]`,
+		`stacks: [{name: a, os: {id: debian}}, {name: a, os: {id: debian}}]`,
+		`stacks: [{name: a}]`,
+		`stacks: [{name: a, os: {id: debian}, components: [{version: "1"}]}]`,
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("Parse(%q) returned no error", invalid)
+		}
+	}
+}
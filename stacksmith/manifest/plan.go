@@ -0,0 +1,349 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/hooksync"
+)
+
+// ChangeType is the kind of change made to a stack.
+type ChangeType string
+
+// Change types, in the order they are applied.
+const (
+	Create     ChangeType = "create"
+	Update     ChangeType = "update"
+	Regenerate ChangeType = "regenerate"
+	Hooks      ChangeType = "hooks"
+	Delete     ChangeType = "delete"
+)
+
+var changeOrder = map[ChangeType]int{Create: 0, Update: 1, Regenerate: 2, Hooks: 3, Delete: 4}
+
+var changeSymbol = map[ChangeType]string{Create: "+", Update: "~", Regenerate: "*", Hooks: "~", Delete: "-"}
+
+// Change is one step of a Plan.
+type Change struct {
+	Type ChangeType
+	Name string
+	// StackID is the live stack changed, empty for creations.
+	StackID string
+	// Spec is the manifest entry, nil for deletions of stacks no longer declared.
+	Spec *StackSpec
+	// Reasons explains why the change is needed.
+	Reasons []string
+	// HookActions are the hook changes of a Hooks change.
+	HookActions []hooksync.Action
+}
+
+func (c Change) String() string {
+	label := fmt.Sprintf("%s %s %q", changeSymbol[c.Type], c.Type, c.Name)
+	if c.StackID != "" {
+		label += fmt.Sprintf(" (%s)", c.StackID)
+	}
+	buf := bytes.NewBufferString(label)
+	for _, reason := range c.Reasons {
+		fmt.Fprintf(buf, "\n    %s", reason)
+	}
+	for _, a := range c.HookActions {
+		fmt.Fprintf(buf, "\n    %s", a)
+	}
+	return buf.String()
+}
+
+// Plan is the ordered list of changes making an account match a manifest.
+type Plan struct {
+	Changes []Change
+}
+
+// Empty reports whether the account already matches the manifest.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String renders the plan as a diff, for dry runs.
+func (p *Plan) String() string {
+	buf := new(bytes.Buffer)
+	for _, c := range p.Changes {
+		fmt.Fprintln(buf, c)
+	}
+	return buf.String()
+}
+
+// Options tune the planning.
+type Options struct {
+	// Prune deletes the stacks of the account that the manifest does not declare.
+	Prune bool
+	// RegenerateOutdated regenerates the declared stacks that are outdated.
+	RegenerateOutdated bool
+}
+
+// Planner compares manifests with the stacks of an account and applies the differences.
+type Planner struct {
+	client *stacksmith.Client
+	hooks  *hooksync.Syncer
+}
+
+// New returns a Planner using client.
+func New(client *stacksmith.Client) *Planner {
+	return &Planner{client: client, hooks: hooksync.New(client)}
+}
+
+// Plan computes the changes making the account match m.
+//
+// A stack whose components, OS or flavor differ from its manifest entry
+// cannot be changed in place: it is replaced, by creating the new stack
+// before deleting the old one.
+func (p *Planner) Plan(ctx context.Context, m *Manifest, opts Options) (*Plan, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	live, _, err := p.client.Stacks.ListAllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]stacksmith.StackItem)
+	for _, item := range live {
+		if _, ok := byName[item.Name]; ok {
+			return nil, fmt.Errorf("manifest: several stacks are named %q", item.Name)
+		}
+		byName[item.Name] = item
+	}
+
+	plan := new(Plan)
+	for i := range m.Stacks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		spec := &m.Stacks[i]
+		item, ok := byName[spec.Name]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Type: Create, Name: spec.Name, Spec: spec, Reasons: hookReasons(spec.Hooks)})
+			continue
+		}
+		delete(byName, spec.Name)
+
+		changes, err := p.planStack(ctx, spec, item.ID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: stack %q: %v", spec.Name, err)
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	if opts.Prune {
+		for name, item := range byName {
+			plan.Changes = append(plan.Changes, Change{Type: Delete, Name: name, StackID: item.ID, Reasons: []string{"not in manifest"}})
+		}
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		a, b := plan.Changes[i], plan.Changes[j]
+		if a.Type != b.Type {
+			return changeOrder[a.Type] < changeOrder[b.Type]
+		}
+		return a.Name < b.Name
+	})
+	return plan, nil
+}
+
+func (p *Planner) planStack(ctx context.Context, spec *StackSpec, stackID string, opts Options) ([]Change, error) {
+	stack, _, err := p.client.Stacks.GetContext(ctx, stackID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reasons := definitionChanges(spec, stack); len(reasons) > 0 {
+		if spec.Hooks == nil {
+			// Leaving the hooks alone means giving the new stack those of
+			// the stack it replaces.
+			hooks, _, err := p.client.Hooks.ListAllContext(ctx, stackID)
+			if err != nil {
+				return nil, err
+			}
+			replacement := *spec
+			replacement.Hooks = []string{}
+			for _, hook := range hooks {
+				replacement.Hooks = append(replacement.Hooks, hook.URL)
+			}
+			spec = &replacement
+		}
+		return []Change{
+			{Type: Create, Name: spec.Name, Spec: spec, Reasons: append(reasons, hookReasons(spec.Hooks)...)},
+			{Type: Delete, Name: spec.Name, StackID: stackID, Spec: spec, Reasons: []string{"replaced"}},
+		}, nil
+	}
+
+	var changes []Change
+	var reasons []string
+	if stack.NotificationsEnabled != spec.Notifications {
+		reasons = append(reasons, fmt.Sprintf("notifications: %t -> %t", stack.NotificationsEnabled, spec.Notifications))
+	}
+	if stack.Shared != spec.Shared {
+		reasons = append(reasons, fmt.Sprintf("shared: %t -> %t", stack.Shared, spec.Shared))
+	}
+	if len(reasons) > 0 {
+		changes = append(changes, Change{Type: Update, Name: spec.Name, StackID: stackID, Spec: spec, Reasons: reasons})
+	}
+	if opts.RegenerateOutdated && stack.Outdated {
+		changes = append(changes, Change{Type: Regenerate, Name: spec.Name, StackID: stackID, Spec: spec, Reasons: []string{"outdated"}})
+	}
+	if spec.Hooks != nil {
+		hookPlan, err := p.hooks.Plan(ctx, hooksync.Desired{stackID: spec.Hooks})
+		if err != nil {
+			return nil, err
+		}
+		if err := hookPlan.Errors[stackID]; err != nil {
+			return nil, err
+		}
+		if len(hookPlan.Actions) > 0 {
+			changes = append(changes, Change{Type: Hooks, Name: spec.Name, StackID: stackID, Spec: spec, HookActions: hookPlan.Actions})
+		}
+	}
+	return changes, nil
+}
+
+// definitionChanges compares the requirements of a live stack with its spec.
+func definitionChanges(spec *StackSpec, stack *stacksmith.Stack) []string {
+	wanted := make(map[string]string)
+	for _, c := range spec.Components {
+		wanted[c.ID] = versionOrLatest(c.Version)
+	}
+	wanted[spec.OS.ID] = versionOrLatest(spec.OS.Version)
+
+	current := make(map[string]string)
+	for _, r := range stack.Requirements {
+		current[r.ID] = versionOrLatest(r.Version)
+	}
+
+	var reasons []string
+	for _, id := range sortedKeys(wanted, current) {
+		want, hasWant := wanted[id]
+		have, hasHave := current[id]
+		switch {
+		case !hasHave:
+			reasons = append(reasons, fmt.Sprintf("add %s %s", id, want))
+		case !hasWant:
+			reasons = append(reasons, fmt.Sprintf("remove %s %s", id, have))
+		case want != have:
+			reasons = append(reasons, fmt.Sprintf("%s: %s -> %s", id, have, want))
+		}
+	}
+	if spec.Flavor != "" && spec.Flavor != stack.Flavor.ID {
+		reasons = append(reasons, fmt.Sprintf("flavor: %s -> %s", stack.Flavor.ID, spec.Flavor))
+	}
+	return reasons
+}
+
+func hookReasons(hooks []string) []string {
+	if len(hooks) == 0 {
+		return nil
+	}
+	return []string{"hooks: " + strings.Join(hooks, ", ")}
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Result reports the outcome of one Change.
+type Result struct {
+	Change Change
+	// Status is the generation started by creations and regenerations.
+	Status *stacksmith.StatusGeneration
+	Err    error
+}
+
+// Apply carries out the plan in order. A failing change does not stop the
+// others, except that a replaced stack is only deleted once its replacement
+// has been created. A created stack that cannot be configured is deleted, so
+// that the next plan creates it again rather than finding two stacks of the
+// same name.
+func (p *Planner) Apply(ctx context.Context, plan *Plan) ([]Result, error) {
+	var results []Result
+	failedCreate := make(map[string]bool)
+	for _, c := range plan.Changes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := Result{Change: c}
+		switch c.Type {
+		case Create:
+			result.Status, _, result.Err = p.client.Stacks.CreateContext(ctx, c.Spec.Definition())
+			if result.Err == nil {
+				result.Err = p.configure(ctx, result.Status.ID, c.Spec)
+				if result.Err != nil {
+					result.Err = p.discard(result.Status.ID, result.Err)
+				}
+			}
+			if result.Err != nil {
+				failedCreate[c.Name] = true
+			}
+		case Update:
+			_, _, result.Err = p.client.Stacks.UpdateContext(ctx, c.StackID, c.Spec.Params())
+		case Regenerate:
+			result.Status, _, result.Err = p.client.Stacks.RegenerateContext(ctx, c.StackID)
+		case Hooks:
+			result.Err = p.applyHooks(ctx, c)
+		case Delete:
+			if c.Spec != nil && failedCreate[c.Name] {
+				result.Err = fmt.Errorf("manifest: replacement of %q failed, keeping stack %s", c.Name, c.StackID)
+				break
+			}
+			_, _, result.Err = p.client.Stacks.DeleteContext(ctx, c.StackID)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// configure sets the properties and hooks of a newly created stack.
+func (p *Planner) configure(ctx context.Context, stackID string, spec *StackSpec) error {
+	if _, _, err := p.client.Stacks.UpdateContext(ctx, stackID, spec.Params()); err != nil {
+		return err
+	}
+	for _, url := range spec.Hooks {
+		if _, _, err := p.client.Hooks.RegisterContext(ctx, stackID, &stacksmith.HookParams{URL: url}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discard deletes the stack created with ID stackID, which configure failed
+// to set up with err. It runs even once the context of Apply is done, so as
+// not to leave a half configured stack behind.
+func (p *Planner) discard(stackID string, err error) error {
+	if _, _, deleteErr := p.client.Stacks.Delete(stackID); deleteErr != nil {
+		return fmt.Errorf("manifest: configuring new stack %s: %v; deleting it: %v", stackID, err, deleteErr)
+	}
+	return fmt.Errorf("manifest: configuring new stack %s: %v; deleted it", stackID, err)
+}
+
+func (p *Planner) applyHooks(ctx context.Context, c Change) error {
+	results, err := p.hooks.Apply(ctx, &hooksync.Plan{Actions: c.HookActions})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
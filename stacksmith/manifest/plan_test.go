@@ -0,0 +1,186 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+const liveStacks = `{"total_entries":3,"total_pages":1,"items":[
+	{"id":"bzr9nhz","name":"My ROR stack2"},
+	{"id":"nw5bkf3","name":"Node.js 6.4.0 on Debian"},
+	{"id":"orphan1","name":"Orphan"}]}`
+
+const nodeStack = `{"id":"nw5bkf3","name":"Node.js 6.4.0 on Debian","outdated":false,
+	"requirements":[{"id":"node","version":"6.4.0"},{"id":"debian","version":"wheezy"}]}`
+
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+	// failNew fails the updates of the created stack new1.
+	failNew bool
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != "GET" {
+		rec.calls = append(rec.calls, r.Method+" "+r.URL.Path)
+	}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/v1/stacks/":
+		fmt.Fprint(w, liveStacks)
+	case r.Method == "GET" && r.URL.Path == "/api/v1/stacks/bzr9nhz":
+		w.Write(utils.GetJSON("stack"))
+	case r.Method == "GET" && r.URL.Path == "/api/v1/stacks/nw5bkf3":
+		fmt.Fprint(w, nodeStack)
+	case r.Method == "GET" && r.URL.Path == "/api/v1/stacks/nw5bkf3/hooks":
+		fmt.Fprint(w, `{"total_entries":1,"total_pages":1,"items":[{"id":"h1","url":"https://hooks.example.com/node"}]}`)
+	case r.Method == "GET":
+		fmt.Fprint(w, `{"total_entries":0,"total_pages":1,"items":[]}`)
+	case r.Method == "PATCH" && r.URL.Path == "/api/v1/stacks/new1" && rec.failNew:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"status":"500","error":"Internal error"}`)
+	case r.Method == "POST" && r.URL.Path == "/api/v1/stacks/":
+		fmt.Fprint(w, `{"id":"new1","stack_url":"https://stacksmith.bitnami.com/api/v1/stacks/new1"}`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func TestPlanner(t *testing.T) {
+	rec := new(recorder)
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	planner := New(stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)))
+
+	m, err := Load("testdata/stacks.yaml")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	plan, err := planner.Plan(context.Background(), m, Options{Prune: true, RegenerateOutdated: true})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	diffExpected := `+ create "Go service"
+    hooks: https://hooks.example.com/go
++ create "Node.js 6.4.0 on Debian"
+    debian: wheezy -> jessie
+    hooks: https://hooks.example.com/node
+~ update "My ROR stack2" (bzr9nhz)
+    notifications: true -> false
+* regenerate "My ROR stack2" (bzr9nhz)
+    outdated
+~ hooks "My ROR stack2" (bzr9nhz)
+    + https://hooks.example.com/ror
+- delete "Node.js 6.4.0 on Debian" (nw5bkf3)
+    replaced
+- delete "Orphan" (orphan1)
+    not in manifest
+`
+	if plan.String() != diffExpected {
+		t.Errorf("Plan returned\n%s\nwant\n%s", plan, diffExpected)
+	}
+
+	results, err := planner.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("Apply of %s returned error: %v", result.Change, result.Err)
+		}
+	}
+
+	callsExpected := []string{
+		"POST /api/v1/stacks/",
+		"PATCH /api/v1/stacks/new1",
+		"POST /api/v1/stacks/new1/hooks",
+		"POST /api/v1/stacks/",
+		"PATCH /api/v1/stacks/new1",
+		"POST /api/v1/stacks/new1/hooks",
+		"PATCH /api/v1/stacks/bzr9nhz",
+		"POST /api/v1/stacks/bzr9nhz/regenerate",
+		"POST /api/v1/stacks/bzr9nhz/hooks",
+		"DELETE /api/v1/stacks/nw5bkf3",
+		"DELETE /api/v1/stacks/orphan1",
+	}
+	if !reflect.DeepEqual(rec.calls, callsExpected) {
+		t.Errorf("Apply made calls %v, want %v", rec.calls, callsExpected)
+	}
+}
+
+func TestPlanner_ApplyFailedReplacement(t *testing.T) {
+	rec := &recorder{failNew: true}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	planner := New(stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)))
+
+	m, err := Load("testdata/stacks.yaml")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	var spec *StackSpec
+	for i := range m.Stacks {
+		if m.Stacks[i].Name == "Node.js 6.4.0 on Debian" {
+			spec = &m.Stacks[i]
+		}
+	}
+	plan := &Plan{Changes: []Change{
+		{Type: Create, Name: spec.Name, Spec: spec},
+		{Type: Delete, Name: spec.Name, StackID: "nw5bkf3", Spec: spec},
+	}}
+
+	results, err := planner.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			t.Errorf("Apply of %s returned no error", result.Change)
+		}
+	}
+	callsExpected := []string{
+		"POST /api/v1/stacks/",
+		"PATCH /api/v1/stacks/new1",
+		"DELETE /api/v1/stacks/new1",
+	}
+	if !reflect.DeepEqual(rec.calls, callsExpected) {
+		t.Errorf("Apply made calls %v, want %v", rec.calls, callsExpected)
+	}
+}
+
+func TestPlanner_ApplyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel while the stack is being created. The body is read so that
+		// the server notices the client going away.
+		ioutil.ReadAll(r.Body)
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	planner := New(stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)))
+
+	spec := &StackSpec{Name: "Go service"}
+	results, _ := planner.Apply(ctx, &Plan{Changes: []Change{{Type: Create, Name: spec.Name, Spec: spec}}})
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("Apply canceled during a creation returned %+v", results)
+	}
+}
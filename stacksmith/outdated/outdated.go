@@ -0,0 +1,259 @@
+// Package outdated builds an account-wide report of the outdated components
+// of stacks, grouped by component.
+//
+// Stacks are listed once and only those flagged outdated are fetched, a
+// bounded number at a time. The releases a component is behind are counted
+// from its Discovery changelog.
+package outdated
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/internal/parallel"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// DefaultConcurrency is the number of requests in flight when
+// Options.Concurrency is zero.
+const DefaultConcurrency = 4
+
+// Options tunes Build.
+type Options struct {
+	// Concurrency bounds the requests in flight.
+	Concurrency int
+	// Filter selects the stacks to report on. Nil selects all.
+	Filter func(stacksmith.StackItem) bool
+	// SkipChangelog does not read changelogs, so that only the revisions
+	// of the version in use are counted as behind.
+	SkipChangelog bool
+}
+
+// Report lists the outdated components of the stacks of an account.
+type Report struct {
+	// Stacks is the number of stacks checked.
+	Stacks     int         `json:"stacks"`
+	Components []Component `json:"components"`
+	// Errors holds the stacks that could not be fetched.
+	Errors map[string]error `json:"-"`
+}
+
+// Component is an outdated component and where it is used.
+type Component struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Stacks is the number of stacks using an outdated version.
+	Stacks int `json:"stacks"`
+	// MaxBehind is the largest Usage.Behind, -1 when all are unknown.
+	MaxBehind int     `json:"max_behind"`
+	Versions  []Usage `json:"versions"`
+}
+
+// Usage is an outdated version of a component and the stacks using it.
+type Usage struct {
+	// Version is the version in use with its revision, like "2.2.3-3".
+	Version string `json:"version"`
+	Branch  string `json:"branch,omitempty"`
+	Latest  string `json:"latest"`
+	// Behind counts the releases, new versions or revisions, published
+	// after Version. It is -1 when unknown: the changelog was skipped or
+	// could not be read and Latest is another version.
+	Behind   int      `json:"behind"`
+	StackIDs []string `json:"stack_ids"`
+}
+
+// Build walks the stacks of the account and reports their outdated
+// components. Stacks failing to be fetched are left out and listed in
+// Report.Errors; the error returned is for the listing of stacks or ctx.
+func Build(ctx context.Context, client *stacksmith.Client, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	items, _, err := client.Stacks.ListAllContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	report := &Report{Components: []Component{}, Errors: make(map[string]error)}
+	var ids []string
+	for _, item := range items {
+		if opts.Filter != nil && !opts.Filter(item) {
+			continue
+		}
+		report.Stacks++
+		if item.Outdated {
+			ids = append(ids, item.ID)
+		}
+	}
+
+	stacks := make([]*stacksmith.Stack, len(ids))
+	errs := make([]error, len(ids))
+	if err := parallel.ForEach(ctx, len(ids), concurrency, func(i int) {
+		stacks[i], _, errs[i] = client.Stacks.GetContext(ctx, ids[i])
+	}); err != nil {
+		return nil, err
+	}
+
+	usages := make(map[usageKey]*Usage)
+	components := make(map[string]*Component)
+	for i, stack := range stacks {
+		if errs[i] != nil {
+			report.Errors[ids[i]] = errs[i]
+			continue
+		}
+		for _, c := range append([]stacksmith.Component{stack.Os}, stack.Components...) {
+			if c.ID == "" || !c.Outdated {
+				continue
+			}
+			key := usageKey{ID: c.ID, Version: c.Version, Revision: c.Revision, Branch: c.Branch}
+			u, ok := usages[key]
+			if !ok {
+				u = &Usage{
+					Version: version.Format(c.Version, c.Revision),
+					Branch:  c.Branch,
+					Latest:  version.Format(c.Latest.Version, c.Latest.Revision),
+					Behind:  -1,
+				}
+				if c.Version == c.Latest.Version {
+					u.Behind = c.Latest.Revision - c.Revision
+				}
+				usages[key] = u
+			}
+			u.StackIDs = append(u.StackIDs, stack.ID)
+			if _, ok := components[c.ID]; !ok {
+				components[c.ID] = &Component{ID: c.ID, Name: c.Name, MaxBehind: -1}
+			}
+		}
+	}
+
+	keys := make([]usageKey, 0, len(usages))
+	for key := range usages {
+		keys = append(keys, key)
+	}
+	if !opts.SkipChangelog {
+		if err := parallel.ForEach(ctx, len(keys), concurrency, func(i int) {
+			if behind, err := releasesAfter(ctx, client, keys[i]); err == nil {
+				usages[keys[i]].Behind = behind
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	for _, key := range keys {
+		u := usages[key]
+		sort.Strings(u.StackIDs)
+		c := components[key.ID]
+		c.Versions = append(c.Versions, *u)
+		c.Stacks += len(u.StackIDs)
+		if u.Behind > c.MaxBehind {
+			c.MaxBehind = u.Behind
+		}
+	}
+	ids = ids[:0]
+	for id := range components {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		report.Components = append(report.Components, *components[id])
+	}
+	return report, nil
+}
+
+type usageKey struct {
+	ID       string
+	Version  string
+	Revision int
+	Branch   string
+}
+
+func (k usageKey) less(o usageKey) bool {
+	switch {
+	case k.ID != o.ID:
+		return k.ID < o.ID
+	case k.Version != o.Version:
+		return k.Version < o.Version
+	case k.Revision != o.Revision:
+		return k.Revision < o.Revision
+	}
+	return k.Branch < o.Branch
+}
+
+// releasesAfter counts the changelog entries of the component of key
+// published after the version in use, following every page.
+func releasesAfter(ctx context.Context, client *stacksmith.Client, key usageKey) (int, error) {
+	behind := 0
+	for page := 1; ; page++ {
+		changelog, _, err := client.Discovery.GetChangelogFromContext(ctx, key.ID,
+			&stacksmith.RangeParams{From: key.Version}, &stacksmith.PaginationParams{Page: page, PerPage: 100})
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range changelog.Items {
+			if key.Branch != "" && entry.Branch != "" && entry.Branch != key.Branch {
+				continue
+			}
+			if entry.Version != key.Version || entry.Revision > key.Revision {
+				behind++
+			}
+		}
+		if page >= changelog.TotalPAges || len(changelog.Items) == 0 {
+			return behind, nil
+		}
+	}
+}
+
+// CSVHeader is the first record written by WriteCSV.
+var CSVHeader = []string{"component", "name", "version", "branch", "latest", "behind", "stacks", "stack_ids"}
+
+// WriteCSV writes one record per outdated version in use.
+func WriteCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write(CSVHeader)
+	for _, c := range r.Components {
+		for _, u := range c.Versions {
+			cw.Write([]string{
+				c.ID, c.Name, u.Version, u.Branch, u.Latest, strconv.Itoa(u.Behind),
+				strconv.Itoa(len(u.StackIDs)), strings.Join(u.StackIDs, " "),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes r as indented JSON, errors as messages.
+func WriteJSON(w io.Writer, r *Report) error {
+	out := struct {
+		*Report
+		Errors map[string]string `json:"errors,omitempty"`
+	}{Report: r}
+	if len(r.Errors) > 0 {
+		out.Errors = make(map[string]string)
+		for id, err := range r.Errors {
+			out.Errors[id] = err.Error()
+		}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,193 @@
+package outdated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testServer serves stacks s1 to s5: s1 and s2 use ruby 2.2.3-3, s3 ruby
+// 2.2.5-0 and an outdated OS, s4 is up to date and s5 cannot be fetched.
+// inFlight and maxInFlight count the stacks being fetched.
+func testServer(inFlight, maxInFlight *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/stacks/")
+		if id == "" {
+			fmt.Fprint(w, `{"total_entries":5,"total_pages":1,"items":[
+				{"id":"s1","outdated":true},{"id":"s2","outdated":true},{"id":"s3","outdated":true},
+				{"id":"s4","outdated":false},{"id":"s5","outdated":true}]}`)
+			return
+		}
+
+		n := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			max := atomic.LoadInt32(maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		stack := new(stacksmith.Stack)
+		json.Unmarshal(utils.GetJSON("stack"), stack)
+		stack.ID = id
+		stack.Os.Outdated = false
+		ruby := &stack.Components[0]
+		ruby.Outdated = true
+		ruby.Latest.Version, ruby.Latest.Revision = "2.3.1", 0
+		switch id {
+		case "s3":
+			ruby.Version, ruby.Revision = "2.2.5", 0
+			ruby.Latest.Version, ruby.Latest.Revision = "2.2.5", 2
+			stack.Os.Outdated = true
+			stack.Os.Latest.Version, stack.Os.Latest.Revision = "jessie", 8
+		case "s4":
+			ruby.Outdated = false
+		case "s5":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"status":"500","error":"Internal error"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(stack)
+	})
+	mux.HandleFunc("/api/v1/components/ruby/changelog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("from") != "2.2.3" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status":"404","error":"Not found"}`)
+			return
+		}
+		if r.FormValue("page") == "1" {
+			fmt.Fprint(w, `{"total_entries":4,"total_pages":2,"items":[
+				{"version":"2.3.1","revision":0,"branch":"stable"},
+				{"version":"2.3.0","revision":1,"branch":"stable"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"total_entries":4,"total_pages":2,"items":[
+			{"version":"2.2.3","revision":4,"branch":"stable"},
+			{"version":"2.2.3","revision":3,"branch":"stable"}]}`)
+	})
+	mux.HandleFunc("/api/v1/components/debian/changelog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"status":"404","error":"Not found"}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestBuild(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := testServer(&inFlight, &maxInFlight)
+	defer server.Close()
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+
+	report, err := Build(context.Background(), client, &Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+	if maxInFlight != 2 {
+		t.Errorf("Build fetched %d stacks at a time, want 2", maxInFlight)
+	}
+
+	expected := []Component{
+		{ID: "debian", Name: "Debian", Stacks: 1, MaxBehind: -1, Versions: []Usage{
+			{Version: "wheezy-7", Branch: "stable", Latest: "jessie-8", Behind: -1, StackIDs: []string{"s3"}},
+		}},
+		{ID: "ruby", Name: "Ruby", Stacks: 3, MaxBehind: 3, Versions: []Usage{
+			{Version: "2.2.3-3", Branch: "stable", Latest: "2.3.1-0", Behind: 3, StackIDs: []string{"s1", "s2"}},
+			{Version: "2.2.5-0", Branch: "stable", Latest: "2.2.5-2", Behind: 2, StackIDs: []string{"s3"}},
+		}},
+	}
+	if !reflect.DeepEqual(report.Components, expected) {
+		t.Errorf("Build returned %+v, want %+v", report.Components, expected)
+	}
+	if report.Stacks != 5 || len(report.Errors) != 1 || report.Errors["s5"] == nil {
+		t.Errorf("Build checked %d stacks with errors %v", report.Stacks, report.Errors)
+	}
+
+	report, _ = Build(context.Background(), client, &Options{SkipChangelog: true, Filter: func(s stacksmith.StackItem) bool { return s.ID == "s1" }})
+	if len(report.Components) != 1 || report.Components[0].Versions[0].Behind != -1 || report.Stacks != 1 {
+		t.Errorf("Build without changelog returned %+v", report)
+	}
+}
+
+func TestBuild_Canceled(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := testServer(&inFlight, &maxInFlight)
+	defer server.Close()
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Build(ctx, client, nil); err != context.Canceled {
+		t.Errorf("Build with a canceled context returned %v", err)
+	}
+}
+
+func TestBuild_CanceledInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/stacks/" {
+			fmt.Fprint(w, `{"total_entries":1,"total_pages":1,"items":[{"id":"s1","outdated":true}]}`)
+			return
+		}
+		// Cancel while the stack is being fetched.
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+
+	start := time.Now()
+	if _, err := Build(ctx, client, nil); err != context.Canceled {
+		t.Errorf("Build canceled while fetching a stack returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Build canceled while fetching a stack returned after %s", elapsed)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	report := &Report{Stacks: 2, Components: []Component{{ID: "ruby", Name: "Ruby, the language", Stacks: 2, MaxBehind: 3, Versions: []Usage{
+		{Version: "2.2.3-3", Latest: "2.3.1-0", Behind: 3, StackIDs: []string{"s1", "s2"}},
+	}}}, Errors: map[string]error{"s5": fmt.Errorf("Internal error")}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, report); err != nil {
+		t.Fatalf("WriteCSV returned error: %v", err)
+	}
+	expected := "component,name,version,branch,latest,behind,stacks,stack_ids\nruby,\"Ruby, the language\",2.2.3-3,,2.3.1-0,3,2,s1 s2\n"
+	if buf.String() != expected {
+		t.Errorf("WriteCSV wrote %q, want %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, report); err != nil {
+		t.Fatalf("WriteJSON returned error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON wrote invalid JSON: %v", err)
+	}
+	if decoded["errors"].(map[string]interface{})["s5"] != "Internal error" || decoded["stacks"] != 2.0 {
+		t.Errorf("WriteJSON wrote %s", buf.String())
+	}
+}
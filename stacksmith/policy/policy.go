@@ -0,0 +1,281 @@
+// Package policy decides whether a stack complies with a security policy,
+// so that CI pipelines can fail the builds of the stacks that do not.
+//
+// A policy is usually read from a YAML file:
+//
+//	max_severity: medium
+//	max_outdated_days: 30
+//	components:
+//	  openssl:
+//	    max_severity: low
+//	allow:
+//	  - vulnerability: CVE-2016-0800
+//	    component: ruby
+//	    expires: 2016-12-31
+//	    reason: not reachable from the application
+//
+// The vulnerabilities evaluated are those Stacksmith attaches to the
+// components of the stack, those listed by Stacks.GetVulnerabilities and,
+// when neither names any, the summary in Stack.Vulnerabilities.
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+	"gopkg.in/yaml.v2"
+)
+
+// DateFormat is the format of Allowance.Expires.
+const DateFormat = "2006-01-02"
+
+// Rules reported in Finding.Rule.
+const (
+	RuleSeverity = "max_severity"
+	RuleOutdated = "max_outdated_days"
+)
+
+// Policy holds the rules a stack must follow.
+type Policy struct {
+	// MaxSeverity is the most severe vulnerability allowed: "none", "low",
+	// "medium", "high" or "critical". Empty allows any.
+	MaxSeverity string `yaml:"max_severity,omitempty" json:"max_severity,omitempty"`
+	// MaxOutdatedDays fails the outdated stacks last generated more than
+	// that many days ago. Zero disables the rule.
+	MaxOutdatedDays int `yaml:"max_outdated_days,omitempty" json:"max_outdated_days,omitempty"`
+	// Components replaces MaxSeverity for some components, by ID.
+	Components map[string]Exception `yaml:"components,omitempty" json:"components,omitempty"`
+	// Allow lists the vulnerabilities accepted despite their severity.
+	Allow []Allowance `yaml:"allow,omitempty" json:"allow,omitempty"`
+}
+
+// Exception is the rule of one component.
+type Exception struct {
+	MaxSeverity string `yaml:"max_severity" json:"max_severity"`
+	Reason      string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Allowance accepts a vulnerability until it expires.
+type Allowance struct {
+	Vulnerability string `yaml:"vulnerability" json:"vulnerability"`
+	// Component restricts the allowance to one component. Empty means any.
+	Component string `yaml:"component,omitempty" json:"component,omitempty"`
+	// Expires is the last day, as "2006-01-02" in UTC, the allowance
+	// applies. Empty never expires.
+	Expires string `yaml:"expires,omitempty" json:"expires,omitempty"`
+	Reason  string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// expired tells whether the allowance no longer applies at now.
+func (a *Allowance) expired(now time.Time) bool {
+	if a.Expires == "" {
+		return false
+	}
+	day, err := time.Parse(DateFormat, a.Expires)
+	return err != nil || !now.Before(day.AddDate(0, 0, 1))
+}
+
+// Load reads the policy file at path, YAML or JSON.
+func Load(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Policy)
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("policy: %s: %v", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%v in %s", err, path)
+	}
+	return p, nil
+}
+
+// Validate checks the severities and dates of the policy.
+func (p *Policy) Validate() error {
+	if !vulns.KnownSeverity(p.MaxSeverity) {
+		return fmt.Errorf("policy: unknown severity %q", p.MaxSeverity)
+	}
+	if p.MaxOutdatedDays < 0 {
+		return fmt.Errorf("policy: negative max_outdated_days %d", p.MaxOutdatedDays)
+	}
+	for id, e := range p.Components {
+		if !vulns.KnownSeverity(e.MaxSeverity) {
+			return fmt.Errorf("policy: unknown severity %q for component %s", e.MaxSeverity, id)
+		}
+	}
+	for _, a := range p.Allow {
+		if a.Vulnerability == "" {
+			return fmt.Errorf("policy: allowance without vulnerability")
+		}
+		if a.Expires == "" {
+			continue
+		}
+		if _, err := time.Parse(DateFormat, a.Expires); err != nil {
+			return fmt.Errorf("policy: allowance of %s expires on %q, want YYYY-MM-DD", a.Vulnerability, a.Expires)
+		}
+	}
+	return nil
+}
+
+// Finding is one reason for a verdict.
+type Finding struct {
+	// Rule is RuleSeverity or RuleOutdated.
+	Rule          string `json:"rule"`
+	Vulnerability string `json:"vulnerability,omitempty"`
+	Component     string `json:"component,omitempty"`
+	Severity      string `json:"severity,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// Verdict is the result of a policy on a stack.
+type Verdict struct {
+	StackID string `json:"stack_id"`
+	Pass    bool   `json:"pass"`
+	// Violations make the verdict fail.
+	Violations []Finding `json:"violations"`
+	// Waived are the violations accepted by an allowance.
+	Waived []Finding `json:"waived,omitempty"`
+}
+
+// Verdicts are the verdicts of several stacks.
+type Verdicts []*Verdict
+
+// Failed tells whether any verdict fails.
+func (vs Verdicts) Failed() bool {
+	for _, v := range vs {
+		if !v.Pass {
+			return true
+		}
+	}
+	return false
+}
+
+// Check fetches a stack and its vulnerabilities and evaluates p on them.
+func (p *Policy) Check(client *stacksmith.Client, stackID string) (*Verdict, *http.Response, error) {
+	stack, resp, err := client.Stacks.Get(stackID)
+	if err != nil {
+		return nil, resp, err
+	}
+	vulnerabilities, resp, err := client.Stacks.GetAllVulnerabilities(stackID)
+	if err != nil {
+		return nil, resp, err
+	}
+	return p.Evaluate(stack, vulnerabilities, time.Now()), resp, nil
+}
+
+// Evaluate applies p at now to stack and vulnerabilities, as returned by
+// Stacks.GetAllVulnerabilities.
+func (p *Policy) Evaluate(stack *stacksmith.Stack, vulnerabilities []stacksmith.VulnerabilityItem, now time.Time) *Verdict {
+	v := &Verdict{StackID: stack.ID, Violations: []Finding{}}
+	found := vulns.Merge(stack, vulnerabilities)
+	if len(found) == 0 && stack.Vulnerabilities.Vulnerable {
+		// The stack tells its severity without naming any vulnerability.
+		found = []vulns.Entry{{Severity: stack.Vulnerabilities.Severity}}
+	}
+	for _, f := range found {
+		max, scope := p.MaxSeverity, "the policy"
+		if e, ok := p.Components[f.Component]; ok && f.Component != "" {
+			max, scope = e.MaxSeverity, "the policy of "+f.Component
+		}
+		if max == "" || vulns.Rank(f.Severity) <= vulns.Rank(max) {
+			continue
+		}
+
+		finding := Finding{Rule: RuleSeverity, Vulnerability: f.Name, Component: f.Component, Severity: f.Severity}
+		switch {
+		case f.Name == "":
+			finding.Reason = fmt.Sprintf("stack is vulnerable with %s severity, above %s allowed by %s", f.Severity, max, scope)
+		case f.Component == "":
+			finding.Reason = fmt.Sprintf("%s (%s) is above %s allowed by %s", f.Name, f.Severity, max, scope)
+		default:
+			finding.Reason = fmt.Sprintf("%s (%s) in %s is above %s allowed by %s", f.Name, f.Severity, f.Component, max, scope)
+		}
+
+		if a := p.allowance(f); a != nil {
+			if !a.expired(now) {
+				finding.Reason += ", " + allowed(a)
+				v.Waived = append(v.Waived, finding)
+				continue
+			}
+			finding.Reason += ", allowance expired on " + a.Expires
+		}
+		v.Violations = append(v.Violations, finding)
+	}
+
+	if f, ok := p.outdated(stack, now); ok {
+		v.Violations = append(v.Violations, f)
+	}
+	v.Pass = len(v.Violations) == 0
+	return v
+}
+
+// allowance returns the allowance matching f, preferring the ones that have
+// not expired.
+func (p *Policy) allowance(f vulns.Entry) *Allowance {
+	var match *Allowance
+	for i := range p.Allow {
+		a := &p.Allow[i]
+		if a.Vulnerability != f.Name || (a.Component != "" && a.Component != f.Component) {
+			continue
+		}
+		if match == nil || a.Expires == "" || (match.Expires != "" && a.Expires > match.Expires) {
+			match = a
+		}
+	}
+	return match
+}
+
+func allowed(a *Allowance) string {
+	s := "allowed"
+	if a.Expires != "" {
+		s += " until " + a.Expires
+	}
+	if a.Reason != "" {
+		s += ": " + a.Reason
+	}
+	return s
+}
+
+// outdated applies MaxOutdatedDays. Stacksmith does not say when a stack
+// became outdated, so its age is counted from its last generation.
+func (p *Policy) outdated(stack *stacksmith.Stack, now time.Time) (Finding, bool) {
+	if p.MaxOutdatedDays == 0 || !stack.Outdated {
+		return Finding{}, false
+	}
+	generated, ok := generatedAt(stack)
+	if !ok {
+		return Finding{}, false
+	}
+	days := int(now.Sub(generated).Hours() / 24)
+	if days <= p.MaxOutdatedDays {
+		return Finding{}, false
+	}
+
+	var components []string
+	for _, c := range stack.Components {
+		if c.Outdated {
+			components = append(components, c.ID+" "+version.Format(c.Version, c.Revision))
+		}
+	}
+	reason := fmt.Sprintf("stack is outdated and was last generated %d days ago, more than %d allowed", days, p.MaxOutdatedDays)
+	if len(components) > 0 {
+		reason += " (outdated: " + strings.Join(components, ", ") + ")"
+	}
+	return Finding{Rule: RuleOutdated, Reason: reason}, true
+}
+
+func generatedAt(stack *stacksmith.Stack) (time.Time, bool) {
+	for _, date := range []string{stack.RegeneratedAt, stack.GeneratedAt} {
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
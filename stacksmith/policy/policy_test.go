@@ -0,0 +1,202 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// now is a few months after the generation of the fixture stack.
+var now = time.Date(2016, 9, 23, 15, 0, 0, 0, time.UTC)
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func names(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Vulnerability+"@"+f.Component)
+	}
+	return out
+}
+
+func TestEvaluate_MaxSeverity(t *testing.T) {
+	var cases = []struct {
+		policy     Policy
+		violations []string
+	}{
+		{Policy{}, nil},
+		{Policy{MaxSeverity: "critical"}, nil},
+		{Policy{MaxSeverity: "medium"}, []string{"CVE-2016-0800@ruby", "CVE-2016-2107@ruby"}},
+		{Policy{MaxSeverity: "high", Components: map[string]Exception{"ruby": {MaxSeverity: "none"}}}, []string{
+			"CVE-2015-3197@ruby", "CVE-2015-7551@ruby", "CVE-2016-0800@ruby", "CVE-2016-2105@ruby",
+			"CVE-2016-2106@ruby", "CVE-2016-2107@ruby", "CVE-2016-2109@ruby", "CVE-2016-2176@ruby",
+		}},
+		{Policy{MaxSeverity: "none", Components: map[string]Exception{"ruby": {MaxSeverity: "high"}}}, nil},
+	}
+	for _, c := range cases {
+		v := c.policy.Evaluate(testStack(t), nil, now)
+		if got := names(v.Violations); !reflect.DeepEqual(got, c.violations) {
+			t.Errorf("Evaluate(%+v) violations are %v, want %v", c.policy, got, c.violations)
+		}
+		if v.Pass != (len(c.violations) == 0) {
+			t.Errorf("Evaluate(%+v) passes: %v", c.policy, v.Pass)
+		}
+	}
+}
+
+func TestEvaluate_Sources(t *testing.T) {
+	p := &Policy{MaxSeverity: "high"}
+	stack := testStack(t)
+	stack.Components[0].Vulnerabilities.Items = nil
+
+	if v := p.Evaluate(stack, nil, now); len(v.Violations) != 0 {
+		t.Errorf("Evaluate of a high stack summary returned %v", v.Violations)
+	}
+	stack.Vulnerabilities.Severity = "critical"
+	v := p.Evaluate(stack, nil, now)
+	if len(v.Violations) != 1 || !strings.Contains(v.Violations[0].Reason, "stack is vulnerable with critical severity") {
+		t.Errorf("Evaluate of a critical stack summary returned %+v", v.Violations)
+	}
+
+	var listed stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2016-9999","severity":"critical","ranges":[{"component":"ruby","from":"2.2","to":"2.2.5"}]},
+		{"name":"CVE-2016-9997","severity":"critical","ranges":[{"component":"ruby","from":"2.1","to":"2.2.2"},{"component":"nginx","from":"1.10","to":"1.10.2"}]},
+		{"name":"CVE-2016-9998","severity":"mystery"}]}`), &listed)
+	v = p.Evaluate(stack, listed.Items, now)
+	expected := []string{"CVE-2016-9998@", "CVE-2016-9999@ruby"}
+	if got := names(v.Violations); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Evaluate of listed vulnerabilities returned %v, want %v", got, expected)
+	}
+}
+
+func TestEvaluate_Allow(t *testing.T) {
+	p := &Policy{MaxSeverity: "medium", Allow: []Allowance{
+		{Vulnerability: "CVE-2016-0800", Component: "ruby", Expires: "2016-09-23", Reason: "not reachable"},
+		{Vulnerability: "CVE-2016-2107", Expires: "2016-09-22"},
+		{Vulnerability: "CVE-2016-2107", Component: "openssl"},
+	}}
+	v := p.Evaluate(testStack(t), nil, now)
+	if got := names(v.Violations); !reflect.DeepEqual(got, []string{"CVE-2016-2107@ruby"}) {
+		t.Errorf("Evaluate violations are %v", got)
+	}
+	if reason := v.Violations[0].Reason; !strings.HasSuffix(reason, ", allowance expired on 2016-09-22") {
+		t.Errorf("expired allowance reason is %q", reason)
+	}
+	if len(v.Waived) != 1 || v.Waived[0].Reason != "CVE-2016-0800 (high) in ruby is above medium allowed by the policy, allowed until 2016-09-23: not reachable" {
+		t.Errorf("Evaluate waived %+v", v.Waived)
+	}
+
+	if v := p.Evaluate(testStack(t), nil, now.AddDate(0, 0, 1)); len(v.Violations) != 2 || len(v.Waived) != 0 {
+		t.Errorf("Evaluate the day after the allowance expires returned %+v", v)
+	}
+}
+
+func TestEvaluate_Outdated(t *testing.T) {
+	stack := testStack(t)
+	stack.Components[0].Outdated = true
+
+	p := &Policy{MaxOutdatedDays: 90}
+	if v := p.Evaluate(stack, nil, now); !v.Pass {
+		t.Errorf("Evaluate of a stack generated 90 days ago failed: %+v", v.Violations)
+	}
+	v := p.Evaluate(stack, nil, now.AddDate(0, 0, 1))
+	expected := "stack is outdated and was last generated 91 days ago, more than 90 allowed (outdated: ruby 2.2.3-3)"
+	if v.Pass || v.Violations[0].Rule != RuleOutdated || v.Violations[0].Reason != expected {
+		t.Errorf("Evaluate of an old stack returned %+v", v)
+	}
+
+	stack.Outdated = false
+	if v := p.Evaluate(stack, nil, now.AddDate(1, 0, 0)); !v.Pass {
+		t.Errorf("Evaluate of an up to date stack failed: %+v", v.Violations)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "policy")
+	defer os.RemoveAll(dir)
+	write := func(contents string) string {
+		path := filepath.Join(dir, "policy.yaml")
+		ioutil.WriteFile(path, []byte(contents), 0644)
+		return path
+	}
+
+	p, err := Load(write(`max_severity: medium
+max_outdated_days: 30
+components:
+  openssl:
+    max_severity: low
+allow:
+  - vulnerability: CVE-2016-0800
+    component: ruby
+    expires: 2016-12-31
+`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	expected := &Policy{
+		MaxSeverity:     "medium",
+		MaxOutdatedDays: 30,
+		Components:      map[string]Exception{"openssl": {MaxSeverity: "low"}},
+		Allow:           []Allowance{{Vulnerability: "CVE-2016-0800", Component: "ruby", Expires: "2016-12-31"}},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Load returned %+v, want %+v", p, expected)
+	}
+
+	for _, bad := range []string{
+		"max_severity: severe",
+		"max_severity_typo: low",
+		"components: {ruby: {max_severity: meh}}",
+		"allow: [{vulnerability: CVE-1, expires: 31/12/2016}]",
+		"allow: [{component: ruby}]",
+	} {
+		if _, err := Load(write(bad)); err == nil {
+			t.Errorf("Load(%q) returned no error", bad)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(utils.GetJSON("stack"))
+	})
+	mux.HandleFunc("/api/v1/stacks/bzr9nhz/vulnerabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"total_entries":1,"total_pages":1,"items":[
+			{"name":"CVE-2016-9999","severity":"critical","ranges":[{"component":"debian","from":"wheezy","to":"wheezy"}]}]}`)
+	})
+
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+	v, _, err := (&Policy{MaxSeverity: "high"}).Check(client, "bzr9nhz")
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if got := names(v.Violations); !reflect.DeepEqual(got, []string{"CVE-2016-9999@debian"}) {
+		t.Errorf("Check violations are %v", got)
+	}
+	if !(Verdicts{v}).Failed() || (Verdicts{{Pass: true}}).Failed() {
+		t.Errorf("Verdicts.Failed is wrong")
+	}
+}
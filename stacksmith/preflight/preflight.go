@@ -0,0 +1,336 @@
+// Package preflight checks stack definitions against the Discovery catalog
+// before they are sent to Stacks.Create, reporting every unknown component,
+// OS, version or flavor with suggestions.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// Problem is a field of a definition that Stacksmith would reject.
+type Problem struct {
+	// Field is the JSON path of the field, like "components[1].version".
+	Field       string   `json:"field"`
+	Value       string   `json:"value"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+func (p Problem) String() string {
+	s := p.Field + ": " + p.Message
+	if len(p.Suggestions) > 0 {
+		quoted := make([]string, len(p.Suggestions))
+		for i, suggestion := range p.Suggestions {
+			quoted[i] = strconv.Quote(suggestion)
+		}
+		s += ", did you mean " + strings.Join(quoted, " or ") + "?"
+	}
+	return s
+}
+
+// Problems is the error returned by Validate for an invalid definition.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validator checks definitions, looking up each part of the catalog once.
+type Validator struct {
+	discovery *stacksmith.DiscoveryService
+
+	components map[string]stacksmith.Item
+	oses       map[string]stacksmith.Item
+	items      map[string]*stacksmith.Item
+	flavors    map[string][]string
+}
+
+// New returns a Validator looking up the catalog in discovery.
+func New(discovery *stacksmith.DiscoveryService) *Validator {
+	return &Validator{
+		discovery: discovery,
+		items:     make(map[string]*stacksmith.Item),
+		flavors:   make(map[string][]string),
+	}
+}
+
+// Validate checks the name, OS, components, versions and flavor of def. It
+// returns Problems listing everything wrong with def, or another error when
+// the catalog cannot be read.
+func (v *Validator) Validate(ctx context.Context, def *stacksmith.StackDefinition) error {
+	var problems Problems
+	add := func(field, value, message string, suggestions []string) {
+		problems = append(problems, Problem{Field: field, Value: value, Message: message, Suggestions: suggestions})
+	}
+
+	if strings.TrimSpace(def.Name) == "" {
+		add("name", def.Name, "is required", nil)
+	}
+
+	if err := v.loadCatalog(ctx); err != nil {
+		return err
+	}
+	var valid []string
+	check := func(field string, c stacksmith.ComponentItem, catalog map[string]stacksmith.Item, kind string) error {
+		switch {
+		case c.ID == "":
+			add(field+".id", c.ID, "is required", nil)
+			return nil
+		case catalog[c.ID].ID == "":
+			add(field+".id", c.ID, fmt.Sprintf("unknown %s %q", kind, c.ID), suggest(c.ID, catalogIDs(catalog)))
+			return nil
+		}
+		valid = append(valid, c.ID)
+		if c.Version == "" || c.Version == "latest" {
+			return nil
+		}
+		item, err := v.item(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if !hasVersion(item, c.Version) {
+			add(field+".version", c.Version, fmt.Sprintf("unknown version %q of %s", c.Version, c.ID), suggestVersion(item, c.Version))
+		}
+		return nil
+	}
+
+	if err := check("os", def.OS, v.oses, "OS"); err != nil {
+		return err
+	}
+	seen := make(map[string]int)
+	for i, c := range def.Components {
+		field := fmt.Sprintf("components[%d]", i)
+		if first, ok := seen[c.ID]; ok && c.ID != "" {
+			add(field+".id", c.ID, fmt.Sprintf("duplicates components[%d]", first), nil)
+			continue
+		}
+		seen[c.ID] = i
+		if err := check(field, c, v.components, "component"); err != nil {
+			return err
+		}
+	}
+
+	if def.Flavor != "" && len(valid) > 0 {
+		var flavors []string
+		for _, id := range valid {
+			componentFlavors, err := v.componentFlavors(ctx, id)
+			if err != nil {
+				return err
+			}
+			flavors = append(flavors, componentFlavors...)
+		}
+		if !contains(flavors, def.Flavor) {
+			add("flavor", def.Flavor, fmt.Sprintf("unknown flavor %q for the components of the stack", def.Flavor), suggest(def.Flavor, flavors))
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (v *Validator) loadCatalog(ctx context.Context) error {
+	if v.components != nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	components, _, err := v.discovery.ComponentsList("")
+	if err != nil {
+		return fmt.Errorf("preflight: components: %v", err)
+	}
+	oses, _, err := v.discovery.OsesList("")
+	if err != nil {
+		return fmt.Errorf("preflight: OSes: %v", err)
+	}
+	v.components, v.oses = byID(components.Items), byID(oses.Items)
+	return nil
+}
+
+func (v *Validator) item(ctx context.Context, id string) (*stacksmith.Item, error) {
+	if item, ok := v.items[id]; ok {
+		return item, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, _, err := v.discovery.GetComponent(id)
+	if err != nil {
+		return nil, fmt.Errorf("preflight: component %s: %v", id, err)
+	}
+	v.items[id] = item
+	return item, nil
+}
+
+// componentFlavors returns the flavor IDs of the component id, following
+// every page.
+func (v *Validator) componentFlavors(ctx context.Context, id string) ([]string, error) {
+	if flavors, ok := v.flavors[id]; ok {
+		return flavors, nil
+	}
+	flavors := []string{}
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		list, _, err := v.discovery.GetFlavorsFrom(id, &stacksmith.PaginationParams{Page: page, PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("preflight: flavors of %s: %v", id, err)
+		}
+		for _, flavor := range list.Items {
+			flavors = append(flavors, flavor.ID)
+		}
+		if page >= list.TotalPages || len(list.Items) == 0 {
+			break
+		}
+	}
+	v.flavors[id] = flavors
+	return flavors, nil
+}
+
+func byID(items []stacksmith.Item) map[string]stacksmith.Item {
+	catalog := make(map[string]stacksmith.Item)
+	for _, item := range items {
+		catalog[item.ID] = item
+	}
+	return catalog
+}
+
+func catalogIDs(catalog map[string]stacksmith.Item) []string {
+	ids := make([]string, 0, len(catalog))
+	for id := range catalog {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// hasVersion tells whether want names a version of item, with or without
+// its revision as in "2.2.3", "2.2.3-3" or "2.2.3:3", or one of its release
+// series.
+func hasVersion(item *stacksmith.Item, want string) bool {
+	for _, iv := range item.Versions {
+		if want == iv.Version || want == version.Format(iv.Version, iv.Revision) || want == iv.Version+":"+strconv.Itoa(iv.Revision) {
+			return true
+		}
+	}
+	for _, series := range item.ReleaseSeries {
+		if want == series.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestVersion returns the versions of item closest to want, or its
+// latest version when none is close.
+func suggestVersion(item *stacksmith.Item, want string) []string {
+	var candidates []string
+	for _, iv := range item.Versions {
+		candidates = append(candidates, iv.Version)
+	}
+	for _, series := range item.ReleaseSeries {
+		candidates = append(candidates, series.Version)
+	}
+	if suggestions := suggest(want, candidates); len(suggestions) > 0 {
+		return suggestions
+	}
+	if versions := version.FromItem(item); len(versions) > 0 {
+		return []string{versions[0].Version}
+	}
+	return nil
+}
+
+// maxSuggestions bounds the suggestions of a Problem.
+const maxSuggestions = 3
+
+// suggest returns the candidates close to value: those differing only in
+// case, starting with it or within a few edits of it, closest first.
+func suggest(value string, candidates []string) []string {
+	type scored struct {
+		candidate string
+		distance  int
+	}
+	lower := strings.ToLower(value)
+	limit := len(value) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	seen := make(map[string]bool)
+	var matches []scored
+	for _, candidate := range candidates {
+		if seen[candidate] || candidate == value {
+			continue
+		}
+		seen[candidate] = true
+		c := strings.ToLower(candidate)
+		d := distance(lower, c)
+		if d <= limit || (len(lower) >= 3 && strings.HasPrefix(c, lower)) {
+			matches = append(matches, scored{candidate, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].candidate < matches[j].candidate
+	})
+	var suggestions []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].candidate)
+	}
+	return suggestions
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testServer serves a catalog of apache, ruby and postgresql on debian and
+// ubuntu, counting the requests of each path.
+func testServer() (*stacksmith.Client, map[string]int, func()) {
+	requests := make(map[string]int)
+	mux := http.NewServeMux()
+	serve := func(path, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			requests[r.URL.Path]++
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("page") == "2" {
+				fmt.Fprint(w, `{"total_entries":2,"total_pages":2,"items":[{"id":"rails"}]}`)
+				return
+			}
+			fmt.Fprint(w, body)
+		})
+	}
+	serve("/api/v1/components", `{"items":[{"id":"apache"},{"id":"ruby"},{"id":"postgresql"}]}`)
+	serve("/api/v1/oses", `{"items":[{"id":"debian"},{"id":"ubuntu"}]}`)
+	serve("/api/v1/components/apache", string(utils.GetJSON("component")))
+	serve("/api/v1/components/debian", `{"id":"debian","versions":[{"version":"wheezy","revision":7},{"version":"jessie","revision":1}]}`)
+	serve("/api/v1/components/apache/flavors", `{"total_entries":0,"total_pages":1,"items":[]}`)
+	serve("/api/v1/components/ruby/flavors", `{"total_entries":2,"total_pages":2,"items":[{"id":"ruby-base"}]}`)
+	serve("/api/v1/components/debian/flavors", `{"total_entries":0,"total_pages":1,"items":[]}`)
+	server := httptest.NewServer(mux)
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), requests, server.Close
+}
+
+func TestValidate(t *testing.T) {
+	client, requests, closeServer := testServer()
+	defer closeServer()
+	v := New(client.Discovery)
+
+	def := &stacksmith.StackDefinition{
+		Name: "My stack",
+		OS:   stacksmith.ComponentItem{ID: "debian", Version: "wheezy"},
+		Components: []stacksmith.ComponentItem{
+			{ID: "apache", Version: "2.4.17-1-3"},
+			{ID: "ruby", Version: "latest"},
+			{ID: "apache", Version: "2.4"},
+		},
+		Flavor: "rails",
+	}
+	err := v.Validate(context.Background(), def)
+	if err == nil {
+		t.Fatal("Validate of a duplicate component returned no error")
+	}
+	expected := Problems{{Field: "components[2].id", Value: "apache", Message: "duplicates components[0]"}}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("Validate returned %#v, want %#v", err, expected)
+	}
+
+	def.Components = def.Components[:2]
+	if err := v.Validate(context.Background(), def); err != nil {
+		t.Errorf("Validate of a valid definition returned %v", err)
+	}
+	def.Flavor = "rials"
+	if err := v.Validate(context.Background(), def); err == nil || err.Error() != `flavor: unknown flavor "rials" for the components of the stack, did you mean "rails"?` {
+		t.Errorf("Validate of an unknown flavor returned %v", err)
+	}
+	for path, n := range requests {
+		if n != 1 && path != "/api/v1/components/ruby/flavors" {
+			t.Errorf("%s requested %d times", path, n)
+		}
+	}
+}
+
+func TestValidate_Problems(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+
+	err := New(client.Discovery).Validate(context.Background(), &stacksmith.StackDefinition{
+		OS: stacksmith.ComponentItem{ID: "debain", Version: "wheezy"},
+		Components: []stacksmith.ComponentItem{
+			{ID: "Ruby"},
+			{ID: "postgres"},
+			{ID: "apache", Version: "2.4.71"},
+			{ID: "apache2"},
+			{ID: ""},
+		},
+		Flavor: "apache-bsae",
+	})
+	problems, ok := err.(Problems)
+	if !ok {
+		t.Fatalf("Validate returned %v", err)
+	}
+	expected := []string{
+		`name: is required`,
+		`os.id: unknown OS "debain", did you mean "debian"?`,
+		`components[0].id: unknown component "Ruby", did you mean "ruby"?`,
+		`components[1].id: unknown component "postgres", did you mean "postgresql"?`,
+		`components[2].version: unknown version "2.4.71" of apache, did you mean "2.4.17-1" or "2.4.20" or "2.4.23"?`,
+		`components[3].id: unknown component "apache2", did you mean "apache"?`,
+		`components[4].id: is required`,
+		`flavor: unknown flavor "apache-bsae" for the components of the stack`,
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Validate returned:\n%s", err)
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+
+	err := New(client.Discovery).Validate(context.Background(), &stacksmith.StackDefinition{
+		Name:       "My stack",
+		OS:         stacksmith.ComponentItem{ID: "ubuntu", Version: "16.04"},
+		Components: []stacksmith.ComponentItem{{ID: "ruby"}},
+	})
+	if _, ok := err.(Problems); ok || err == nil {
+		t.Errorf("Validate of an OS missing in Discovery returned %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := New(client.Discovery).Validate(ctx, &stacksmith.StackDefinition{}); err != context.Canceled {
+		t.Errorf("Validate with a canceled context returned %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"mysql", "mariadb", "postgresql", "php", "python", "phpmyadmin"}
+	var cases = []struct {
+		value string
+		want  []string
+	}{
+		{"mysq", []string{"mysql"}},
+		{"pyhton", []string{"python"}},
+		{"ph", []string{"php"}},
+		{"php", []string{"phpmyadmin"}},
+		{"redis", nil},
+	}
+	for _, c := range cases {
+		if got := suggest(c.value, candidates); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("suggest(%q) returned %q, want %q", c.value, got, c.want)
+		}
+	}
+}
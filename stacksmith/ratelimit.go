@@ -0,0 +1,80 @@
+package stacksmith
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitTransport is an http.RoundTripper sending at most Rate requests
+// per second on average, with bursts of up to Burst requests. Requests wait
+// for their turn until their context is done. A RateLimitTransport is safe
+// for concurrent use and must not be copied after first use.
+type RateLimitTransport struct {
+	// Base sends the requests. Nil means http.DefaultTransport.
+	Base http.RoundTripper
+	// Rate is the number of requests per second. Zero means no limit.
+	Rate float64
+	// Burst is the number of requests sent without waiting, at least 1.
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// RoundTrip sends req once the rate allows it.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Rate <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	if wait := t.reserve(time.Now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			t.release()
+			return nil, req.Context().Err()
+		}
+	}
+	return base.RoundTrip(req)
+}
+
+// reserve takes a token and returns how long to wait until it is available.
+func (t *RateLimitTransport) reserve(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	burst := float64(t.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if t.last.IsZero() {
+		t.tokens = burst
+	} else if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens += elapsed.Seconds() * t.Rate
+		if t.tokens > burst {
+			t.tokens = burst
+		}
+	}
+	t.last = now
+
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.Rate * float64(time.Second))
+}
+
+// release gives back the token of a request that was not sent.
+func (t *RateLimitTransport) release() {
+	t.mu.Lock()
+	t.tokens++
+	t.mu.Unlock()
+}
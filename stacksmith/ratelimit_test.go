@@ -0,0 +1,68 @@
+package stacksmith
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/stacks/stack1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"stack1"}`))
+	})
+
+	limited := NewClient("my_api_key", &http.Client{Transport: &RateLimitTransport{Rate: 20, Burst: 2}})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, _, err := limited.Stacks.Get("stack1"); err != nil {
+			t.Fatalf("Stacks.Get returned error: %v", err)
+		}
+	}
+	// Two requests go at once, the next two 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests at 20/s with bursts of 2 took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestRateLimitTransport_canceled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	transport := &RateLimitTransport{Rate: 0.1}
+	transport.reserve(time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", server.URL+"/stacks/stack1", nil)
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("RoundTrip returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The canceled request gives its token back.
+	if transport.tokens < -0.01 || transport.tokens > 0.01 {
+		t.Errorf("tokens = %v after a canceled request, want 0", transport.tokens)
+	}
+}
+
+func TestRateLimitTransport_reserve(t *testing.T) {
+	transport := &RateLimitTransport{Rate: 2, Burst: 3}
+	now := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	for i := 0; i < 5; i++ {
+		waits = append(waits, transport.reserve(now))
+	}
+	want := []time.Duration{0, 0, 0, 500 * time.Millisecond, time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("reserve %d waits %v, want %v", i, waits[i], want[i])
+		}
+	}
+	// After 10 seconds the bucket is full again, but no fuller than Burst.
+	if wait := transport.reserve(now.Add(10 * time.Second)); wait != 0 || transport.tokens != 2 {
+		t.Errorf("reserve after 10s waits %v with %v tokens left, want 0 with 2", wait, transport.tokens)
+	}
+}
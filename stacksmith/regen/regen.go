@@ -0,0 +1,333 @@
+// Package regen regenerates the stacks of an account that are outdated or
+// vulnerable, following rules on which stacks to touch, how many at a time
+// and when.
+//
+// A Bot scans the stacks once per Run, or every interval with Loop, and
+// reports what it did, or would do in dry-run, for each stack needing a
+// regeneration.
+package regen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+)
+
+// Config holds the rules of a Bot.
+type Config struct {
+	// Outdated regenerates the outdated stacks.
+	Outdated bool `yaml:"outdated" json:"outdated"`
+	// MinSeverity regenerates the stacks vulnerable at this severity or
+	// above: "low", "medium", "high" or "critical". Empty disables it.
+	MinSeverity string `yaml:"min_severity,omitempty" json:"min_severity,omitempty"`
+	// Include restricts the stacks to those whose name matches one of the
+	// patterns, in path.Match syntax. Empty includes all.
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	// Exclude leaves out the stacks whose name matches one of the
+	// patterns. It wins over Include.
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	// MaxPerRun bounds the regenerations of a run, 0 for no bound.
+	MaxPerRun int `yaml:"max_per_run,omitempty" json:"max_per_run,omitempty"`
+	// QuietHours is a daily period without regenerations.
+	QuietHours *QuietHours `yaml:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+	// DryRun reports the regenerations without doing them.
+	DryRun bool `yaml:"dry_run" json:"dry_run"`
+}
+
+// Validate checks the severity, patterns and quiet hours of c.
+func (c *Config) Validate() error {
+	if c.MinSeverity != "" && !vulns.KnownSeverity(c.MinSeverity) {
+		return fmt.Errorf("regen: unknown severity %q", c.MinSeverity)
+	}
+	if !c.Outdated && c.MinSeverity == "" {
+		return fmt.Errorf("regen: nothing to regenerate, enable outdated stacks or set a severity")
+	}
+	for _, pattern := range append(append([]string{}, c.Include...), c.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("regen: invalid pattern %q", pattern)
+		}
+	}
+	if c.MaxPerRun < 0 {
+		return fmt.Errorf("regen: negative maximum of regenerations")
+	}
+	if c.QuietHours != nil {
+		return c.QuietHours.validate()
+	}
+	return nil
+}
+
+// QuietHours is a daily period, like 22:00 to 06:00, in a time zone.
+type QuietHours struct {
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+	// Location is a time zone name, like "Europe/Madrid". Empty is the
+	// local time zone.
+	Location string `yaml:"location,omitempty" json:"location,omitempty"`
+}
+
+// ParseQuietHours reads quiet hours written "22:00-06:00".
+func ParseQuietHours(s string) (*QuietHours, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("regen: invalid quiet hours %q, want HH:MM-HH:MM", s)
+	}
+	q := &QuietHours{Start: strings.TrimSpace(parts[0]), End: strings.TrimSpace(parts[1])}
+	return q, q.validate()
+}
+
+func (q *QuietHours) validate() error {
+	for _, clock := range []string{q.Start, q.End} {
+		if _, err := time.Parse("15:04", clock); err != nil {
+			return fmt.Errorf("regen: invalid quiet hour %q, want HH:MM", clock)
+		}
+	}
+	if _, err := q.location(); err != nil {
+		return fmt.Errorf("regen: %v", err)
+	}
+	return nil
+}
+
+func (q *QuietHours) location() (*time.Location, error) {
+	if q.Location == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(q.Location)
+}
+
+// Contains tells whether t falls in the quiet hours. Periods ending before
+// they start span midnight; equal bounds are empty.
+func (q *QuietHours) Contains(t time.Time) bool {
+	loc, err := q.location()
+	if err != nil {
+		return false
+	}
+	start, err1 := time.Parse("15:04", q.Start)
+	end, err2 := time.Parse("15:04", q.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from <= to {
+		return from <= now && now < to
+	}
+	return now >= from || now < to
+}
+
+func (q *QuietHours) String() string {
+	return q.Start + "-" + q.End
+}
+
+// Actions taken on a stack.
+const (
+	Regenerated     = "regenerated"
+	WouldRegenerate = "would regenerate"
+	Skipped         = "skipped"
+	Failed          = "failed"
+)
+
+// Report is the outcome of a run.
+type Report struct {
+	Time   time.Time `json:"time"`
+	DryRun bool      `json:"dry_run"`
+	// Quiet tells the run fell in the quiet hours and regenerated nothing.
+	Quiet bool `json:"quiet"`
+	// Stacks is the number of stacks scanned.
+	Stacks  int      `json:"stacks"`
+	Actions []Action `json:"actions"`
+}
+
+// Action is what a run did with a stack needing a regeneration.
+type Action struct {
+	StackID   string `json:"stack_id"`
+	StackName string `json:"stack_name"`
+	// Reasons tell why the stack needs a regeneration, like "outdated" or
+	// "vulnerable (high)".
+	Reasons []string `json:"reasons"`
+	Action  string   `json:"action"`
+	// Detail tells why the stack was skipped or the regeneration failed.
+	Detail string `json:"detail,omitempty"`
+	// Status is the generation started by Stacks.Regenerate.
+	Status *stacksmith.StatusGeneration `json:"status,omitempty"`
+}
+
+// Bot regenerates stacks.
+type Bot struct {
+	client *stacksmith.Client
+	config Config
+	// Now returns the time of a run, time.Now when nil.
+	Now func() time.Time
+}
+
+// New returns a Bot following config.
+func New(client *stacksmith.Client, config *Config) (*Bot, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Bot{client: client, config: *config}, nil
+}
+
+func (b *Bot) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+// candidate is a stack needing a regeneration.
+type candidate struct {
+	stack   stacksmith.StackItem
+	reasons []string
+	rank    int
+}
+
+// Run scans the stacks and regenerates those needing it, the most
+// vulnerable first. Failed regenerations are reported as actions; the
+// error returned is for the listing of stacks or ctx.
+func (b *Bot) Run(ctx context.Context) (*Report, error) {
+	report := &Report{Time: b.now(), DryRun: b.config.DryRun, Actions: []Action{}}
+	if q := b.config.QuietHours; q != nil && q.Contains(report.Time) {
+		report.Quiet = true
+		return report, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	items, _, err := b.client.Stacks.ListAllContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	report.Stacks = len(items)
+
+	var candidates []candidate
+	for _, item := range items {
+		if c, ok := b.candidate(item); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank > candidates[j].rank
+		}
+		return candidates[i].stack.Name < candidates[j].stack.Name
+	})
+
+	regenerations := 0
+	for _, c := range candidates {
+		a := Action{StackID: c.stack.ID, StackName: c.stack.Name, Reasons: c.reasons}
+		switch excluded := b.excluded(c.stack.Name); {
+		case excluded != "":
+			a.Action, a.Detail = Skipped, excluded
+		case b.config.MaxPerRun > 0 && regenerations >= b.config.MaxPerRun:
+			a.Action, a.Detail = Skipped, fmt.Sprintf("limit of %d regenerations per run reached", b.config.MaxPerRun)
+		case b.config.DryRun:
+			a.Action = WouldRegenerate
+			regenerations++
+		default:
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			status, _, err := b.client.Stacks.RegenerateContext(ctx, c.stack.ID)
+			if err != nil && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				a.Action, a.Detail = Failed, err.Error()
+				break
+			}
+			a.Action, a.Status = Regenerated, status
+			regenerations++
+		}
+		report.Actions = append(report.Actions, a)
+	}
+	return report, nil
+}
+
+// candidate tells whether item needs a regeneration and why.
+func (b *Bot) candidate(item stacksmith.StackItem) (candidate, bool) {
+	c := candidate{stack: item}
+	if b.config.Outdated && item.Outdated {
+		c.reasons = append(c.reasons, "outdated")
+	}
+	if b.config.MinSeverity != "" && item.Vulnerabilities.Vulnerable {
+		if r := vulns.Rank(item.Vulnerabilities.Severity); r >= vulns.Rank(b.config.MinSeverity) {
+			c.reasons = append(c.reasons, fmt.Sprintf("vulnerable (%s)", item.Vulnerabilities.Severity))
+			c.rank = r
+		}
+	}
+	return c, len(c.reasons) > 0
+}
+
+// excluded tells why the filters leave out the stack named name, empty when
+// they do not.
+func (b *Bot) excluded(name string) string {
+	for _, pattern := range b.config.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return fmt.Sprintf("excluded by %q", pattern)
+		}
+	}
+	if len(b.config.Include) == 0 {
+		return ""
+	}
+	for _, pattern := range b.config.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return ""
+		}
+	}
+	return "not included"
+}
+
+// Loop runs b every interval, the first time right away, until ctx is done,
+// passing each report or error to fn.
+func (b *Bot) Loop(ctx context.Context, interval time.Duration, fn func(*Report, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := b.Run(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fn(report, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WriteText writes r as one line per action, suited to logs.
+func WriteText(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	stamp := r.Time.UTC().Format(time.RFC3339)
+	dryRun := ""
+	if r.DryRun {
+		dryRun = ", dry run"
+	}
+	switch {
+	case r.Quiet:
+		fmt.Fprintf(tw, "%s\tquiet hours, nothing regenerated\n", stamp)
+	default:
+		fmt.Fprintf(tw, "%s\t%d stacks scanned, %d to regenerate%s\n", stamp, r.Stacks, len(r.Actions), dryRun)
+	}
+	for _, a := range r.Actions {
+		detail := a.Detail
+		if a.Status != nil {
+			detail = "generation " + a.Status.ID
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", stamp, a.Action, a.StackID, a.StackName, strings.Join(a.Reasons, ", "), detail)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,225 @@
+package regen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testServer lists five stacks and regenerates them, failing for s5. The
+// regenerated stacks are recorded in order.
+func testServer() (*stacksmith.Client, func() []string, func()) {
+	var mu sync.Mutex
+	var regenerated []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/stacks/"), "/regenerate")
+		if id == "" {
+			fmt.Fprint(w, `{"total_entries":5,"total_pages":1,"items":[
+				{"id":"s1","name":"web-prod","outdated":true},
+				{"id":"s2","name":"web-staging","outdated":false,"vulnerabilities":{"vulnerable":true,"severity":"high"}},
+				{"id":"s3","name":"db-prod","outdated":false,"vulnerabilities":{"vulnerable":true,"severity":"low"}},
+				{"id":"s4","name":"sandbox","outdated":true,"vulnerabilities":{"vulnerable":true,"severity":"critical"}},
+				{"id":"s5","name":"api-prod","outdated":true}]}`)
+			return
+		}
+		if r.Method != "POST" {
+			http.Error(w, "unexpected "+r.Method, http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		regenerated = append(regenerated, id)
+		mu.Unlock()
+		if id == "s5" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"status":"500","error":"Internal error"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id":"new-%s","stack_url":"https://stacksmith.bitnami.com/api/v1/stacks/new-%s"}`, id, id)
+	})
+	server := httptest.NewServer(mux)
+	calls := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, regenerated...)
+	}
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), calls, server.Close
+}
+
+func actions(report *Report) []string {
+	var got []string
+	for _, a := range report.Actions {
+		s := a.Action + " " + a.StackID + " " + strings.Join(a.Reasons, ",")
+		if a.Detail != "" {
+			s += " (" + a.Detail + ")"
+		}
+		if a.Status != nil {
+			s += " -> " + a.Status.ID
+		}
+		got = append(got, s)
+	}
+	return got
+}
+
+func TestRun(t *testing.T) {
+	client, regenerated, closeServer := testServer()
+	defer closeServer()
+
+	bot, err := New(client, &Config{Outdated: true, MinSeverity: "high", Exclude: []string{"sandbox"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	report, err := bot.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	expected := []string{
+		"skipped s4 outdated,vulnerable (critical) (excluded by \"sandbox\")",
+		"regenerated s2 vulnerable (high) -> new-s2",
+		"failed s5 outdated (stacksmith: 500 Internal error)",
+		"regenerated s1 outdated -> new-s1",
+	}
+	if got := actions(report); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Run returned %q, want %q", got, expected)
+	}
+	if got := regenerated(); !reflect.DeepEqual(got, []string{"s2", "s5", "s1"}) {
+		t.Errorf("Run regenerated %v", got)
+	}
+	if report.Stacks != 5 || report.Quiet || report.DryRun {
+		t.Errorf("Run returned %+v", report)
+	}
+}
+
+func TestRun_CanceledInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			fmt.Fprint(w, `{"total_entries":1,"total_pages":1,"items":[{"id":"s1","name":"web-prod","outdated":true}]}`)
+			return
+		}
+		// Cancel while the stack is being regenerated.
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	bot, _ := New(stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), &Config{Outdated: true})
+	start := time.Now()
+	if _, err := bot.Run(ctx); err != context.Canceled {
+		t.Errorf("Run canceled during a regeneration returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run canceled during a regeneration returned after %s", elapsed)
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	client, regenerated, closeServer := testServer()
+	defer closeServer()
+
+	bot, _ := New(client, &Config{Outdated: true, Include: []string{"*-prod", "sandbox"}, MaxPerRun: 2, DryRun: true})
+	report, err := bot.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	expected := []string{
+		"would regenerate s5 outdated",
+		"would regenerate s4 outdated",
+		"skipped s1 outdated (limit of 2 regenerations per run reached)",
+	}
+	if got := actions(report); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Run returned %q, want %q", got, expected)
+	}
+	if len(regenerated()) != 0 {
+		t.Errorf("Run in dry-run regenerated %v", regenerated())
+	}
+
+	var buf bytes.Buffer
+	bot.Now = func() time.Time { return time.Date(2016, 9, 23, 15, 0, 0, 0, time.UTC) }
+	report, _ = bot.Run(context.Background())
+	WriteText(&buf, report)
+	if !strings.HasPrefix(buf.String(), "2016-09-23T15:00:00Z  5 stacks scanned, 3 to regenerate, dry run\n2016-09-23T15:00:00Z  would regenerate  s5  api-prod  outdated") {
+		t.Errorf("WriteText wrote:\n%s", buf.String())
+	}
+}
+
+func TestRun_QuietHours(t *testing.T) {
+	client, regenerated, closeServer := testServer()
+	defer closeServer()
+
+	quiet, err := ParseQuietHours("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiet.Location = "UTC"
+	bot, _ := New(client, &Config{Outdated: true, QuietHours: quiet})
+	bot.Now = func() time.Time { return time.Date(2016, 9, 23, 23, 30, 0, 0, time.UTC) }
+	report, err := bot.Run(context.Background())
+	if err != nil || !report.Quiet || len(report.Actions) != 0 || len(regenerated()) != 0 {
+		t.Errorf("Run in quiet hours returned %+v, %v", report, err)
+	}
+
+	for clock, want := range map[string]bool{"21:59": false, "22:00": true, "02:00": true, "06:00": false, "12:00": false} {
+		at, _ := time.Parse("15:04", clock)
+		if got := quiet.Contains(at); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", clock, got, want)
+		}
+	}
+	day := &QuietHours{Start: "09:00", End: "17:00", Location: "UTC"}
+	if !day.Contains(time.Date(2016, 9, 23, 9, 0, 0, 0, time.UTC)) || day.Contains(time.Date(2016, 9, 23, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("Contains is wrong for daytime quiet hours")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, c := range []*Config{
+		{},
+		{MinSeverity: "severe"},
+		{Outdated: true, Include: []string{"[web"}},
+		{Outdated: true, MaxPerRun: -1},
+		{Outdated: true, QuietHours: &QuietHours{Start: "22h", End: "06:00"}},
+		{Outdated: true, QuietHours: &QuietHours{Start: "22:00", End: "06:00", Location: "Mars/Olympus"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) returned no error", c)
+		}
+	}
+	if _, err := ParseQuietHours("22:00"); err == nil {
+		t.Errorf("ParseQuietHours of a single hour returned no error")
+	}
+}
+
+func TestLoop(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+	bot, _ := New(client, &Config{Outdated: true, DryRun: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	err := bot.Loop(ctx, time.Millisecond, func(report *Report, err error) {
+		if err != nil || len(report.Actions) != 3 {
+			t.Errorf("Loop passed %+v, %v", report, err)
+		}
+		if runs++; runs == 3 {
+			cancel()
+		}
+	})
+	if err != context.Canceled || runs != 3 {
+		t.Errorf("Loop returned %v after %d runs", err, runs)
+	}
+}
@@ -0,0 +1,125 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed field selector, a subset of JSONPath: "{.items[*].name}",
+// ".vulnerabilities.severity" or "versions[0].version". Braces and the
+// leading dot are optional.
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// segment is a field name, an index, or every element when wildcard is set.
+type segment struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParsePath parses a field selector.
+func ParsePath(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("render: unbalanced braces in %q", expr)
+		}
+		s = s[1 : len(s)-1]
+	}
+	s = strings.TrimPrefix(s, "$")
+
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("render: unbalanced brackets in %q", expr)
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			if inner == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("render: bad index %q in %q", inner, expr)
+			}
+			p.segments = append(p.segments, segment{index: index, isIndex: true})
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			field := s[:end]
+			s = s[end:]
+			if field == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{field: field})
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Select returns the values found at the path in doc, a value decoded from JSON.
+func (p *Path) Select(doc interface{}) []interface{} {
+	values := []interface{}{doc}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, seg.apply(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (seg segment) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, 0, len(keys))
+			for _, k := range keys {
+				values = append(values, v[k])
+			}
+			return values
+		}
+		if field, ok := v[seg.field]; ok && !seg.isIndex {
+			return []interface{}{field}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			return v
+		}
+		if seg.isIndex {
+			index := seg.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []interface{}{v[index]}
+			}
+		}
+	}
+	return nil
+}
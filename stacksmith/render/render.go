@@ -0,0 +1,129 @@
+// Package render prints API results as tables, JSON, YAML, Go templates or
+// the values picked by a JSONPath-like expression.
+//
+// Every format works on the JSON form of the result, so fields are named as
+// in the API: "id", "vulnerabilities.severity", "items[*].name"...
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Formats understood by Render.
+const (
+	Table    = "table"
+	JSON     = "json"
+	YAML     = "yaml"
+	Template = "template"
+	JSONPath = "jsonpath"
+)
+
+// Options selects how a result is rendered.
+type Options struct {
+	Format string
+	// Columns are the field paths shown by Table. Empty means the columns
+	// registered for the type of the result.
+	Columns []string
+	// Template is the text/template used by Template.
+	Template string
+	// Path is the expression used by JSONPath, such as "{.items[*].id}".
+	Path string
+}
+
+// ParseFormat reads a format as given on a command line: "table", "json",
+// "yaml", "template=TEMPLATE" or "jsonpath=EXPRESSION".
+func ParseFormat(format string) (Options, error) {
+	name, arg := format, ""
+	if i := strings.Index(format, "="); i >= 0 {
+		name, arg = format[:i], format[i+1:]
+	}
+	switch name {
+	case Table, JSON, YAML:
+		if arg != "" {
+			return Options{}, fmt.Errorf("render: format %s takes no argument", name)
+		}
+		return Options{Format: name}, nil
+	case Template:
+		return Options{Format: name, Template: arg}, nil
+	case JSONPath:
+		return Options{Format: name, Path: arg}, nil
+	}
+	return Options{}, fmt.Errorf("render: unknown format %q", format)
+}
+
+// Render writes v to w as opts says.
+func Render(w io.Writer, v interface{}, opts Options) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case "", Table:
+		doc, err := decode(data)
+		if err != nil {
+			return err
+		}
+		columns := opts.Columns
+		if len(columns) == 0 {
+			columns = columnsFor(v, doc)
+		}
+		return writeTable(w, rows(doc), columns)
+	case JSON:
+		indented, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", indented)
+		return err
+	case YAML:
+		doc, err := decodeOrdered(data)
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case Template:
+		tmpl, err := template.New("render").Option("missingkey=zero").Parse(opts.Template)
+		if err != nil {
+			return fmt.Errorf("render: %v", err)
+		}
+		doc, err := decode(data)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, doc)
+	case JSONPath:
+		path, err := ParsePath(opts.Path)
+		if err != nil {
+			return err
+		}
+		doc, err := decode(data)
+		if err != nil {
+			return err
+		}
+		for _, value := range path.Select(doc) {
+			if _, err := fmt.Fprintln(w, cell(value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("render: unknown format %q", opts.Format)
+}
+
+func decode(data []byte) (interface{}, error) {
+	var doc interface{}
+	err := json.Unmarshal(data, &doc)
+	return doc, err
+}
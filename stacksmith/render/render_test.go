@@ -0,0 +1,112 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+func render(t *testing.T, v interface{}, opts Options) string {
+	buf := new(bytes.Buffer)
+	if err := Render(buf, v, opts); err != nil {
+		t.Fatalf("Render(%+v) returned error: %v", opts, err)
+	}
+	return buf.String()
+}
+
+func TestRender_Table(t *testing.T) {
+	vulnerabilities := new(stacksmith.Vulnerability)
+	json.Unmarshal(utils.GetJSON("vulnerabilities"), vulnerabilities)
+	vulnerabilities.Items = vulnerabilities.Items[:3]
+
+	expected := `NAME           SEVERITY  RANGES.COMPONENT
+CVE-2015-7551  medium    ruby
+CVE-2015-3197  low       ruby
+CVE-2016-0800  high      ruby
+`
+	if got := render(t, vulnerabilities, Options{Format: Table}); got != expected {
+		t.Errorf("Render table returned\n%s\nwant\n%s", got, expected)
+	}
+
+	expected = `NAME           RANGES.TO
+CVE-2015-7551  2.2.3
+CVE-2015-3197  2.2.4:1
+CVE-2016-0800  2.2.4:3
+`
+	if got := render(t, vulnerabilities, Options{Columns: []string{"name", "ranges[*].to"}}); got != expected {
+		t.Errorf("Render table with columns returned\n%s\nwant\n%s", got, expected)
+	}
+
+	// Types without registered columns show their scalar fields.
+	status := &stacksmith.StatusDeletion{ID: "stack1", Deleted: true}
+	expected = "DELETED  ID\ntrue     stack1\n"
+	if got := render(t, status, Options{Format: Table}); got != expected {
+		t.Errorf("Render table returned\n%s\nwant\n%s", got, expected)
+	}
+}
+
+func TestRender_Formats(t *testing.T) {
+	hooks := &stacksmith.HooksList{TotalEntries: 2, TotalPages: 1, Items: []stacksmith.Hook{
+		{ID: "h1", URL: "https://example.com/1"},
+		{ID: "h2", URL: "https://example.com/2"},
+	}}
+
+	var cases = []struct {
+		format   string
+		expected string
+	}{
+		{"json", `{
+  "total_entries": 2,
+  "total_pages": 1,
+  "items": [
+    {
+      "id": "h1",
+      "url": "https://example.com/1"
+    },
+    {
+      "id": "h2",
+      "url": "https://example.com/2"
+    }
+  ]
+}
+`},
+		{"yaml", `total_entries: 2
+total_pages: 1
+items:
+- id: h1
+  url: https://example.com/1
+- id: h2
+  url: https://example.com/2
+`},
+		{`template={{range .items}}{{.id}} {{.url}}{{"\n"}}{{end}}`, "h1 https://example.com/1\nh2 https://example.com/2\n"},
+		{"jsonpath={.items[*].url}", "https://example.com/1\nhttps://example.com/2\n"},
+		{"jsonpath=items[-1].id", "h2\n"},
+		{"jsonpath={.total_entries}", "2\n"},
+	}
+
+	for _, c := range cases {
+		opts, err := ParseFormat(c.format)
+		if err != nil {
+			t.Fatalf("ParseFormat(%q) returned error: %v", c.format, err)
+		}
+		if got := render(t, hooks, opts); got != c.expected {
+			t.Errorf("Render %s returned\n%s\nwant\n%s", c.format, got, c.expected)
+		}
+	}
+}
+
+func TestParseFormat_Errors(t *testing.T) {
+	for _, format := range []string{"xml", "json=pretty"} {
+		if _, err := ParseFormat(format); err == nil {
+			t.Errorf("ParseFormat(%q) returned no error", format)
+		}
+	}
+	for _, expr := range []string{"{.items", ".items[", ".items[x]"} {
+		if _, err := ParsePath(expr); err == nil {
+			t.Errorf("ParsePath(%q) returned no error", expr)
+		}
+	}
+}
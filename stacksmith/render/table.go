@@ -0,0 +1,175 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+var (
+	columnsMu sync.RWMutex
+	columns   = make(map[reflect.Type][]string)
+)
+
+func init() {
+	stackColumns := []string{"id", "name", "status", "outdated", "vulnerabilities.severity"}
+	RegisterColumns(stacksmith.StacksList{}, stackColumns...)
+	RegisterColumns([]stacksmith.StackItem{}, stackColumns...)
+	RegisterColumns(stacksmith.Stack{}, "id", "name", "status", "outdated", "vulnerabilities.severity", "flavor.id", "os.id", "os.version")
+	RegisterColumns(stacksmith.Vulnerability{}, "name", "severity", "ranges[*].component")
+	RegisterColumns(stacksmith.HooksList{}, "id", "url")
+	RegisterColumns([]stacksmith.Hook{}, "id", "url")
+	RegisterColumns(stacksmith.ListItems{}, "id", "name", "category", "versions[0].version")
+	RegisterColumns(stacksmith.Item{}, "id", "name", "category", "versions[0].version")
+	RegisterColumns(stacksmith.Changelog{}, "version", "revision", "branch", "published_at")
+	RegisterColumns(stacksmith.Flavors{}, "id", "name", "default", "description")
+	RegisterColumns(stacksmith.SlackChannels{}, "id", "slack_channel")
+}
+
+// RegisterColumns sets the default table columns of the type of sample.
+func RegisterColumns(sample interface{}, paths ...string) {
+	columnsMu.Lock()
+	defer columnsMu.Unlock()
+	columns[indirect(reflect.TypeOf(sample))] = paths
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// columnsFor returns the registered columns for v, or else the scalar
+// fields of its rows.
+func columnsFor(v interface{}, doc interface{}) []string {
+	if v != nil {
+		columnsMu.RLock()
+		registered, ok := columns[indirect(reflect.TypeOf(v))]
+		columnsMu.RUnlock()
+		if ok {
+			return registered
+		}
+	}
+
+	seen := make(map[string]bool)
+	var fields []string
+	for _, row := range rows(doc) {
+		object, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, value := range object {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				continue
+			}
+			if !seen[k] {
+				seen[k] = true
+				fields = append(fields, k)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// rows returns the entries of a list result, the elements of an array, or
+// the result itself.
+func rows(doc interface{}) []interface{} {
+	switch v := doc.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		if items, ok := v["items"].([]interface{}); ok {
+			return items
+		}
+	}
+	return []interface{}{doc}
+}
+
+func writeTable(w io.Writer, entries []interface{}, paths []string) error {
+	selectors := make([]*Path, len(paths))
+	headers := make([]string, len(paths))
+	for i, p := range paths {
+		selector, err := ParsePath(p)
+		if err != nil {
+			return err
+		}
+		selectors[i] = selector
+		headers[i] = header(p)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, entry := range entries {
+		cells := make([]string, len(selectors))
+		for i, selector := range selectors {
+			cells[i] = joinCells(selector.Select(entry))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// header turns "vulnerabilities.severity" into "VULNERABILITIES.SEVERITY"
+// and "versions[0].version" into "VERSIONS.VERSION".
+func header(path string) string {
+	path = strings.Trim(path, "{}.$")
+	for strings.Contains(path, "[") {
+		start := strings.Index(path, "[")
+		end := strings.Index(path[start:], "]")
+		if end < 0 {
+			break
+		}
+		path = path[:start] + path[start+end+1:]
+	}
+	return strings.ToUpper(path)
+}
+
+func joinCells(values []interface{}) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = cell(value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// cell formats one value: scalars as is, arrays of scalars comma separated
+// and anything else as compact JSON.
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		scalars := true
+		for _, element := range v {
+			switch element.(type) {
+			case map[string]interface{}, []interface{}:
+				scalars = false
+			}
+		}
+		if scalars {
+			return joinCells(v)
+		}
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,307 @@
+// Package resolve turns stack definitions whose versions are constraints,
+// like "~7.0", ">=5.6 <7" or "5.7.x", into definitions naming exact
+// versions, explaining each choice.
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+)
+
+// Strategy picks a version among those matching a constraint.
+type Strategy string
+
+// Strategies.
+const (
+	// Newest picks the newest matching version.
+	Newest Strategy = "newest"
+	// PreferSafe picks the newest matching version that no known
+	// vulnerability affects, or the newest when all are affected.
+	PreferSafe Strategy = "prefer-safe"
+)
+
+// Options tunes a Resolver.
+type Options struct {
+	// Strategy defaults to Newest.
+	Strategy Strategy
+	// Branch restricts the versions to a branch, like "stable". Empty
+	// allows every branch.
+	Branch string
+	// Before ignores the versions published after it, to resolve a
+	// definition as it would have been at that time. Zero ignores none.
+	Before time.Time
+	// Vulnerabilities are the known vulnerabilities PreferSafe avoids, as
+	// returned by Stacks.GetAllVulnerabilities. PreferSafe needs them: nil
+	// fails with ErrNoVulnerabilities, while an empty slice tells none are
+	// known.
+	Vulnerabilities []stacksmith.VulnerabilityItem
+}
+
+// ErrNoVulnerabilities is returned by Resolve for the PreferSafe strategy
+// without Options.Vulnerabilities.
+var ErrNoVulnerabilities = errors.New("resolve: prefer-safe needs the known vulnerabilities")
+
+// Resolver resolves definitions, looking up each component once.
+type Resolver struct {
+	discovery *stacksmith.DiscoveryService
+	opts      Options
+	items     map[string]*stacksmith.Item
+}
+
+// New returns a Resolver looking up components in discovery.
+func New(discovery *stacksmith.DiscoveryService, opts *Options) *Resolver {
+	r := &Resolver{discovery: discovery, items: make(map[string]*stacksmith.Item)}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Strategy == "" {
+		r.opts.Strategy = Newest
+	}
+	return r
+}
+
+// Resolution is a resolved definition and how it was resolved.
+type Resolution struct {
+	// Definition names exact versions and is ready for Stacks.Create.
+	Definition *stacksmith.StackDefinition `json:"definition"`
+	Choices    []Choice                    `json:"choices"`
+}
+
+// Choice explains the version chosen for the OS or a component.
+type Choice struct {
+	// Field is the JSON path of the component, like "components[1]".
+	Field       string `json:"field"`
+	ID          string `json:"id"`
+	Constraint  string `json:"constraint"`
+	Version     string `json:"version"`
+	Revision    int    `json:"revision"`
+	Branch      string `json:"branch,omitempty"`
+	PublishedAt string `json:"published_at,omitempty"`
+	// Candidates is the number of versions matching Constraint.
+	Candidates int `json:"candidates"`
+	// Vulnerabilities names the known vulnerabilities of Version.
+	Vulnerabilities []string `json:"vulnerabilities,omitempty"`
+	Reason          string   `json:"reason"`
+}
+
+// Unsatisfiable is the error returned for components no version satisfies.
+type Unsatisfiable []string
+
+func (u Unsatisfiable) Error() string {
+	return "resolve: " + strings.Join(u, "; ")
+}
+
+// Resolve returns def with the version of its OS and components replaced
+// by the version chosen among those matching it. A version may be a
+// constraint, the name of a release series of the component, "latest" or
+// empty for any version. It fails with Unsatisfiable when a constraint
+// matches no version.
+func (r *Resolver) Resolve(ctx context.Context, def *stacksmith.StackDefinition) (*Resolution, error) {
+	if r.opts.Strategy == PreferSafe && r.opts.Vulnerabilities == nil {
+		return nil, ErrNoVulnerabilities
+	}
+	resolved := &stacksmith.StackDefinition{Name: def.Name, Flavor: def.Flavor}
+	res := &Resolution{Definition: resolved, Choices: []Choice{}}
+	var unsatisfiable Unsatisfiable
+	pick := func(field string, c stacksmith.ComponentItem) (stacksmith.ComponentItem, error) {
+		choice, err := r.choose(ctx, field, c)
+		if err != nil {
+			if _, ok := err.(unsatisfied); ok {
+				unsatisfiable = append(unsatisfiable, err.Error())
+				return c, nil
+			}
+			return c, err
+		}
+		res.Choices = append(res.Choices, *choice)
+		return stacksmith.ComponentItem{ID: c.ID, Version: r.definitionVersion(choice)}, nil
+	}
+
+	var err error
+	if def.OS.ID != "" {
+		if resolved.OS, err = pick("os", def.OS); err != nil {
+			return nil, err
+		}
+	}
+	for i, c := range def.Components {
+		item, err := pick(fmt.Sprintf("components[%d]", i), c)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Components = append(resolved.Components, item)
+	}
+	if len(unsatisfiable) > 0 {
+		return nil, unsatisfiable
+	}
+	return res, nil
+}
+
+// unsatisfied is a constraint matching no version.
+type unsatisfied string
+
+func (u unsatisfied) Error() string {
+	return string(u)
+}
+
+// candidate is a version of a component.
+type candidate struct {
+	version     version.Version
+	publishedAt string
+}
+
+func (r *Resolver) choose(ctx context.Context, field string, c stacksmith.ComponentItem) (*Choice, error) {
+	item, err := r.item(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	match, describe, err := matcher(item, c.Version)
+	if err != nil {
+		return nil, fmt.Errorf("resolve: %s: %v", field, err)
+	}
+
+	var candidates []candidate
+	for _, iv := range item.Versions {
+		if r.opts.Branch != "" && iv.Branch != r.opts.Branch {
+			continue
+		}
+		if !r.opts.Before.IsZero() {
+			if published, err := time.Parse(time.RFC3339, iv.PublishedAt); err == nil && published.After(r.opts.Before) {
+				continue
+			}
+		}
+		v, err := version.New(iv.Version, iv.Revision)
+		if err != nil || !match(v) {
+			continue
+		}
+		v.Branch = iv.Branch
+		candidates = append(candidates, candidate{version: v, publishedAt: iv.PublishedAt})
+	}
+	if len(candidates) == 0 {
+		return nil, unsatisfied(fmt.Sprintf("%s: no version of %s matches %s%s", field, c.ID, describe, r.restrictions()))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if cmp := version.Compare(candidates[i].version, candidates[j].version); cmp != 0 {
+			return cmp > 0
+		}
+		return candidates[i].publishedAt > candidates[j].publishedAt
+	})
+
+	chosen := candidates[0]
+	reason := fmt.Sprintf("newest of %d versions matching %s%s", len(candidates), describe, r.restrictions())
+	if len(candidates) == 1 {
+		reason = fmt.Sprintf("only version matching %s%s", describe, r.restrictions())
+	}
+	affected := r.affecting(c.ID, chosen.version)
+	if r.opts.Strategy == PreferSafe && len(affected) > 0 {
+		safe := -1
+		for i, candidate := range candidates {
+			if len(r.affecting(c.ID, candidate.version)) == 0 {
+				safe = i
+				break
+			}
+		}
+		switch {
+		case safe < 0 && len(candidates) == 1:
+			reason += ", which has known vulnerabilities"
+		case safe < 0:
+			reason += ", every one with known vulnerabilities"
+		default:
+			reason = fmt.Sprintf("newest of %d versions matching %s%s with no known vulnerabilities, skipping %s (%s)",
+				len(candidates), describe, r.restrictions(), chosen.version.Full(), strings.Join(affected, ", "))
+			chosen, affected = candidates[safe], nil
+		}
+	}
+
+	return &Choice{
+		Field:           field,
+		ID:              c.ID,
+		Constraint:      c.Version,
+		Version:         chosen.version.Version,
+		Revision:        chosen.version.Revision,
+		Branch:          chosen.version.Branch,
+		PublishedAt:     chosen.publishedAt,
+		Candidates:      len(candidates),
+		Vulnerabilities: affected,
+		Reason:          reason,
+	}, nil
+}
+
+// definitionVersion returns the version naming choice in a definition.
+// Stacksmith takes a bare version as its newest revision, so an older
+// revision, like one PreferSafe or Before fell back to, is written in
+// full, "7.0.10-1".
+func (r *Resolver) definitionVersion(choice *Choice) string {
+	for _, iv := range r.items[choice.ID].Versions {
+		if iv.Version == choice.Version && iv.Revision > choice.Revision {
+			return version.Format(choice.Version, choice.Revision)
+		}
+	}
+	return choice.Version
+}
+
+// restrictions describes the options restricting the versions.
+func (r *Resolver) restrictions() string {
+	s := ""
+	if r.opts.Branch != "" {
+		s += " in branch " + r.opts.Branch
+	}
+	if !r.opts.Before.IsZero() {
+		s += " published before " + r.opts.Before.UTC().Format("2006-01-02")
+	}
+	return s
+}
+
+// affecting names the known vulnerabilities affecting v of component.
+func (r *Resolver) affecting(component string, v version.Version) []string {
+	var names []string
+	for _, vulnerability := range vulns.Affecting(r.opts.Vulnerabilities, component, v) {
+		names = append(names, vulnerability.Name)
+	}
+	return names
+}
+
+// matcher returns the function matching the versions of item allowed by
+// constraint, and a description of them.
+func matcher(item *stacksmith.Item, constraint string) (func(version.Version) bool, string, error) {
+	constraint = strings.TrimSpace(constraint)
+	switch constraint {
+	case "", "latest", "*":
+		return func(version.Version) bool { return true }, "any version", nil
+	}
+	for _, series := range item.ReleaseSeries {
+		if series.Version != constraint {
+			continue
+		}
+		return func(v version.Version) bool {
+			s, ok := version.ReleaseSeries(item, v)
+			return ok && s == constraint
+		}, "release series " + constraint, nil
+	}
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return nil, "", err
+	}
+	return c.Check, constraint, nil
+}
+
+func (r *Resolver) item(ctx context.Context, id string) (*stacksmith.Item, error) {
+	if item, ok := r.items[id]; ok {
+		return item, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, _, err := r.discovery.GetComponent(id)
+	if err != nil {
+		return nil, fmt.Errorf("resolve: component %s: %v", id, err)
+	}
+	r.items[id] = item
+	return item, nil
+}
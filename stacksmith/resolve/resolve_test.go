@@ -0,0 +1,234 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+// testServer serves apache from the fixtures, php, openssl and debian, counting the
+// requests of each component.
+func testServer() (*stacksmith.Client, map[string]int, func()) {
+	requests := make(map[string]int)
+	components := map[string]string{
+		"apache": string(utils.GetJSON("component")),
+		"php": `{"id":"php","versions":[
+			{"version":"7.1.0-rc1","revision":0,"branch":"testing","published_at":"2016-09-01T10:00:00.000Z"},
+			{"version":"7.0.10","revision":2,"branch":"stable","published_at":"2016-08-25T10:00:00.000Z"},
+			{"version":"7.0.10","revision":1,"branch":"stable","published_at":"2016-08-20T10:00:00.000Z"},
+			{"version":"7.0.9","revision":5,"branch":"stable","published_at":"2016-08-10T10:00:00.000Z"},
+			{"version":"5.6.24","revision":0,"branch":"stable","published_at":"2016-07-22T10:00:00.000Z"}]}`,
+		"openssl": `{"id":"openssl","versions":[
+			{"version":"1.0.2","revision":0,"branch":"stable"},
+			{"version":"1.0.2a","revision":0,"branch":"stable"},
+			{"version":"1.0.2h","revision":0,"branch":"stable"},
+			{"version":"1.1.0-pre5","revision":0,"branch":"testing"}]}`,
+		"debian": `{"id":"debian","versions":[
+			{"version":"jessie","revision":8,"branch":"stable"},
+			{"version":"wheezy","revision":9,"branch":"stable"}]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/api/v1/components/"):]
+		requests[id]++
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := components[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status":"404","error":"Not found"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	})
+	server := httptest.NewServer(mux)
+	return stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL)), requests, server.Close
+}
+
+func TestResolve(t *testing.T) {
+	client, requests, closeServer := testServer()
+	defer closeServer()
+	r := New(client.Discovery, nil)
+
+	def := &stacksmith.StackDefinition{
+		Name:   "My stack",
+		OS:     stacksmith.ComponentItem{ID: "debian", Version: "wheezy"},
+		Flavor: "php-base",
+		Components: []stacksmith.ComponentItem{
+			{ID: "php", Version: "~7.0"},
+			{ID: "apache", Version: "2.4"},
+			{ID: "php", Version: ">=5.6 <7"},
+			{ID: "php", Version: "latest"},
+		},
+	}
+	res, err := r.Resolve(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	expected := &stacksmith.StackDefinition{
+		Name:   "My stack",
+		OS:     stacksmith.ComponentItem{ID: "debian", Version: "wheezy"},
+		Flavor: "php-base",
+		Components: []stacksmith.ComponentItem{
+			{ID: "php", Version: "7.0.10"},
+			{ID: "apache", Version: "2.4.23"},
+			{ID: "php", Version: "5.6.24"},
+			{ID: "php", Version: "7.1.0-rc1"},
+		},
+	}
+	if !reflect.DeepEqual(res.Definition, expected) {
+		t.Errorf("Resolve returned %+v, want %+v", res.Definition, expected)
+	}
+
+	var reasons []string
+	for _, c := range res.Choices {
+		reasons = append(reasons, fmt.Sprintf("%s %s:%d %s", c.Field, c.Version, c.Revision, c.Reason))
+	}
+	expectedReasons := []string{
+		"os wheezy:9 only version matching wheezy",
+		"components[0] 7.0.10:2 newest of 3 versions matching ~7.0",
+		"components[1] 2.4.23:1 newest of 7 versions matching release series 2.4",
+		"components[2] 5.6.24:0 only version matching >=5.6 <7",
+		"components[3] 7.1.0-rc1:0 newest of 5 versions matching any version",
+	}
+	if !reflect.DeepEqual(reasons, expectedReasons) {
+		t.Errorf("Resolve explained %q, want %q", reasons, expectedReasons)
+	}
+	if requests["php"] != 1 {
+		t.Errorf("php requested %d times", requests["php"])
+	}
+}
+
+func TestResolve_Options(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+
+	var vulnerabilities stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2016-7124","severity":"high","ranges":[{"component":"php","from":"7.0","to":"7.0.10:1"}]},
+		{"name":"CVE-2016-7125","severity":"medium","ranges":[{"component":"php","from":"7.0.10:2","to":"7.0.10:2"}]}]}`), &vulnerabilities)
+	opts := &Options{
+		Strategy:        PreferSafe,
+		Branch:          "stable",
+		Before:          time.Date(2016, 8, 22, 0, 0, 0, 0, time.UTC),
+		Vulnerabilities: vulnerabilities.Items,
+	}
+	def := &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "php", Version: "latest"}}}
+
+	res, err := New(client.Discovery, opts).Resolve(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	c := res.Choices[0]
+	if c.Version != "5.6.24" || c.Reason != "newest of 3 versions matching any version in branch stable published before 2016-08-22 with no known vulnerabilities, skipping 7.0.10-1 (CVE-2016-7124)" {
+		t.Errorf("Resolve chose %+v", c)
+	}
+
+	opts.Before = time.Time{}
+	def.Components[0].Version = "~7.0"
+	res, _ = New(client.Discovery, opts).Resolve(context.Background(), def)
+	c = res.Choices[0]
+	if c.Version != "7.0.10" || c.Revision != 2 || !reflect.DeepEqual(c.Vulnerabilities, []string{"CVE-2016-7125"}) ||
+		c.Reason != "newest of 3 versions matching ~7.0 in branch stable, every one with known vulnerabilities" {
+		t.Errorf("Resolve chose %+v", c)
+	}
+}
+
+func TestResolve_Revision(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+
+	var vulnerabilities stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2016-7125","severity":"medium","ranges":[{"component":"php","from":"7.0.10:2","to":"7.0.10:2"}]}]}`), &vulnerabilities)
+	def := &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "php", Version: "~7.0"}}}
+
+	res, err := New(client.Discovery, &Options{Strategy: PreferSafe, Vulnerabilities: vulnerabilities.Items}).Resolve(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if c := res.Choices[0]; c.Version != "7.0.10" || c.Revision != 1 {
+		t.Errorf("Resolve chose %+v, want 7.0.10 revision 1", c)
+	}
+	if v := res.Definition.Components[0].Version; v != "7.0.10-1" {
+		t.Errorf("Resolve defined version %q, want 7.0.10-1", v)
+	}
+
+	// The newest revision of a version keeps the version alone.
+	res, _ = New(client.Discovery, nil).Resolve(context.Background(), def)
+	if v := res.Definition.Components[0].Version; v != "7.0.10" {
+		t.Errorf("Resolve defined version %q, want 7.0.10", v)
+	}
+}
+
+func TestResolve_LetterReleases(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+
+	var vulnerabilities stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2016-2177","severity":"high","ranges":[{"component":"openssl","from":"1.0.2h","to":"1.0.2h"}]}]}`), &vulnerabilities)
+	def := &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "openssl", Version: "~1.0"}}}
+
+	res, err := New(client.Discovery, nil).Resolve(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if c := res.Choices[0]; c.Version != "1.0.2h" || c.Candidates != 3 {
+		t.Errorf("Resolve chose %+v, want 1.0.2h of 3", c)
+	}
+
+	res, err = New(client.Discovery, &Options{Strategy: PreferSafe, Vulnerabilities: vulnerabilities.Items}).Resolve(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if c := res.Choices[0]; c.Version != "1.0.2a" || len(c.Vulnerabilities) != 0 {
+		t.Errorf("Resolve skipping 1.0.2h chose %+v, want 1.0.2a", c)
+	}
+}
+
+func TestResolve_PreferSafeWithoutVulnerabilities(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+	def := &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "php", Version: "~7.0"}}}
+
+	if _, err := New(client.Discovery, &Options{Strategy: PreferSafe}).Resolve(context.Background(), def); err != ErrNoVulnerabilities {
+		t.Errorf("Resolve returned %v, want %v", err, ErrNoVulnerabilities)
+	}
+	res, err := New(client.Discovery, &Options{Strategy: PreferSafe, Vulnerabilities: []stacksmith.VulnerabilityItem{}}).Resolve(context.Background(), def)
+	if err != nil || res.Choices[0].Version != "7.0.10" {
+		t.Errorf("Resolve with no known vulnerabilities returned %+v, %v", res, err)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	client, _, closeServer := testServer()
+	defer closeServer()
+	r := New(client.Discovery, &Options{Branch: "stable"})
+
+	_, err := r.Resolve(context.Background(), &stacksmith.StackDefinition{
+		OS:         stacksmith.ComponentItem{ID: "debian", Version: "stretch"},
+		Components: []stacksmith.ComponentItem{{ID: "php", Version: "^7.1"}},
+	})
+	expected := Unsatisfiable{
+		"os: no version of debian matches stretch in branch stable",
+		"components[0]: no version of php matches ^7.1 in branch stable",
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("Resolve returned %#v, want %#v", err, expected)
+	}
+
+	if _, err := r.Resolve(context.Background(), &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "php", Version: ">= >= 7"}}}); err == nil {
+		t.Errorf("Resolve of an invalid constraint returned no error")
+	}
+	if _, err := r.Resolve(context.Background(), &stacksmith.StackDefinition{Components: []stacksmith.ComponentItem{{ID: "rubby"}}}); err == nil {
+		t.Errorf("Resolve of an unknown component returned no error")
+	}
+}
@@ -0,0 +1,90 @@
+package stacksmith
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxBackoff caps the wait between two attempts of a RetryTransport
+// without MaxBackoff, including the waits asked by Retry-After.
+const DefaultMaxBackoff = time.Minute
+
+// RetryTransport is an http.RoundTripper retrying the idempotent requests
+// that failed on a network error or got a 429 or 5xx answer. It stops
+// waiting, and retrying, once the context of the request is done.
+type RetryTransport struct {
+	// Base sends the requests. Nil means http.DefaultTransport.
+	Base http.RoundTripper
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled on every retry.
+	// A Retry-After header sent by the API takes precedence.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between two attempts. Zero means
+	// DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// RoundTrip sends req, retrying it as configured.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !idempotent(req.Method) {
+		return base.RoundTrip(req)
+	}
+
+	maxBackoff := t.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	backoff := t.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.MaxRetries || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		wait := backoff
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+			resp.Body.Close()
+		}
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "DELETE":
+		return true
+	}
+	return false
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,89 @@
+package stacksmith
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc("/stacks/stack1", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"503","error":"Try again"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"stack1"}`))
+	})
+	mux.HandleFunc("/stacks/stack1/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"503","error":"Try again"}`))
+	})
+
+	retrying := NewClient("my_api_key", &http.Client{Transport: &RetryTransport{MaxRetries: 3, Backoff: time.Millisecond}})
+	stack, _, err := retrying.Stacks.Get("stack1")
+	if err != nil || stack.ID != "stack1" || attempts != 3 {
+		t.Errorf("Stacks.Get returned %+v, %v after %d attempts, want stack1 after 3", stack, err, attempts)
+	}
+
+	attempts = 0
+	if _, _, err := retrying.Stacks.Regenerate("stack1"); err == nil || attempts != 1 {
+		t.Errorf("Stacks.Regenerate returned %v after %d attempts, want an error after 1", err, attempts)
+	}
+}
+
+func TestRetryTransport_context(t *testing.T) {
+	setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc("/stacks/stack1", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":"429","error":"Slow down"}`))
+	})
+
+	// The hour asked by Retry-After is capped, and cut short by the context.
+	transport := &RetryTransport{MaxRetries: 3, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", server.URL+"/stacks/stack1", nil)
+	start := time.Now()
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("RoundTrip returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second || attempts != 1 {
+		t.Errorf("RoundTrip took %v and %d attempts, want one attempt cut short", elapsed, attempts)
+	}
+
+	// A canceled request is not retried.
+	attempts = 0
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); err == nil || attempts != 0 {
+		t.Errorf("RoundTrip of a canceled request returned %v after %d attempts", err, attempts)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	if !retryable(nil, errors.New("connection reset")) {
+		t.Errorf("retryable(network error) = false, want true")
+	}
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded} {
+		if retryable(nil, err) {
+			t.Errorf("retryable(%v) = true, want false", err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package sarif converts the vulnerabilities of a stack into a SARIF 2.1.0
+// log, the format code scanning dashboards ingest.
+//
+// Each vulnerability found by vulns.Merge becomes a rule, and each component
+// of the stack it affects a result located in the stack Dockerfile, on the
+// line installing the component when the parsed Dockerfile is given.
+// Vulnerabilities of the stack as a whole are located on its first line.
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/dockerfile"
+	"github.com/JesusTinoco/go-smith/stacksmith/vulns"
+)
+
+// Version and Schema identify the SARIF version written.
+const (
+	Version = "2.1.0"
+	Schema  = "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json"
+)
+
+// ToolName is the name of the tool the results are attributed to.
+const ToolName = "Stacksmith"
+
+// Log is a SARIF log.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one tool.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool of a run.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the main component of a tool, holding its rules.
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes one vulnerability.
+type Rule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name,omitempty"`
+	ShortDescription     Message        `json:"shortDescription"`
+	FullDescription      *Message       `json:"fullDescription,omitempty"`
+	HelpURI              string         `json:"helpUri,omitempty"`
+	DefaultConfiguration Configuration  `json:"defaultConfiguration"`
+	Properties           RuleProperties `json:"properties"`
+}
+
+// Configuration holds the level of the results of a rule.
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// RuleProperties are the rule properties understood by code scanning.
+type RuleProperties struct {
+	Tags             []string `json:"tags"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+// Message is a plain text message.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is one component affected by one vulnerability.
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          ResultProperties  `json:"properties"`
+}
+
+// ResultProperties tell which component and stack a result is about.
+type ResultProperties struct {
+	StackID   string `json:"stackId"`
+	Component string `json:"component"`
+	Version   string `json:"version,omitempty"`
+	Severity  string `json:"severity"`
+}
+
+// Location is where a result is found.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is a file, relative to the repository root.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a range of lines.
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+// Options tunes New.
+type Options struct {
+	// DockerfilePath is where the Dockerfile of the stack lives in the
+	// repository. Empty means "Dockerfile".
+	DockerfilePath string
+	// Dockerfile, when given, places results on the line installing the
+	// affected component instead of the first line.
+	Dockerfile *dockerfile.Dockerfile
+}
+
+// Levels and security severities of the Stacksmith severities.
+var (
+	levels = map[string]string{
+		"critical": "error",
+		"high":     "error",
+		"medium":   "warning",
+		"low":      "note",
+	}
+	securitySeverities = map[string]string{
+		"critical": "9.5",
+		"high":     "8.0",
+		"medium":   "5.5",
+		"low":      "2.0",
+	}
+)
+
+// New returns the SARIF log of vulnerabilities, as returned by
+// Stacks.GetAllVulnerabilities, found in stack.
+func New(stack *stacksmith.Stack, vulnerabilities []stacksmith.VulnerabilityItem, opts *Options) *Log {
+	if opts == nil {
+		opts = new(Options)
+	}
+	uri := opts.DockerfilePath
+	if uri == "" {
+		uri = dockerfile.FileName
+	}
+
+	lines := make(map[string]int)
+	if opts.Dockerfile != nil {
+		for _, c := range opts.Dockerfile.Components {
+			lines[c.ID] = c.Line
+		}
+	}
+
+	// Rules and results are sorted by vulnerability name for stable output.
+	entries := vulns.Merge(stack, vulnerabilities)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	driver := Driver{Name: ToolName, InformationURI: "https://stacksmith.bitnami.com", Rules: []Rule{}}
+	results := []Result{}
+	ruleIndex := make(map[string]int)
+	for _, e := range entries {
+		index, ok := ruleIndex[e.Name]
+		if !ok {
+			index = len(driver.Rules)
+			ruleIndex[e.Name] = index
+			driver.Rules = append(driver.Rules, newRule(e.Item))
+		}
+
+		location := PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: uri}, Region: &Region{StartLine: 1}}
+		if line := lines[e.Component]; line > 0 && e.Component != "" {
+			location.Region.StartLine = line
+		}
+		results = append(results, Result{
+			RuleID:    e.Name,
+			RuleIndex: index,
+			Level:     level(e.Severity),
+			Message:   Message{Text: message(stack, e)},
+			Locations: []Location{{PhysicalLocation: location}},
+			PartialFingerprints: map[string]string{
+				"stacksmithVulnerability/v1": stack.ID + "/" + e.Component + "/" + e.Name,
+			},
+			Properties: ResultProperties{StackID: stack.ID, Component: e.Component, Version: e.Version, Severity: e.Severity},
+		})
+	}
+
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs:    []Run{{Tool: Tool{Driver: driver}, Results: results}},
+	}
+}
+
+// message describes the result of e, naming the affected versions of its
+// component when the vulnerability lists them.
+func message(stack *stacksmith.Stack, e vulns.Entry) string {
+	severity := strings.ToLower(e.Severity)
+	if e.Component == "" {
+		return fmt.Sprintf("Stack %s is affected by %s (%s severity).", stack.ID, e.Name, severity)
+	}
+	for _, r := range e.Item.Ranges {
+		if r.Component == e.Component {
+			return fmt.Sprintf("%s %s is affected by %s (%s severity, versions %s to %s).",
+				e.Component, e.Version, e.Name, severity, r.From, r.To)
+		}
+	}
+	return fmt.Sprintf("%s %s is affected by %s (%s severity).", e.Component, e.Version, e.Name, severity)
+}
+
+func newRule(v stacksmith.VulnerabilityItem) Rule {
+	var affected []string
+	for _, r := range v.Ranges {
+		affected = append(affected, fmt.Sprintf("%s %s to %s", r.Component, r.From, r.To))
+	}
+	rule := Rule{
+		ID:                   v.Name,
+		Name:                 v.Name,
+		ShortDescription:     Message{Text: fmt.Sprintf("%s (%s severity)", v.Name, strings.ToLower(v.Severity))},
+		DefaultConfiguration: Configuration{Level: level(v.Severity)},
+		Properties: RuleProperties{
+			Tags:             []string{"security", "vulnerability"},
+			SecuritySeverity: securitySeverities[strings.ToLower(v.Severity)],
+		},
+	}
+	if len(affected) > 0 {
+		rule.FullDescription = &Message{Text: v.Name + " affects " + strings.Join(affected, ", ") + "."}
+	}
+	if strings.HasPrefix(v.Name, "CVE-") {
+		rule.HelpURI = "https://nvd.nist.gov/vuln/detail/" + v.Name
+	}
+	return rule
+}
+
+func level(severity string) string {
+	if l, ok := levels[strings.ToLower(severity)]; ok {
+		return l
+	}
+	return "note"
+}
+
+// Write writes log to w as indented JSON.
+func Write(w io.Writer, log *Log) error {
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,104 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/dockerfile"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+func TestNew(t *testing.T) {
+	stack := new(stacksmith.Stack)
+	json.Unmarshal(utils.GetJSON("stack"), stack)
+	vulnerabilities := new(stacksmith.Vulnerability)
+	json.Unmarshal(utils.GetJSON("vulnerabilities"), vulnerabilities)
+	parsed, _ := dockerfile.Parse(strings.NewReader("FROM gcr.io/stacksmith-images/debian-buildpack:wheezy-r7\n\nRUN bitnami-pkg install ruby-2.2.3-3\n"))
+
+	log := New(stack, vulnerabilities.Items, &Options{DockerfilePath: "app/Dockerfile", Dockerfile: parsed})
+
+	if log.Version != Version || len(log.Runs) != 1 {
+		t.Fatalf("New returned %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(vulnerabilities.Items) || len(run.Results) != len(vulnerabilities.Items) {
+		t.Fatalf("New returned %d rules and %d results, want %d", len(run.Tool.Driver.Rules), len(run.Results), len(vulnerabilities.Items))
+	}
+	for i, rule := range run.Tool.Driver.Rules {
+		if i > 0 && rule.ID <= run.Tool.Driver.Rules[i-1].ID {
+			t.Errorf("Rules are not sorted: %s after %s", rule.ID, run.Tool.Driver.Rules[i-1].ID)
+		}
+	}
+
+	var high *Result
+	for i, result := range run.Results {
+		if run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("Result %s points to rule %d", result.RuleID, result.RuleIndex)
+		}
+		if result.RuleID == "CVE-2016-0800" {
+			high = &run.Results[i]
+		}
+	}
+	if high == nil {
+		t.Fatal("New returned no result for CVE-2016-0800")
+	}
+	location := high.Locations[0].PhysicalLocation
+	if high.Level != "error" || location.ArtifactLocation.URI != "app/Dockerfile" || location.Region.StartLine != 3 {
+		t.Errorf("New returned result %+v at %+v", high, location)
+	}
+	if high.Message.Text != "ruby 2.2.3-3 is affected by CVE-2016-0800 (high severity, versions 2.2 to 2.2.4:3)." {
+		t.Errorf("New returned message %q", high.Message.Text)
+	}
+	if rule := run.Tool.Driver.Rules[high.RuleIndex]; rule.Properties.SecuritySeverity != "8.0" || rule.HelpURI != "https://nvd.nist.gov/vuln/detail/CVE-2016-0800" {
+		t.Errorf("New returned rule %+v", rule)
+	}
+}
+
+func TestNew_Merge(t *testing.T) {
+	stack := new(stacksmith.Stack)
+	json.Unmarshal(utils.GetJSON("stack"), stack)
+	var listed stacksmith.Vulnerability
+	json.Unmarshal([]byte(`{"items":[
+		{"name":"CVE-2015-1000","severity":"critical","ranges":[{"component":"ruby","from":"2.0","to":"2.2.3:2"}]},
+		{"name":"CVE-2016-4450","severity":"high","ranges":[{"component":"nginx","from":"1.10","to":"1.10.1"}]},
+		{"name":"CVE-2016-9999","severity":"low"}]}`), &listed)
+
+	run := New(stack, listed.Items, nil).Runs[0]
+	found := make(map[string]Result)
+	for _, result := range run.Results {
+		found[result.RuleID] = result
+	}
+	if _, ok := found["CVE-2015-1000"]; ok {
+		t.Errorf("New reported CVE-2015-1000, fixed in ruby 2.2.3-3")
+	}
+	if _, ok := found["CVE-2016-4450"]; ok {
+		t.Errorf("New reported CVE-2016-4450 of nginx, missing from the stack")
+	}
+	if r := found["CVE-2015-7551"]; r.Properties.Component != "ruby" || r.Properties.Version != "2.2.3-3" {
+		t.Errorf("New reported the vulnerability attached to ruby as %+v", r)
+	}
+	if r := found["CVE-2016-9999"]; r.Properties.Component != "" || r.Message.Text != "Stack "+stack.ID+" is affected by CVE-2016-9999 (low severity)." {
+		t.Errorf("New reported the vulnerability without ranges as %+v", r)
+	}
+	if len(run.Tool.Driver.Rules) != len(run.Results) {
+		t.Errorf("New returned %d rules for %d results", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, New(&stacksmith.Stack{ID: "empty"}, nil, nil)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Write wrote invalid JSON: %v", err)
+	}
+	run := doc["runs"].([]interface{})[0].(map[string]interface{})
+	if doc["$schema"] != Schema || doc["version"] != "2.1.0" || run["results"] == nil {
+		t.Errorf("Write wrote %s", buf.String())
+	}
+}
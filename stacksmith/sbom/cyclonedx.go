@@ -0,0 +1,209 @@
+package sbom
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// CycloneDXVersion is the CycloneDX specification version written.
+const CycloneDXVersion = "1.4"
+
+type cdxBOM struct {
+	BOMFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	SerialNumber    string             `json:"serialNumber"`
+	Version         int                `json:"version"`
+	Metadata        cdxMetadata        `json:"metadata"`
+	Components      []cdxComponent     `json:"components"`
+	Dependencies    []cdxDependency    `json:"dependencies"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxVulnerability struct {
+	BOMRef  string      `json:"bom-ref"`
+	ID      string      `json:"id"`
+	Source  *cdxSource  `json:"source,omitempty"`
+	Ratings []cdxRating `json:"ratings"`
+	Affects []cdxAffect `json:"affects"`
+}
+
+type cdxSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type cdxRating struct {
+	Severity string `json:"severity"`
+	Method   string `json:"method"`
+}
+
+type cdxAffect struct {
+	Ref      string       `json:"ref"`
+	Versions []cdxVersion `json:"versions,omitempty"`
+}
+
+type cdxVersion struct {
+	Range  string `json:"range"`
+	Status string `json:"status"`
+}
+
+// cdxSeverities are the CycloneDX severities.
+var cdxSeverities = map[string]bool{"critical": true, "high": true, "medium": true, "low": true, "info": true, "none": true}
+
+// CycloneDX returns the CycloneDX JSON bill of materials of stack.
+func CycloneDX(stack *stacksmith.Stack, opts *Options) ([]byte, error) {
+	b := newBill(stack, opts)
+	stackRef := "stack:" + stack.ID
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  CycloneDXVersion,
+		SerialNumber: "urn:uuid:" + uuid(stack.ID+"@"+b.Created.Format(time.RFC3339)),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: b.Created.Format(time.RFC3339),
+			Tools:     []cdxTool{{Name: ToolName}},
+			Component: cdxComponent{Type: "container", BOMRef: stackRef, Name: stack.Name, Version: stack.ID},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	stackDeps := cdxDependency{Ref: stackRef, DependsOn: []string{}}
+	vulnerabilities := make(map[string]*cdxVulnerability)
+	var vulnerabilityIDs []string
+	for _, c := range b.all() {
+		ref := c.PURL()
+		bom.Components = append(bom.Components, cdxComponentOf(c, ref))
+		if c.Known {
+			stackDeps.DependsOn = append(stackDeps.DependsOn, ref)
+		}
+		for _, v := range c.Vulnerabilities {
+			vuln, ok := vulnerabilities[v.Name]
+			if !ok {
+				vuln = &cdxVulnerability{BOMRef: "vulnerability:" + v.Name, ID: v.Name, Ratings: []cdxRating{{
+					Severity: cdxSeverity(v.Severity),
+					Method:   "other",
+				}}}
+				if strings.HasPrefix(v.Name, "CVE-") {
+					vuln.Source = &cdxSource{Name: "NVD", URL: advisoryURL(v.Name)}
+				}
+				vulnerabilities[v.Name] = vuln
+				vulnerabilityIDs = append(vulnerabilityIDs, v.Name)
+			}
+			affect := cdxAffect{Ref: ref}
+			for _, r := range v.Ranges {
+				if r.Component == c.ID {
+					affect.Versions = append(affect.Versions, cdxVersion{Range: "vers:generic/>=" + r.From + "|<=" + r.To, Status: "affected"})
+				}
+			}
+			vuln.Affects = append(vuln.Affects, affect)
+		}
+	}
+	bom.Dependencies = append(bom.Dependencies, stackDeps)
+
+	refs := make(map[string]string)
+	for _, c := range b.all() {
+		refs[c.ID] = c.PURL()
+	}
+	for _, c := range b.Components {
+		deps, ok := b.Deps[c.ID]
+		if !ok {
+			continue
+		}
+		dependency := cdxDependency{Ref: refs[c.ID], DependsOn: []string{}}
+		for _, dep := range deps {
+			dependency.DependsOn = append(dependency.DependsOn, refs[dep])
+		}
+		bom.Dependencies = append(bom.Dependencies, dependency)
+	}
+
+	sort.Strings(vulnerabilityIDs)
+	for _, id := range vulnerabilityIDs {
+		bom.Vulnerabilities = append(bom.Vulnerabilities, *vulnerabilities[id])
+	}
+
+	return marshal(bom)
+}
+
+func cdxComponentOf(c *component, ref string) cdxComponent {
+	out := cdxComponent{Type: cdxType(c.Category), BOMRef: ref, Name: c.ID, Version: c.FullVersion(), PURL: ref}
+	if isSHA256(c.Checksum) {
+		out.Hashes = []cdxHash{{Alg: "SHA-256", Content: strings.ToLower(c.Checksum)}}
+	}
+	if c.Name != "" && c.Name != c.ID {
+		out.Properties = append(out.Properties, cdxProperty{Name: "stacksmith:name", Value: c.Name})
+	}
+	if c.Version != "" {
+		out.Properties = append(out.Properties,
+			cdxProperty{Name: "stacksmith:version", Value: c.Version},
+			cdxProperty{Name: "stacksmith:revision", Value: strconv.Itoa(c.Revision)})
+	}
+	if c.Branch != "" {
+		out.Properties = append(out.Properties, cdxProperty{Name: "stacksmith:branch", Value: c.Branch})
+	}
+	if c.Category != "" {
+		out.Properties = append(out.Properties, cdxProperty{Name: "stacksmith:category", Value: c.Category})
+	}
+	return out
+}
+
+// cdxType maps Stacksmith categories to CycloneDX component types.
+func cdxType(category string) string {
+	switch category {
+	case "os":
+		return "operating-system"
+	case "framework":
+		return "framework"
+	case "runtime", "service":
+		return "application"
+	}
+	return "library"
+}
+
+func cdxSeverity(severity string) string {
+	severity = strings.ToLower(severity)
+	if cdxSeverities[severity] {
+		return severity
+	}
+	return "unknown"
+}
@@ -0,0 +1,94 @@
+package sbom
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestCycloneDX(t *testing.T) {
+	got, err := CycloneDX(testStack(t), &Options{Dependencies: testDependencies})
+	if err != nil {
+		t.Fatalf("CycloneDX returned error: %v", err)
+	}
+	testGolden(t, "stack.cdx.json", got)
+
+	again, _ := CycloneDX(testStack(t), &Options{Dependencies: testDependencies})
+	if string(again) != string(got) {
+		t.Errorf("CycloneDX is not stable")
+	}
+}
+
+// TestCycloneDX_Schema checks the constraints the CycloneDX 1.4 JSON schema
+// puts on what CycloneDX writes.
+func TestCycloneDX_Schema(t *testing.T) {
+	data, _ := CycloneDX(testStack(t), &Options{Dependencies: testDependencies})
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("CycloneDX wrote invalid JSON: %v", err)
+	}
+
+	testRequired(t, doc, "bom", "bomFormat", "specVersion")
+	if doc["bomFormat"] != "CycloneDX" || doc["specVersion"] != "1.4" {
+		t.Errorf("bom is %v %v", doc["bomFormat"], doc["specVersion"])
+	}
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(doc["serialNumber"].(string)) {
+		t.Errorf("serialNumber %v is not a UUID URN", doc["serialNumber"])
+	}
+
+	types := map[string]bool{"application": true, "framework": true, "library": true, "container": true,
+		"operating-system": true, "device": true, "firmware": true, "file": true}
+	hash := regexp.MustCompile(`^[a-f0-9]{64}$`)
+	refs := make(map[string]bool)
+	for _, c := range doc["components"].([]interface{}) {
+		component := c.(map[string]interface{})
+		testRequired(t, component, "component", "type", "name")
+		if !types[component["type"].(string)] {
+			t.Errorf("component %v has type %v", component["name"], component["type"])
+		}
+		for _, h := range asSlice(component["hashes"]) {
+			h := h.(map[string]interface{})
+			if h["alg"] != "SHA-256" || !hash.MatchString(h["content"].(string)) {
+				t.Errorf("component %v has hash %v", component["name"], h)
+			}
+		}
+		refs[component["bom-ref"].(string)] = true
+	}
+	refs["stack:bzr9nhz"] = true
+
+	for _, d := range doc["dependencies"].([]interface{}) {
+		dependency := d.(map[string]interface{})
+		testRequired(t, dependency, "dependency", "ref")
+		for _, ref := range append(asSlice(dependency["dependsOn"]), dependency["ref"]) {
+			if !refs[ref.(string)] {
+				t.Errorf("dependency refers to unknown %v", ref)
+			}
+		}
+	}
+
+	severities := map[string]bool{"critical": true, "high": true, "medium": true, "low": true, "info": true, "none": true, "unknown": true}
+	vulnerabilities := asSlice(doc["vulnerabilities"])
+	if len(vulnerabilities) != 8 {
+		t.Errorf("bom has %d vulnerabilities, want 8", len(vulnerabilities))
+	}
+	for _, v := range vulnerabilities {
+		vulnerability := v.(map[string]interface{})
+		for _, r := range asSlice(vulnerability["ratings"]) {
+			if !severities[r.(map[string]interface{})["severity"].(string)] {
+				t.Errorf("vulnerability %v has rating %v", vulnerability["id"], r)
+			}
+		}
+		for _, a := range asSlice(vulnerability["affects"]) {
+			affect := a.(map[string]interface{})
+			testRequired(t, affect, "affect", "ref")
+			if !refs[affect["ref"].(string)] {
+				t.Errorf("vulnerability %v affects unknown %v", vulnerability["id"], affect["ref"])
+			}
+		}
+	}
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
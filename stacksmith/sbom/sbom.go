@@ -0,0 +1,195 @@
+// Package sbom writes software bills of materials of stacks, as CycloneDX
+// 1.4 JSON and SPDX 2.3 JSON documents.
+//
+// A bill lists the OS and the components of the stack with their version,
+// revision, checksum and category, the dependencies between them, and the
+// vulnerabilities Stacksmith attaches to each component. Documents only
+// depend on their inputs: entries are sorted and dates come from the stack,
+// so that two bills of the same stack are identical.
+package sbom
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/depgraph"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// ToolName is the tool recorded as the author of the documents.
+const ToolName = "go-smith"
+
+// Options tunes CycloneDX and SPDX.
+type Options struct {
+	// Dependencies maps component IDs to the IDs of their dependencies, as
+	// returned by Dependencies. Nil leaves the relationships out.
+	Dependencies map[string][]string
+	// Created replaces the generation date of the stack as the document date.
+	Created time.Time
+}
+
+// Dependencies resolves the transitive dependencies of the components of
+// stack with a depgraph.Resolver, and returns the dependencies of each
+// component reached.
+func Dependencies(ctx context.Context, discovery *stacksmith.DiscoveryService, stack *stacksmith.Stack) (map[string][]string, error) {
+	var roots []stacksmith.ComponentItem
+	for _, c := range stack.Components {
+		roots = append(roots, stacksmith.ComponentItem{ID: c.ID, Version: c.Version})
+	}
+	g, err := depgraph.NewResolver(discovery, 0).Resolve(ctx, stack.Name, roots)
+	if err != nil {
+		return nil, fmt.Errorf("sbom: %v", err)
+	}
+	deps := make(map[string][]string, len(g.Nodes))
+	for id, n := range g.Nodes {
+		deps[id] = append([]string{}, n.Dependencies...)
+	}
+	return deps, nil
+}
+
+// component is an entry of the bill.
+type component struct {
+	ID       string
+	Name     string
+	Version  string
+	Revision int
+	Branch   string
+	Checksum string
+	Category string
+	// Known tells whether the component is part of the stack, rather than
+	// only reached as a dependency.
+	Known           bool
+	Vulnerabilities []stacksmith.VulnerabilityItem
+}
+
+// FullVersion is the version with its revision, "2.2.3-3".
+func (c *component) FullVersion() string {
+	return version.Format(c.Version, c.Revision)
+}
+
+// PURL is the package URL of the component.
+func (c *component) PURL() string {
+	if c.Category == "os" {
+		purl := "pkg:generic/" + c.ID
+		if c.Version != "" {
+			purl += "@" + c.Version
+		}
+		return purl
+	}
+	purl := "pkg:bitnami/" + c.ID
+	if v := c.FullVersion(); v != "" {
+		purl += "@" + v
+	}
+	return purl
+}
+
+// bill is the format independent content of a document.
+type bill struct {
+	Stack      *stacksmith.Stack
+	Created    time.Time
+	OS         *component
+	Components []*component
+	Deps       map[string][]string
+}
+
+func newBill(stack *stacksmith.Stack, opts *Options) *bill {
+	if opts == nil {
+		opts = new(Options)
+	}
+	b := &bill{Stack: stack, Created: opts.Created, Deps: make(map[string][]string)}
+	if b.Created.IsZero() {
+		b.Created = stackDate(stack)
+	}
+
+	known := make(map[string]bool)
+	for _, c := range stack.Components {
+		b.Components = append(b.Components, newComponent(c))
+		known[c.ID] = true
+	}
+	if stack.Os.ID != "" {
+		b.OS = newComponent(stack.Os)
+		if b.OS.Category == "" {
+			b.OS.Category = "os"
+		}
+	}
+
+	for id, deps := range opts.Dependencies {
+		b.Deps[id] = append([]string{}, deps...)
+		sort.Strings(b.Deps[id])
+		for _, dep := range append([]string{id}, deps...) {
+			if !known[dep] {
+				known[dep] = true
+				b.Components = append(b.Components, &component{ID: dep, Name: dep})
+			}
+		}
+	}
+	sort.Slice(b.Components, func(i, j int) bool { return b.Components[i].ID < b.Components[j].ID })
+	return b
+}
+
+func newComponent(c stacksmith.Component) *component {
+	vulnerabilities := append([]stacksmith.VulnerabilityItem(nil), c.Vulnerabilities.Items...)
+	sort.SliceStable(vulnerabilities, func(i, j int) bool { return vulnerabilities[i].Name < vulnerabilities[j].Name })
+	return &component{
+		ID:              c.ID,
+		Name:            c.Name,
+		Version:         c.Version,
+		Revision:        c.Revision,
+		Branch:          c.Branch,
+		Checksum:        c.Checksum,
+		Category:        c.Category,
+		Known:           true,
+		Vulnerabilities: vulnerabilities,
+	}
+}
+
+// all returns the OS, when known, and the components.
+func (b *bill) all() []*component {
+	if b.OS == nil {
+		return b.Components
+	}
+	return append([]*component{b.OS}, b.Components...)
+}
+
+// stackDate is when the stack was last generated, or the Unix epoch when
+// the stack does not say.
+func stackDate(stack *stacksmith.Stack) time.Time {
+	for _, date := range []string{stack.RegeneratedAt, stack.GeneratedAt} {
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			return t.UTC().Truncate(time.Second)
+		}
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// uuid derives a version 5 style UUID from name, so that documents of the
+// same stack generation share their identifier.
+func uuid(name string) string {
+	sum := sha1.Sum([]byte(name))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// isSHA256 tells whether checksum is a hex SHA-256, the only checksums the
+// documents accept.
+func isSHA256(checksum string) bool {
+	if len(checksum) != 64 {
+		return false
+	}
+	for _, r := range checksum {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// advisoryURL is where a vulnerability is described.
+func advisoryURL(name string) string {
+	return "https://nvd.nist.gov/vuln/detail/" + name
+}
@@ -0,0 +1,98 @@
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+// testDependencies are the dependencies of the fixture stack, openssl
+// being only reachable through ruby.
+var testDependencies = map[string][]string{
+	"ruby":    {"openssl", "zlib"},
+	"openssl": {"zlib"},
+	"zlib":    {},
+}
+
+// testGolden compares got with testdata/name, or rewrites it with -update.
+func testGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s differs, got:\n%s", name, got)
+	}
+}
+
+// testRequired checks that the object at path in doc has the fields.
+func testRequired(t *testing.T, doc map[string]interface{}, path string, fields ...string) {
+	for _, field := range fields {
+		if _, ok := doc[field]; !ok {
+			t.Errorf("%s has no required field %q", path, field)
+		}
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	mux.HandleFunc("/api/v1/components/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/components/"), "/dependencies")
+		mu.Lock()
+		requests[id]++
+		mu.Unlock()
+		items, _ := json.Marshal(map[string][]string{"ruby": {"zlib", "openssl"}, "openssl": {"zlib", "ruby"}}[id])
+		if string(items) == "null" {
+			items = []byte("[]")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total_entries":1,"total_pages":1,"items":` + string(items) + `}`))
+	})
+
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+	deps, err := Dependencies(context.Background(), client.Discovery, testStack(t))
+	if err != nil {
+		t.Fatalf("Dependencies returned error: %v", err)
+	}
+	expected := map[string][]string{"ruby": {"openssl", "zlib"}, "openssl": {"ruby", "zlib"}, "zlib": {}}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Dependencies returned %v, want %v", deps, expected)
+	}
+	for id, n := range requests {
+		if n != 1 {
+			t.Errorf("Dependencies of %s requested %d times", id, n)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// SPDXVersion is the SPDX specification version written.
+const SPDXVersion = "SPDX-2.3"
+
+// SPDXNamespace prefixes the namespaces of the SPDX documents.
+const SPDXNamespace = "https://stacksmith.bitnami.com/spdx/"
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+	Comment           string `json:"comment,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+var notSPDXID = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func spdxID(kind, id string) string {
+	return "SPDXRef-" + kind + "-" + notSPDXID.ReplaceAllString(id, "-")
+}
+
+// SPDX returns the SPDX JSON bill of materials of stack. SPDX has no place
+// for vulnerabilities: they are listed as security advisories of the
+// affected packages.
+func SPDX(stack *stacksmith.Stack, opts *Options) ([]byte, error) {
+	b := newBill(stack, opts)
+	created := b.Created.Format("2006-01-02T15:04:05Z")
+	stackID := spdxID("Stack", stack.ID)
+
+	doc := spdxDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "stack-" + stack.ID,
+		DocumentNamespace: SPDXNamespace + stack.ID + "-" + uuid(stack.ID+"@"+created),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Organization: Bitnami Stacksmith", "Tool: " + ToolName},
+		},
+		Packages: []spdxPackage{{
+			SPDXID:                stackID,
+			Name:                  stack.Name,
+			VersionInfo:           stack.ID,
+			DownloadLocation:      "NOASSERTION",
+			PrimaryPackagePurpose: "CONTAINER",
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: stackID,
+		}},
+	}
+
+	for _, c := range b.all() {
+		id := spdxID("Package", c.ID)
+		doc.Packages = append(doc.Packages, spdxPackageOf(c, id))
+		if c.Known {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID: stackID, RelationshipType: "CONTAINS", RelatedSPDXElement: id,
+			})
+		}
+	}
+	for _, c := range b.Components {
+		for _, dep := range b.Deps[c.ID] {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID: spdxID("Package", c.ID), RelationshipType: "DEPENDS_ON", RelatedSPDXElement: spdxID("Package", dep),
+			})
+		}
+	}
+
+	return marshal(doc)
+}
+
+func spdxPackageOf(c *component, id string) spdxPackage {
+	p := spdxPackage{
+		SPDXID:           id,
+		Name:             c.ID,
+		VersionInfo:      c.FullVersion(),
+		DownloadLocation: "NOASSERTION",
+		ExternalRefs: []spdxExternalRef{{
+			ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: c.PURL(),
+		}},
+		PrimaryPackagePurpose: spdxPurpose(c.Category),
+	}
+	if isSHA256(c.Checksum) {
+		p.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: strings.ToLower(c.Checksum)}}
+	}
+	var comment []string
+	if c.Category != "" {
+		comment = append(comment, "category: "+c.Category)
+	}
+	if c.Branch != "" {
+		comment = append(comment, "branch: "+c.Branch)
+	}
+	if !c.Known {
+		comment = append(comment, "dependency not listed in the stack")
+	}
+	p.Comment = strings.Join(comment, ", ")
+	for _, v := range c.Vulnerabilities {
+		p.ExternalRefs = append(p.ExternalRefs, spdxExternalRef{
+			ReferenceCategory: "SECURITY",
+			ReferenceType:     "advisory",
+			ReferenceLocator:  advisoryURL(v.Name),
+			Comment:           v.Name + " (" + strings.ToLower(v.Severity) + " severity)",
+		})
+	}
+	return p
+}
+
+// spdxPurpose maps Stacksmith categories to SPDX package purposes.
+func spdxPurpose(category string) string {
+	switch category {
+	case "os":
+		return "OPERATING-SYSTEM"
+	case "framework":
+		return "FRAMEWORK"
+	case "runtime", "service":
+		return "APPLICATION"
+	case "":
+		return ""
+	}
+	return "LIBRARY"
+}
+
+// marshal writes v as indented JSON, without escaping HTML characters so
+// that URLs stay readable.
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
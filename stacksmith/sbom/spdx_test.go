@@ -0,0 +1,78 @@
+package sbom
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSPDX(t *testing.T) {
+	got, err := SPDX(testStack(t), &Options{Dependencies: testDependencies})
+	if err != nil {
+		t.Fatalf("SPDX returned error: %v", err)
+	}
+	testGolden(t, "stack.spdx.json", got)
+
+	created := time.Date(2016, 8, 22, 10, 12, 43, 0, time.UTC)
+	other, _ := SPDX(testStack(t), &Options{Created: created})
+	var doc map[string]interface{}
+	json.Unmarshal(other, &doc)
+	if info := doc["creationInfo"].(map[string]interface{}); info["created"] != "2016-08-22T10:12:43Z" {
+		t.Errorf("SPDX with Created wrote creation info %v", info)
+	}
+}
+
+// TestSPDX_Schema checks the constraints the SPDX 2.3 JSON schema puts on
+// what SPDX writes.
+func TestSPDX_Schema(t *testing.T) {
+	data, _ := SPDX(testStack(t), &Options{Dependencies: testDependencies})
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("SPDX wrote invalid JSON: %v", err)
+	}
+
+	testRequired(t, doc, "document", "SPDXID", "creationInfo", "dataLicense", "name", "spdxVersion", "documentNamespace")
+	if doc["spdxVersion"] != "SPDX-2.3" || doc["dataLicense"] != "CC0-1.0" || doc["SPDXID"] != "SPDXRef-DOCUMENT" {
+		t.Errorf("document is %v %v %v", doc["spdxVersion"], doc["dataLicense"], doc["SPDXID"])
+	}
+	info := doc["creationInfo"].(map[string]interface{})
+	testRequired(t, info, "creationInfo", "created", "creators")
+	if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`).MatchString(info["created"].(string)) {
+		t.Errorf("created %v is not in the SPDX date format", info["created"])
+	}
+
+	spdxID := regexp.MustCompile(`^SPDXRef-[A-Za-z0-9.-]+$`)
+	ids := map[string]bool{"SPDXRef-DOCUMENT": true}
+	purposes := map[string]bool{"APPLICATION": true, "FRAMEWORK": true, "LIBRARY": true, "CONTAINER": true,
+		"OPERATING-SYSTEM": true, "DEVICE": true, "FIRMWARE": true, "SOURCE": true, "ARCHIVE": true, "FILE": true,
+		"INSTALL": true, "OTHER": true}
+	for _, p := range doc["packages"].([]interface{}) {
+		pkg := p.(map[string]interface{})
+		testRequired(t, pkg, "package", "SPDXID", "name", "downloadLocation")
+		if !spdxID.MatchString(pkg["SPDXID"].(string)) {
+			t.Errorf("package %v has SPDXID %v", pkg["name"], pkg["SPDXID"])
+		}
+		ids[pkg["SPDXID"].(string)] = true
+		if purpose, ok := pkg["primaryPackagePurpose"]; ok && !purposes[purpose.(string)] {
+			t.Errorf("package %v has purpose %v", pkg["name"], purpose)
+		}
+		for _, c := range asSlice(pkg["checksums"]) {
+			checksum := c.(map[string]interface{})
+			if checksum["algorithm"] != "SHA256" || !regexp.MustCompile(`^[a-f0-9]{64}$`).MatchString(checksum["checksumValue"].(string)) {
+				t.Errorf("package %v has checksum %v", pkg["name"], checksum)
+			}
+		}
+		for _, r := range asSlice(pkg["externalRefs"]) {
+			testRequired(t, r.(map[string]interface{}), "externalRef", "referenceCategory", "referenceType", "referenceLocator")
+		}
+	}
+
+	for _, r := range doc["relationships"].([]interface{}) {
+		relationship := r.(map[string]interface{})
+		testRequired(t, relationship, "relationship", "spdxElementId", "relationshipType", "relatedSpdxElement")
+		if !ids[relationship["spdxElementId"].(string)] || !ids[relationship["relatedSpdxElement"].(string)] {
+			t.Errorf("relationship %v refers to an unknown element", relationship)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package stacksmith
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/dghubble/sling"
@@ -20,27 +23,30 @@ func newStacksService(sling *sling.Sling) *StacksService {
 
 // StacksList ...
 type StacksList struct {
-	TotalEntries int `json:"total_entries"`
-	TotalPages   int `json:"total_pages"`
-	Items        []struct {
-		ID                   string `json:"id"`
-		Name                 string `json:"name"`
-		Status               string `json:"status"`
-		GeneratedAt          string `json:"generated_at"`
-		RegeneratedAt        string `json:"regenerated_at"`
-		Outdated             bool   `json:"outdated"`
-		NotificationsEnabled bool   `json:"notifications_enabled"`
-		Vulnerabilities      struct {
-			URL        string `json:"url"`
-			Vulnerable bool   `json:"vulnerable"`
-			Severity   string `json:"severity"`
-		} `json:"vulnerabilities"`
-		Output struct {
-			Dockerfile string `json:"dockerfile"`
-		} `json:"output"`
-		Shared       bool   `json:"shared"`
-		ShareableURL string `json:"shareable_url"`
-	} `json:"items"`
+	TotalEntries int         `json:"total_entries"`
+	TotalPages   int         `json:"total_pages"`
+	Items        []StackItem `json:"items"`
+}
+
+// StackItem ...
+type StackItem struct {
+	ID                   string `json:"id"`
+	Name                 string `json:"name"`
+	Status               string `json:"status"`
+	GeneratedAt          string `json:"generated_at"`
+	RegeneratedAt        string `json:"regenerated_at"`
+	Outdated             bool   `json:"outdated"`
+	NotificationsEnabled bool   `json:"notifications_enabled"`
+	Vulnerabilities      struct {
+		URL        string `json:"url"`
+		Vulnerable bool   `json:"vulnerable"`
+		Severity   string `json:"severity"`
+	} `json:"vulnerabilities"`
+	Output struct {
+		Dockerfile string `json:"dockerfile"`
+	} `json:"output"`
+	Shared       bool   `json:"shared"`
+	ShareableURL string `json:"shareable_url"`
 }
 
 // Stack ...
@@ -146,56 +152,100 @@ type StackParams struct {
 // List List all stacks attached to your account.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/get_stacks
 func (s *StacksService) List(params *PaginationParams) (*StacksList, *http.Response, error) {
+	return s.ListContext(context.Background(), params)
+}
+
+// ListContext List all stacks attached to your account, aborting the request once ctx is done.
+func (s *StacksService) ListContext(ctx context.Context, params *PaginationParams) (*StacksList, *http.Response, error) {
 	stacksList := new(StacksList)
-	apiError := new(APIError)
-	resp, err := s.sling.New().QueryStruct(params).Receive(stacksList, apiError)
-	return stacksList, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().QueryStruct(params), stacksList)
+	return stacksList, resp, err
+}
+
+// ListAll List all stacks attached to your account, following every page.
+func (s *StacksService) ListAll() ([]StackItem, *http.Response, error) {
+	return s.ListAllContext(context.Background())
+}
+
+// ListAllContext List all stacks attached to your account, following every page until ctx is done.
+func (s *StacksService) ListAllContext(ctx context.Context) ([]StackItem, *http.Response, error) {
+	var stacks []StackItem
+	for page := 1; ; page++ {
+		stacksList, resp, err := s.ListContext(ctx, &PaginationParams{Page: page, PerPage: MaxPerPage})
+		if err != nil {
+			return stacks, resp, err
+		}
+		stacks = append(stacks, stacksList.Items...)
+		if page >= stacksList.TotalPages || len(stacksList.Items) == 0 {
+			return stacks, resp, nil
+		}
+	}
 }
 
 // Create Create a stack by specifying the components you need, its kind and its OS.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/post_stacks
 func (s *StacksService) Create(params *StackDefinition) (*StatusGeneration, *http.Response, error) {
+	return s.CreateContext(context.Background(), params)
+}
+
+// CreateContext Create a stack, aborting the request once ctx is done.
+func (s *StacksService) CreateContext(ctx context.Context, params *StackDefinition) (*StatusGeneration, *http.Response, error) {
 	status := new(StatusGeneration)
-	apiError := new(APIError)
-	resp, err := s.sling.New().Post("").BodyJSON(params).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Post("").BodyJSON(params), status)
+	return status, resp, err
 }
 
 // Delete Delete a stack.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/delete_stacks_id
 func (s *StacksService) Delete(stackID string) (*StatusDeletion, *http.Response, error) {
+	return s.DeleteContext(context.Background(), stackID)
+}
+
+// DeleteContext Delete a stack, aborting the request once ctx is done.
+func (s *StacksService) DeleteContext(ctx context.Context, stackID string) (*StatusDeletion, *http.Response, error) {
 	status := new(StatusDeletion)
-	apiError := new(APIError)
-	resp, err := s.sling.New().Delete(stackID).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Delete(stackID), status)
+	return status, resp, err
 }
 
 // Get Retrieve the properties of a stack, to list the versions of the framework, runtime, and OS generated.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/get_stacks_id
 func (s *StacksService) Get(stackID string) (*Stack, *http.Response, error) {
+	return s.GetContext(context.Background(), stackID)
+}
+
+// GetContext Retrieve the properties of a stack, aborting the request once ctx is done.
+func (s *StacksService) GetContext(ctx context.Context, stackID string) (*Stack, *http.Response, error) {
 	stack := new(Stack)
-	apiError := new(APIError)
-	resp, err := s.sling.New().Get(stackID).Receive(stack, apiError)
-	return stack, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Get(stackID), stack)
+	return stack, resp, err
 }
 
 // Update Update the properties of an existing stack.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/patch_stacks_id
 func (s *StacksService) Update(stackID string, params *StackParams) (*StatusGeneration, *http.Response, error) {
+	return s.UpdateContext(context.Background(), stackID, params)
+}
+
+// UpdateContext Update the properties of an existing stack, aborting the request once ctx is done.
+func (s *StacksService) UpdateContext(ctx context.Context, stackID string, params *StackParams) (*StatusGeneration, *http.Response, error) {
 	status := new(StatusGeneration)
-	apiError := new(APIError)
-	resp, err := s.sling.New().Patch(stackID).BodyJSON(params).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Patch(stackID).BodyJSON(params), status)
+	return status, resp, err
 }
 
 // Regenerate Create a new stack based on the requirements of another, if there are new versions for it's requirements.
 // https://stacksmith.bitnami.com/api/v1/#!/Stacks/post_stacks_id_regenerate
 func (s *StacksService) Regenerate(stackID string) (*StatusGeneration, *http.Response, error) {
+	return s.RegenerateContext(context.Background(), stackID)
+}
+
+// RegenerateContext Create a new stack based on the requirements of another, aborting the request once ctx is done.
+func (s *StacksService) RegenerateContext(ctx context.Context, stackID string) (*StatusGeneration, *http.Response, error) {
 	status := new(StatusGeneration)
-	apiError := new(APIError)
 	path := fmt.Sprintf("%s/regenerate", stackID)
-	resp, err := s.sling.New().Post(path).Receive(status, apiError)
-	return status, resp, relevantError(err, *apiError)
+	resp, err := do(ctx, s.sling.New().Post(path), status)
+	return status, resp, err
 }
 
 // GetVulnerabilities Retrieve the list of vulnerabilities affecting a stack.
@@ -207,3 +257,43 @@ func (s *StacksService) GetVulnerabilities(stackID string, params *PaginationPar
 	resp, err := s.sling.New().Get(path).QueryStruct(params).Receive(vulnerabilities, apiError)
 	return vulnerabilities, resp, relevantError(err, *apiError)
 }
+
+// GetAllVulnerabilities Retrieve the list of vulnerabilities affecting a stack, following every page.
+func (s *StacksService) GetAllVulnerabilities(stackID string) ([]VulnerabilityItem, *http.Response, error) {
+	var items []VulnerabilityItem
+	for page := 1; ; page++ {
+		vulnerabilities, resp, err := s.GetVulnerabilities(stackID, &PaginationParams{Page: page, PerPage: MaxPerPage})
+		if err != nil {
+			return items, resp, err
+		}
+		items = append(items, vulnerabilities.Items...)
+		if page >= vulnerabilities.TotalPages || len(vulnerabilities.Items) == 0 {
+			return items, resp, nil
+		}
+	}
+}
+
+// Dockerfile Retrieve the Dockerfile generated for a stack, the file Stack.Output.Dockerfile points to.
+func (s *StacksService) Dockerfile(ctx context.Context, stackID string) ([]byte, *http.Response, error) {
+	var dockerfile []byte
+	apiError := new(APIError)
+	req, err := s.sling.New().Get(stackID + ".dockerfile").Request()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.sling.New().ResponseDecoder(textDecoder{}).Do(req.WithContext(ctx), &dockerfile, apiError)
+	return dockerfile, resp, relevantError(err, *apiError)
+}
+
+// textDecoder reads plain text responses into a *[]byte and decodes the
+// others, such as API errors, as JSON.
+type textDecoder struct{}
+
+func (textDecoder) Decode(resp *http.Response, v interface{}) error {
+	if text, ok := v.(*[]byte); ok {
+		data, err := ioutil.ReadAll(resp.Body)
+		*text = data
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -1,6 +1,7 @@
 package stacksmith
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"reflect"
@@ -165,6 +166,42 @@ func TestStacksService_Regenerate(t *testing.T) {
 	}
 }
 
+func TestStacksService_Context(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/stacks/stack1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"stack1"}`))
+	})
+
+	stack, _, err := client.Stacks.GetContext(context.Background(), "stack1")
+	if err != nil || stack.ID != "stack1" {
+		t.Errorf("Stacks.GetContext returned %+v, %v", stack, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Stacks.GetContext(ctx, "stack1"); err == nil {
+		t.Errorf("Stacks.GetContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Stacks.UpdateContext(ctx, "stack1", &StackParams{}); err == nil {
+		t.Errorf("Stacks.UpdateContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Stacks.DeleteContext(ctx, "stack1"); err == nil {
+		t.Errorf("Stacks.DeleteContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Stacks.RegenerateContext(ctx, "stack1"); err == nil {
+		t.Errorf("Stacks.RegenerateContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Stacks.ListAllContext(ctx); err == nil {
+		t.Errorf("Stacks.ListAllContext with a canceled context returned no error")
+	}
+	if _, _, err := client.Stacks.CreateContext(ctx, &StackDefinition{}); err == nil {
+		t.Errorf("Stacks.CreateContext with a canceled context returned no error")
+	}
+}
+
 func TestStacksService_GetVulnerabilities(t *testing.T) {
 	setup()
 	defer teardown()
@@ -190,3 +227,100 @@ func TestStacksService_GetVulnerabilities(t *testing.T) {
 		t.Errorf("Stacks.GetVulnerabilities returned %+v, want %+v", vulnerabilitiesRecieved, vulnerabilitiesExpected)
 	}
 }
+
+func TestStacksService_ListAll(t *testing.T) {
+	setup()
+	defer teardown()
+
+	listStacks := utils.GetJSON("list_stacks")
+	stacksList := new(StacksList)
+	json.Unmarshal(listStacks, stacksList)
+
+	pages := []string{}
+	mux.HandleFunc("/stacks/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		pages = append(pages, r.FormValue("page"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(listStacks)
+	})
+
+	stacksRecieved, _, err := client.Stacks.ListAll()
+	if err != nil {
+		t.Errorf("Stacks.ListAll returned error: %v", err.Error())
+	}
+
+	if !reflect.DeepEqual(pages, []string{"1", "2"}) {
+		t.Errorf("Stacks.ListAll requested pages %v, want [1 2]", pages)
+	}
+	if len(stacksRecieved) != 2*len(stacksList.Items) {
+		t.Errorf("Stacks.ListAll returned %d stacks, want %d", len(stacksRecieved), 2*len(stacksList.Items))
+	}
+}
+
+func TestStacksService_Dockerfile(t *testing.T) {
+	setup()
+	defer teardown()
+
+	dockerfile := "FROM bitnami/minideb-extras:jessie-r2\nEXPOSE 3000\n"
+
+	mux.HandleFunc("/stacks/stack1.dockerfile", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"api_key": "my_api_key"})
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(dockerfile))
+	})
+	mux.HandleFunc("/stacks/missing.dockerfile", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":"404","error":"Stack not found"}`))
+	})
+
+	received, _, err := client.Stacks.Dockerfile(context.Background(), "stack1")
+	if err != nil {
+		t.Errorf("Stacks.Dockerfile returned error: %v", err.Error())
+	}
+	if string(received) != dockerfile {
+		t.Errorf("Stacks.Dockerfile returned %q, want %q", received, dockerfile)
+	}
+
+	_, resp, err := client.Stacks.Dockerfile(context.Background(), "missing")
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Stacks.Dockerfile of a missing stack returned %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Stacks.Dockerfile(ctx, "stack1"); err == nil {
+		t.Errorf("Stacks.Dockerfile with a canceled context returned no error")
+	}
+}
+
+func TestStacksService_GetAllVulnerabilities(t *testing.T) {
+	setup()
+	defer teardown()
+
+	vulnerabilitiesJSON := utils.GetJSON("vulnerabilities")
+	vulnerabilities := new(Vulnerability)
+	json.Unmarshal(vulnerabilitiesJSON, vulnerabilities)
+
+	pages := []string{}
+	mux.HandleFunc("/stacks/stack1/vulnerabilities", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"page": r.FormValue("page"), "per_page": "100", "api_key": "my_api_key"})
+		pages = append(pages, r.FormValue("page"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(vulnerabilitiesJSON)
+	})
+
+	itemsRecieved, _, err := client.Stacks.GetAllVulnerabilities("stack1")
+	if err != nil {
+		t.Errorf("Stacks.GetAllVulnerabilities returned error: %v", err.Error())
+	}
+
+	if !reflect.DeepEqual(pages, []string{"1"}) {
+		t.Errorf("Stacks.GetAllVulnerabilities requested pages %v, want [1]", pages)
+	}
+	if !reflect.DeepEqual(itemsRecieved, vulnerabilities.Items) {
+		t.Errorf("Stacks.GetAllVulnerabilities returned %+v, want %+v", itemsRecieved, vulnerabilities.Items)
+	}
+}
@@ -2,6 +2,7 @@ package stacksmith
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/dghubble/sling"
 )
@@ -24,7 +25,16 @@ type APIKeyParam struct {
 
 // NewClient return a new Client
 func NewClient(apiKey string, httpClient *http.Client) *Client {
-	base := sling.New().Client(httpClient).Base(stacksmithAPI).QueryStruct(APIKeyParam{APIKey: apiKey})
+	return NewClientWithBaseURL(stacksmithAPI, apiKey, httpClient)
+}
+
+// NewClientWithBaseURL return a new Client sending its requests to baseURL
+// instead of the public Stacksmith API.
+func NewClientWithBaseURL(baseURL string, apiKey string, httpClient *http.Client) *Client {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	base := sling.New().Client(httpClient).Base(baseURL).QueryStruct(APIKeyParam{APIKey: apiKey})
 	return &Client{
 		sling:     base,
 		Stacks:    newStacksService(base.New()),
@@ -40,3 +40,20 @@ func testFormValues(t *testing.T, r *http.Request, values values) {
 		}
 	}
 }
+
+func TestNewClientWithBaseURL(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/api/v2/stacks/stack1", func(w http.ResponseWriter, r *http.Request) {
+		testFormValues(t, r, values{"api_key": "other_key"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"stack1"}`))
+	})
+
+	other := NewClientWithBaseURL(server.URL+"/api/v2", "other_key", nil)
+	stack, _, err := other.Stacks.Get("stack1")
+	if err != nil || stack.ID != "stack1" {
+		t.Errorf("Stacks.Get returned %+v, %v", stack, err)
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RedirectClient returns an *http.Client sending every request to the host of
+// baseURL, keeping its path. It lets packages other than stacksmith point a
+// Client at an httptest.Server.
+func RedirectClient(baseURL string) *http.Client {
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		panic(err)
+	}
+	return &http.Client{Transport: redirectTransport{target: target}}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := new(http.Request)
+	*redirected = *req
+	redirected.URL = new(url.URL)
+	*redirected.URL = *req.URL
+	redirected.URL.Scheme = t.target.Scheme
+	redirected.URL.Host = t.target.Host
+	redirected.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 )
 
 // GetJSON ...
 func GetJSON(jsonFileName string) []byte {
-	jsonPath := fmt.Sprintf("./utils/fixtures/%s.json", jsonFileName)
+	jsonPath := filepath.Join(fixturesDir(), fmt.Sprintf("%s.json", jsonFileName))
 	file, err := ioutil.ReadFile(jsonPath)
 	if err != nil {
 		log.Fatalf("File error: %v\n", err)
@@ -17,3 +19,13 @@ func GetJSON(jsonFileName string) []byte {
 	}
 	return file
 }
+
+// fixturesDir locates the fixtures next to this file, so packages other
+// than stacksmith can share them from their own working directory.
+func fixturesDir() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return "./utils/fixtures"
+	}
+	return filepath.Join(filepath.Dir(file), "fixtures")
+}
@@ -0,0 +1,228 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a set of versions, like ">=5.6 <7" or "~7.0".
+//
+// Comparisons are separated by spaces or commas and must all hold; "||"
+// separates alternatives. The operators are =, !=, >, >=, <, <=, and:
+//
+//	~7.0     >=7.0 <7.1, the minor version is fixed
+//	~7       >=7 <8
+//	~>2.4.0  >=2.4.0 <2.5, the pessimistic operator of release series
+//	^7.0.10  >=7.0.10 <8, the first non-zero number is fixed
+//	7.0.x    7.0 and every version in it, as does 7.0.*
+//
+// A version alone means "=". Versions may carry a revision, ">=2.2.4:1";
+// versions without one match every revision. The upper bound of ~, ~> and ^
+// leaves out its own pre-releases: ~7.0 does not match 7.1-rc1.
+type Constraint struct {
+	raw          string
+	alternatives [][]comparison
+}
+
+type comparison struct {
+	op string
+	v  Version
+	// series is the prefix of the "x" wildcard.
+	series string
+	// release leaves out the pre-releases of v, for the "<" bounds of
+	// ~, ~> and ^.
+	release bool
+}
+
+var operators = []string{"~>", ">=", "<=", "!=", "==", "=", ">", "<", "~", "^"}
+
+// ParseConstraint reads a constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+		var comparisons []comparison
+		for i := 0; i < len(fields); i++ {
+			term := fields[i]
+			if isOperator(term) {
+				if i+1 == len(fields) {
+					return nil, fmt.Errorf("version: operator %q without version in %q", term, s)
+				}
+				i++
+				term += fields[i]
+			}
+			parsed, err := parseComparison(term)
+			if err != nil {
+				return nil, fmt.Errorf("%v in %q", err, s)
+			}
+			comparisons = append(comparisons, parsed...)
+		}
+		if len(comparisons) == 0 {
+			return nil, fmt.Errorf("version: empty constraint in %q", s)
+		}
+		c.alternatives = append(c.alternatives, comparisons)
+	}
+	return c, nil
+}
+
+// MustParseConstraint is like ParseConstraint but panics on invalid
+// constraints.
+func MustParseConstraint(s string) *Constraint {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func isOperator(s string) bool {
+	for _, op := range operators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseComparison(term string) ([]comparison, error) {
+	op := ""
+	for _, candidate := range operators {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			break
+		}
+	}
+	rest := strings.TrimSpace(term[len(op):])
+	if op == "==" {
+		op = "="
+	}
+
+	if op == "" || op == "=" {
+		switch {
+		case rest == "*" || rest == "x":
+			return []comparison{{op: "*"}}, nil
+		case strings.HasSuffix(rest, ".*") || strings.HasSuffix(rest, ".x"):
+			series := rest[:len(rest)-2]
+			if _, err := Parse(series); err != nil {
+				return nil, err
+			}
+			return []comparison{{op: "*", series: series}}, nil
+		}
+		op = "="
+	}
+
+	v, err := Parse(rest)
+	if err != nil {
+		return nil, err
+	}
+	if op != "~" && op != "~>" && op != "^" {
+		return []comparison{{op: op, v: v}}, nil
+	}
+
+	n := leadingNumbers(v)
+	if n == 0 {
+		return nil, fmt.Errorf("version: %s needs a numeric version, got %q", op, rest)
+	}
+	var index int
+	switch op {
+	case "~":
+		if n > 1 {
+			index = 1
+		}
+	case "~>":
+		if n > 1 {
+			index = n - 2
+		}
+	case "^":
+		index = n - 1
+		for i := 0; i < n; i++ {
+			if v.parts[i].number != 0 {
+				index = i
+				break
+			}
+		}
+	}
+	return []comparison{{op: ">=", v: v}, {op: "<", v: bump(v, index), release: true}}, nil
+}
+
+// leadingNumbers counts the numbers v starts with.
+func leadingNumbers(v Version) int {
+	n := 0
+	for _, p := range v.parts {
+		if !p.numeric {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// bump returns the version made of the numbers of v up to index, the last
+// one incremented: bump("7.0.10", 1) is "7.1".
+func bump(v Version, index int) Version {
+	numbers := make([]string, index+1)
+	for i := 0; i <= index; i++ {
+		n := v.parts[i].number
+		if i == index {
+			n++
+		}
+		numbers[i] = strconv.FormatInt(n, 10)
+	}
+	return MustParse(strings.Join(numbers, "."))
+}
+
+// Check tells whether v satisfies c.
+func (c *Constraint) Check(v Version) bool {
+	for _, alternative := range c.alternatives {
+		ok := true
+		for _, cmp := range alternative {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp comparison) check(v Version) bool {
+	if cmp.op == "*" {
+		return cmp.series == "" || v.InSeries(cmp.series)
+	}
+	c := Compare(v, cmp.v)
+	switch cmp.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0 && !(cmp.release && preReleaseOf(v, cmp.v))
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// preReleaseOf tells whether v is a pre-release of release, like 7.1-rc1
+// of 7.1.
+func preReleaseOf(v, release Version) bool {
+	for i, p := range v.parts {
+		if p.pre {
+			return compareParts(v.parts[:i], release.parts) == 0
+		}
+	}
+	return false
+}
+
+// String returns the constraint as parsed.
+func (c *Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,57 @@
+package version
+
+import "testing"
+
+func TestConstraint(t *testing.T) {
+	var cases = []struct {
+		constraint string
+		matches    []string
+		misses     []string
+	}{
+		{"~7.0", []string{"7.0", "7.0.10:2", "7.0.99"}, []string{"6.9", "7.1.0", "8.0", "7.1-rc1", "7.1.0-beta2", "7.0-rc1"}},
+		{"~7.0.10", []string{"7.0.10", "7.0.11"}, []string{"7.0.9", "7.1"}},
+		{"~7", []string{"7.0", "7.9.9"}, []string{"6.9", "8.0"}},
+		{"~> 2.4.0", []string{"2.4.0", "2.4.23"}, []string{"2.3.9", "2.5.0"}},
+		{"~>2.4", []string{"2.4", "2.9"}, []string{"3.0"}},
+		{"^7.0.10", []string{"7.0.10", "7.9"}, []string{"7.0.9", "8.0", "8.0.0-rc1"}},
+		{"^0.3.1", []string{"0.3.1", "0.3.9"}, []string{"0.4.0"}},
+		{">=5.6 <7", []string{"5.6", "5.6.24", "6.0", "6.9.9-rc1"}, []string{"5.5.9", "7.0", "7.0.10"}},
+		{">= 5.6, < 7", []string{"5.6.24"}, []string{"7.0.10"}},
+		{"7.0.x", []string{"7.0", "7.0.10:2"}, []string{"7.1.0", "70.0"}},
+		{"7.*", []string{"7.0", "7.1.2"}, []string{"8.0"}},
+		{"*", []string{"1.0", "wheezy"}, nil},
+		{"7.0.10", []string{"7.0.10", "7.0.10:3"}, []string{"7.0.1", "7.0.10.1"}},
+		{"=7.0", []string{"7.0", "7.0.0", "7.0.0:3"}, []string{"7.0.1", "7.0.0-rc1"}},
+		{">=1.0.2 <=1.0.2g", []string{"1.0.2", "1.0.2a", "1.0.2g"}, []string{"1.0.1u", "1.0.2h", "1.0.2-rc1"}},
+		{"=7.0.10:2", []string{"7.0.10:2", "7.0.10"}, []string{"7.0.10:3"}},
+		{">2.2.4:1", []string{"2.2.4:2", "2.2.5:0"}, []string{"2.2.4:1", "2.2.4:0"}},
+		{"!=5.6.24", []string{"5.6.23"}, []string{"5.6.24"}},
+		{"<5.6 || >=7.0", []string{"5.5", "7.0.10"}, []string{"5.6.1", "6.0"}},
+	}
+	for _, c := range cases {
+		constraint, err := ParseConstraint(c.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) returned error: %v", c.constraint, err)
+			continue
+		}
+		for _, v := range c.matches {
+			if !constraint.Check(MustParse(v)) {
+				t.Errorf("%q does not match %s", c.constraint, v)
+			}
+		}
+		for _, v := range c.misses {
+			if constraint.Check(MustParse(v)) {
+				t.Errorf("%q matches %s", c.constraint, v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"", ">=", "~wheezy", ">=7.0 ||", "7.0 <", ">= >= 7"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("ParseConstraint(%q) returned no error", bad)
+		}
+	}
+	if s := MustParseConstraint(" >=5.6 <7 ").String(); s != ">=5.6 <7" {
+		t.Errorf("String returned %q", s)
+	}
+}
@@ -0,0 +1,337 @@
+// Package version parses and compares the versions of Stacksmith components.
+//
+// Components do not follow semver: versions look like "7.0.10", "2.4.17-1",
+// "1.0.2h", "9.3.11.v20160721", "14.04" or "wheezy", and Stacksmith adds a
+// revision number to each. Versions are compared piece by piece, numbers
+// numerically and words lexically, trailing zeros aside so that "7.0" is
+// "7.0.0". Pre-release words such as "rc" or "beta" sort before the release
+// when they follow a separator, as in "7.0.0-rc1"; a letter glued to a
+// number, as in OpenSSL's "1.0.2h", sorts after it. The revision breaks ties.
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// Version is a component version with its revision.
+type Version struct {
+	// Version is the version as written by Stacksmith, like "7.0.10".
+	Version  string
+	Revision int
+	// HasRevision tells whether Revision was given. A version without
+	// revision is equal to every revision of the same version.
+	HasRevision bool
+	// Branch is the branch the version is published in, like "stable". It
+	// does not take part in comparisons.
+	Branch string
+
+	parts []part
+}
+
+// part is a run of digits or of other characters.
+type part struct {
+	number  int64
+	word    string
+	numeric bool
+	// pre marks a pre-release word, one of preRelease following a
+	// separator.
+	pre bool
+}
+
+// preRelease are the words marking versions released before the version
+// they are appended to, when a separator comes before them.
+var preRelease = map[string]bool{
+	"alpha": true, "a": true, "beta": true, "b": true, "rc": true, "pre": true, "preview": true, "dev": true, "snapshot": true,
+}
+
+// Parse reads a version, optionally followed by its revision as in
+// "2.2.4:1", the form used by vulnerability ranges.
+func Parse(s string) (Version, error) {
+	s = strings.TrimSpace(s)
+	v := Version{Version: s}
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		revision, err := strconv.Atoi(s[i+1:])
+		if err != nil || revision < 0 {
+			return Version{}, fmt.Errorf("version: invalid revision in %q", s)
+		}
+		v.Version, v.Revision, v.HasRevision = s[:i], revision, true
+	}
+	if v.Version == "" || strings.ContainsAny(v.Version, " \t<>=~^|,*") {
+		return Version{}, fmt.Errorf("version: invalid version %q", s)
+	}
+	v.parts = split(v.Version)
+	if len(v.parts) == 0 {
+		return Version{}, fmt.Errorf("version: invalid version %q", s)
+	}
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid versions.
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// New returns the version with revision, as found in components.
+func New(version string, revision int) (Version, error) {
+	v, err := Parse(version)
+	if err != nil {
+		return Version{}, err
+	}
+	if v.HasRevision {
+		return Version{}, fmt.Errorf("version: invalid version %q", version)
+	}
+	v.Revision, v.HasRevision = revision, true
+	return v, nil
+}
+
+// FromComponent returns the version of c.
+func FromComponent(c stacksmith.Component) (Version, error) {
+	v, err := New(c.Version, c.Revision)
+	if err != nil {
+		return Version{}, err
+	}
+	v.Branch = c.Branch
+	return v, nil
+}
+
+// Latest returns the latest version of c, as Stacksmith reports it.
+func Latest(c stacksmith.Component) (Version, error) {
+	v, err := New(c.Latest.Version, c.Latest.Revision)
+	if err != nil {
+		return Version{}, err
+	}
+	v.Branch = c.Branch
+	return v, nil
+}
+
+// FromItem returns the versions of item, newest first, skipping those that
+// do not parse.
+func FromItem(item *stacksmith.Item) []Version {
+	var versions []Version
+	for _, iv := range item.Versions {
+		v, err := New(iv.Version, iv.Revision)
+		if err != nil {
+			continue
+		}
+		v.Branch = iv.Branch
+		versions = append(versions, v)
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[j].Less(versions[i]) })
+	return versions
+}
+
+func split(s string) []part {
+	var parts []part
+	for i := 0; i < len(s); {
+		c := s[i]
+		j := i
+		switch {
+		case isDigit(c):
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			n, err := strconv.ParseInt(s[i:j], 10, 64)
+			if err != nil {
+				parts = append(parts, part{word: s[i:j]})
+			} else {
+				parts = append(parts, part{number: n, numeric: true})
+			}
+		case isSeparator(c):
+			j++
+		default:
+			for j < len(s) && !isDigit(s[j]) && !isSeparator(s[j]) {
+				j++
+			}
+			word := strings.ToLower(s[i:j])
+			parts = append(parts, part{word: word, pre: i > 0 && isSeparator(s[i-1]) && preRelease[word]})
+		}
+		i = j
+	}
+	return parts
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isSeparator(c byte) bool {
+	return c == '.' || c == '-' || c == '_' || c == '+'
+}
+
+// String returns the version as written by Stacksmith, followed by
+// ":revision" when it has one.
+func (v Version) String() string {
+	if !v.HasRevision {
+		return v.Version
+	}
+	return v.Version + ":" + strconv.Itoa(v.Revision)
+}
+
+// Full returns the version with its revision as Stacksmith shows it, like
+// "2.2.3-3" or "1.0.2h-0". An empty version stays empty.
+func (v Version) Full() string {
+	if !v.HasRevision || v.Version == "" {
+		return v.Version
+	}
+	return v.Version + "-" + strconv.Itoa(v.Revision)
+}
+
+// Format returns version with revision as Stacksmith shows them, like
+// "2.2.3-3", for the versions and revisions of components and items.
+func Format(version string, revision int) string {
+	return Version{Version: version, Revision: revision, HasRevision: true}.Full()
+}
+
+// Compare returns -1, 0 or 1 when a is older than, the same as or newer
+// than b. Revisions are only compared when both versions have one.
+func Compare(a, b Version) int {
+	if c := compareParts(a.parts, b.parts); c != 0 {
+		return c
+	}
+	if !a.HasRevision || !b.HasRevision {
+		return 0
+	}
+	switch {
+	case a.Revision < b.Revision:
+		return -1
+	case a.Revision > b.Revision:
+		return 1
+	}
+	return 0
+}
+
+func compareParts(a, b []part) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePart(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -tail(b[len(a):])
+	case len(a) > len(b):
+		return tail(a[len(b):])
+	}
+	return 0
+}
+
+// tail compares a version ending with parts to the same version without
+// them: "1.0.2h" is newer than "1.0.2", "7.0.0-rc1" older than "7.0.0" and
+// "7.0.0" the same as "7.0".
+func tail(parts []part) int {
+	for _, p := range parts {
+		switch {
+		case p.numeric && p.number == 0:
+			continue
+		case p.pre:
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func comparePart(a, b part) int {
+	switch {
+	case a.numeric && b.numeric:
+		switch {
+		case a.number < b.number:
+			return -1
+		case a.number > b.number:
+			return 1
+		}
+		return 0
+	case a.numeric:
+		return 1
+	case b.numeric:
+		return -1
+	}
+	if a.pre != b.pre {
+		if a.pre {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.word, b.word)
+}
+
+// Less tells whether v is older than o.
+func (v Version) Less(o Version) bool {
+	return Compare(v, o) < 0
+}
+
+// Equal tells whether v and o are the same version.
+func (v Version) Equal(o Version) bool {
+	return Compare(v, o) == 0
+}
+
+// Sort sorts versions from the oldest.
+func Sort(versions []Version) {
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Less(versions[j]) })
+}
+
+// SeriesDepth is the number of numbers naming a release series, "7.0" for
+// "7.0.10".
+const SeriesDepth = 2
+
+// Series returns the release series of v, its first SeriesDepth numbers
+// like "7.0", or the version itself when it does not start with a number.
+func (v Version) Series() string {
+	var numbers []string
+	for _, p := range v.parts {
+		if !p.numeric || len(numbers) == SeriesDepth {
+			break
+		}
+		numbers = append(numbers, strconv.FormatInt(p.number, 10))
+	}
+	if len(numbers) == 0 {
+		return v.Version
+	}
+	return strings.Join(numbers, ".")
+}
+
+// SameSeries tells whether v and o belong to the same release series.
+func (v Version) SameSeries(o Version) bool {
+	return v.Series() == o.Series()
+}
+
+// InSeries tells whether v starts with the numbers of series, so that
+// "7.0.10" is in "7", "7.0" and "7.0.10" but not in "7.1".
+func (v Version) InSeries(series string) bool {
+	prefix := split(series)
+	if len(prefix) == 0 || len(prefix) > len(v.parts) {
+		return false
+	}
+	for i, p := range prefix {
+		if comparePart(p, v.parts[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ReleaseSeries returns the release series of item that v belongs to, as
+// listed in Item.ReleaseSeries, or false when none does.
+func ReleaseSeries(item *stacksmith.Item, v Version) (string, bool) {
+	for _, series := range item.ReleaseSeries {
+		if series.Payload != "" {
+			if c, err := ParseConstraint(series.Payload); err == nil && c.Check(v) {
+				return series.Version, true
+			}
+			continue
+		}
+		if v.InSeries(series.Version) {
+			return series.Version, true
+		}
+	}
+	return "", false
+}
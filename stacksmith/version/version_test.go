@@ -0,0 +1,173 @@
+package version
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+func TestParse(t *testing.T) {
+	var cases = []struct {
+		in          string
+		version     string
+		revision    int
+		hasRevision bool
+	}{
+		{"7.0.10", "7.0.10", 0, false},
+		{"2.2.4:1", "2.2.4", 1, true},
+		{" 9.3.11.v20160721 ", "9.3.11.v20160721", 0, false},
+		{"wheezy", "wheezy", 0, false},
+	}
+	for _, c := range cases {
+		v, err := Parse(c.in)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", c.in, err)
+			continue
+		}
+		if v.Version != c.version || v.Revision != c.revision || v.HasRevision != c.hasRevision {
+			t.Errorf("Parse(%q) returned %+v", c.in, v)
+		}
+	}
+
+	for _, bad := range []string{"", ":1", "7.0:x", "7.0:-1", ">=7.0", "7.0 || 8", "..."} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) returned no error", bad)
+		}
+	}
+	if _, err := New("2.2.4:1", 2); err == nil {
+		t.Errorf("New with two revisions returned no error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	var cases = []struct {
+		a, b string
+		want int
+	}{
+		{"7.0.10:2", "7.0.9:5", 1},
+		{"7.0.10:2", "7.0.10:5", -1},
+		{"7.0.10", "7.0.10:5", 0},
+		{"7.0.10", "7.0.10", 0},
+		{"7.0", "7.0.0", 0},
+		{"7.0.0:1", "7.0:0", 1},
+		{"7.0.0.1", "7.0", 1},
+		{"7.0.0-rc1", "7.0", -1},
+		{"7.0.0-rc1", "7.0.0", -1},
+		{"7.0.0-RC2", "7.0.0-rc1", 1},
+		{"7.0.0-beta3", "7.0.0-rc1", -1},
+		{"1.0.2h", "1.0.2", 1},
+		{"1.0.2a", "1.0.2", 1},
+		{"1.0.2h", "1.0.2a", 1},
+		{"1.0.2b", "1.0.2-b1", 1},
+		{"7.0.0.beta2", "7.0.0", -1},
+		{"1.0.2h", "1.0.2g", 1},
+		{"1.0.2h", "1.0.10", -1},
+		{"2.4.17-1", "2.4.17", 1},
+		{"2.4.17-1", "2.4.17-0", 1},
+		{"2.4.12-4", "2.4.17-0", -1},
+		{"9.3.11.v20160721", "9.3.10.v20160621", 1},
+		{"14.04", "16.04", -1},
+		{"5.6.24", "5.10", -1},
+	}
+	for _, c := range cases {
+		a, b := MustParse(c.a), MustParse(c.b)
+		if got := Compare(a, b); got != c.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := Compare(b, a); got != -c.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", c.b, c.a, got, -c.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	var versions []Version
+	for _, s := range []string{"7.0.10:2", "5.6.24", "7.0.9:5", "7.0.0-rc1", "7.0.10:0", "7.0.0"} {
+		versions = append(versions, MustParse(s))
+	}
+	Sort(versions)
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.String())
+	}
+	expected := []string{"5.6.24", "7.0.0-rc1", "7.0.0", "7.0.9:5", "7.0.10:0", "7.0.10:2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Sort returned %v, want %v", got, expected)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	v := MustParse("7.0.10:2")
+	if v.Series() != "7.0" || MustParse("wheezy").Series() != "wheezy" || MustParse("14.04").Series() != "14.4" {
+		t.Errorf("Series returned %q", v.Series())
+	}
+	if !v.SameSeries(MustParse("7.0.1")) || v.SameSeries(MustParse("7.1.0")) {
+		t.Errorf("SameSeries is wrong")
+	}
+	for series, want := range map[string]bool{"7": true, "7.0": true, "7.0.10": true, "7.1": false, "7.0.10.1": false, "": false} {
+		if got := v.InSeries(series); got != want {
+			t.Errorf("InSeries(%q) = %v, want %v", series, got, want)
+		}
+	}
+}
+
+func TestItem(t *testing.T) {
+	item := new(stacksmith.Item)
+	if err := json.Unmarshal(utils.GetJSON("component"), item); err != nil {
+		t.Fatal(err)
+	}
+
+	versions := FromItem(item)
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.Full())
+	}
+	expected := []string{"2.4.23-1", "2.4.20-0", "2.4.17-1-3", "2.4.17-0-0", "2.4.12-4-1", "2.4.12-3-0", "2.4.12-2-2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("FromItem returned %v, want %v", got, expected)
+	}
+	if versions[0].Branch != "stable" {
+		t.Errorf("FromItem lost the branch: %+v", versions[0])
+	}
+
+	if series, ok := ReleaseSeries(item, versions[0]); !ok || series != "2.4" {
+		t.Errorf("ReleaseSeries returned %q, %v", series, ok)
+	}
+	if _, ok := ReleaseSeries(item, MustParse("2.2.31")); ok {
+		t.Errorf("ReleaseSeries found a series for 2.2.31")
+	}
+}
+
+func TestComponent(t *testing.T) {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	current, err := FromComponent(stack.Components[0])
+	if err != nil {
+		t.Fatalf("FromComponent returned error: %v", err)
+	}
+	latest, _ := Latest(stack.Os)
+	if current.String() != "2.2.3:3" || current.Branch != "stable" || latest.Full() != "wheezy-9" {
+		t.Errorf("FromComponent returned %v, Latest returned %v", current, latest)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		version  string
+		revision int
+		want     string
+	}{
+		{"2.2.3", 3, "2.2.3-3"},
+		{"1.0.2h", 0, "1.0.2h-0"},
+		{"", 0, ""},
+	} {
+		if got := Format(c.version, c.revision); got != c.want {
+			t.Errorf("Format(%q, %d) = %q, want %q", c.version, c.revision, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package vulns
+
+import (
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// Affects tells whether v of component is inside one of the ranges of
+// vulnerability. Range bounds are inclusive and an empty bound is open. A
+// bound that does not parse cannot rule v out, so its range matches.
+func Affects(vulnerability stacksmith.VulnerabilityItem, component string, v version.Version) bool {
+	for _, r := range vulnerability.Ranges {
+		if r.Component == component && inRange(v, r.From, r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange(v version.Version, from, to string) bool {
+	if from != "" {
+		if min, err := version.Parse(from); err == nil && v.Less(min) {
+			return false
+		}
+	}
+	if to != "" {
+		if max, err := version.Parse(to); err == nil && max.Less(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Affecting returns the vulnerabilities affecting v of component.
+func Affecting(vulnerabilities []stacksmith.VulnerabilityItem, component string, v version.Version) []stacksmith.VulnerabilityItem {
+	var affecting []stacksmith.VulnerabilityItem
+	for _, vulnerability := range vulnerabilities {
+		if Affects(vulnerability, component, v) {
+			affecting = append(affecting, vulnerability)
+		}
+	}
+	return affecting
+}
+
+// SafeUpgrade returns the oldest version of item newer than current and in
+// its branch that none of vulnerabilities affects, or false when there is
+// none. item is the component as returned by Discovery.GetComponent.
+func SafeUpgrade(item *stacksmith.Item, current version.Version, vulnerabilities []stacksmith.VulnerabilityItem) (version.Version, bool) {
+	versions := version.FromItem(item)
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if !current.Less(v) || (current.Branch != "" && v.Branch != current.Branch) {
+			continue
+		}
+		if len(Affecting(vulnerabilities, item.ID, v)) == 0 {
+			return v, true
+		}
+	}
+	return version.Version{}, false
+}
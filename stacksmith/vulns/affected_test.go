@@ -0,0 +1,99 @@
+package vulns
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+func TestAffecting(t *testing.T) {
+	ruby := testStack(t).Components[0]
+	var cases = []struct {
+		version string
+		want    []string
+	}{
+		{"2.2.3:3", []string{"CVE-2015-7551", "CVE-2015-3197", "CVE-2016-0800", "CVE-2016-2107", "CVE-2016-2105", "CVE-2016-2106", "CVE-2016-2109", "CVE-2016-2176"}},
+		{"2.2.4:2", []string{"CVE-2016-0800", "CVE-2016-2107", "CVE-2016-2105", "CVE-2016-2106", "CVE-2016-2109", "CVE-2016-2176"}},
+		{"2.2.5", []string{"CVE-2016-2107", "CVE-2016-2105", "CVE-2016-2106", "CVE-2016-2109", "CVE-2016-2176"}},
+		{"2.2.5:1", nil},
+		{"2.1.9", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, v := range Affecting(ruby.Vulnerabilities.Items, "ruby", version.MustParse(c.version)) {
+			got = append(got, v.Name)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("Affecting(%s) returned %q, want %q", c.version, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("Affecting(%s) returned %q, want %q", c.version, got, c.want)
+				break
+			}
+		}
+	}
+
+	items := testItems(t, `{"items":[
+		{"name":"open","ranges":[{"component":"ruby","from":"","to":"2.0"}]},
+		{"name":"unparsed","ranges":[{"component":"ruby","from":"2.2","to":"<latest>"}]}]}`)
+	if !Affects(items[0], "ruby", version.MustParse("1.8.7")) || Affects(items[0], "ruby", version.MustParse("2.0.1")) {
+		t.Errorf("Affects is wrong with an open range")
+	}
+	if !Affects(items[1], "ruby", version.MustParse("2.3")) || Affects(items[1], "nginx", version.MustParse("2.3")) {
+		t.Errorf("Affects is wrong with an unparsed bound")
+	}
+}
+
+func TestSafeUpgrade(t *testing.T) {
+	item := new(stacksmith.Item)
+	if err := json.Unmarshal(utils.GetJSON("component"), item); err != nil {
+		t.Fatal(err)
+	}
+	items := testItems(t, `{"items":[
+		{"name":"CVE-2016-5387","severity":"high","ranges":[{"component":"apache","from":"2.4.12","to":"2.4.17-1:3"}]},
+		{"name":"CVE-2016-0736","severity":"medium","ranges":[{"component":"apache","from":"2.4.20","to":"2.4.20"}]}]}`)
+
+	current, _ := version.New("2.4.12-2", 2)
+	current.Branch = "stable"
+	upgrade, ok := SafeUpgrade(item, current, items)
+	if !ok || upgrade.Full() != "2.4.23-1" {
+		t.Errorf("SafeUpgrade returned %v, %v, want 2.4.23-1", upgrade.Full(), ok)
+	}
+	if upgrade, ok := SafeUpgrade(item, current, nil); !ok || upgrade.Full() != "2.4.12-3-0" {
+		t.Errorf("SafeUpgrade without vulnerabilities returned %v, %v", upgrade.Full(), ok)
+	}
+
+	current.Branch = "testing"
+	if _, ok := SafeUpgrade(item, current, items); ok {
+		t.Errorf("SafeUpgrade left the branch")
+	}
+}
+
+func TestSafeUpgrade_letterReleases(t *testing.T) {
+	item := new(stacksmith.Item)
+	if err := json.Unmarshal([]byte(`{"id":"openssl","versions":[
+		{"version":"1.0.2h","revision":0},
+		{"version":"1.0.2g","revision":1},
+		{"version":"1.0.2b","revision":0},
+		{"version":"1.0.2a","revision":0},
+		{"version":"1.0.2","revision":0}]}`), item); err != nil {
+		t.Fatal(err)
+	}
+	items := testItems(t, `{"items":[
+		{"name":"CVE-2016-2107","severity":"high","ranges":[{"component":"openssl","from":"1.0.2","to":"1.0.2g"}]}]}`)
+
+	for s, want := range map[string]bool{"1.0.2": true, "1.0.2a": true, "1.0.2g:1": true, "1.0.2h": false, "1.0.1u": false} {
+		if got := Affects(items[0], "openssl", version.MustParse(s)); got != want {
+			t.Errorf("Affects(openssl %s) = %v, want %v", s, got, want)
+		}
+	}
+	current, _ := version.New("1.0.2a", 0)
+	if upgrade, ok := SafeUpgrade(item, current, items); !ok || upgrade.Full() != "1.0.2h-0" {
+		t.Errorf("SafeUpgrade from 1.0.2a returned %v, %v, want 1.0.2h-0", upgrade.Full(), ok)
+	}
+}
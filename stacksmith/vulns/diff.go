@@ -0,0 +1,168 @@
+// Package vulns compares and reasons about the vulnerabilities of stacks.
+package vulns
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// Change statuses.
+const (
+	Fixed      = "fixed"
+	Introduced = "introduced"
+	Unchanged  = "unchanged"
+)
+
+// Key identifies a vulnerability of a component. Component is empty for the
+// vulnerabilities Stacksmith does not attach to any.
+type Key struct {
+	Name      string
+	Component string
+}
+
+// Entry is a vulnerability of a component of a stack.
+type Entry struct {
+	Key
+	Severity string
+	// Version is the version and revision of the component in the stack,
+	// empty for the vulnerabilities of the stack as a whole.
+	Version string
+	// Item is the vulnerability as Stacksmith lists it.
+	Item stacksmith.VulnerabilityItem
+}
+
+// Set returns the vulnerabilities of stack, as merged by Merge, by key.
+func Set(stack *stacksmith.Stack, vulnerabilities []stacksmith.VulnerabilityItem) map[Key]Entry {
+	set := make(map[Key]Entry)
+	for _, entry := range Merge(stack, vulnerabilities) {
+		set[entry.Key] = entry
+	}
+	return set
+}
+
+// Versions maps the IDs of the OS and components of stack to their version
+// and revision, like "2.2.3-3".
+func Versions(stack *stacksmith.Stack) map[string]string {
+	versions := make(map[string]string)
+	for _, c := range append([]stacksmith.Component{stack.Os}, stack.Components...) {
+		if c.ID != "" {
+			versions[c.ID] = version.Format(c.Version, c.Revision)
+		}
+	}
+	return versions
+}
+
+// Change is a vulnerability of a component in a Diff.
+type Change struct {
+	Status        string `json:"status"`
+	Vulnerability string `json:"vulnerability"`
+	Component     string `json:"component,omitempty"`
+	Severity      string `json:"severity"`
+	// OldVersion and NewVersion are the versions of the component in the
+	// old and new stacks.
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	// Cause explains a fixed or introduced vulnerability.
+	Cause string `json:"cause,omitempty"`
+}
+
+// Diff is the difference between the vulnerabilities of two stacks, or of
+// a stack before and after its regeneration.
+type Diff struct {
+	Old        string   `json:"old"`
+	New        string   `json:"new"`
+	Fixed      []Change `json:"fixed"`
+	Introduced []Change `json:"introduced"`
+	Unchanged  []Change `json:"unchanged"`
+}
+
+// Compare returns the Diff between the vulnerabilities of two stacks, each
+// given with the vulnerabilities listed by Stacks.GetAllVulnerabilities.
+func Compare(oldStack *stacksmith.Stack, oldVulnerabilities []stacksmith.VulnerabilityItem, newStack *stacksmith.Stack, newVulnerabilities []stacksmith.VulnerabilityItem) *Diff {
+	before, after := Set(oldStack, oldVulnerabilities), Set(newStack, newVulnerabilities)
+	oldVersions, newVersions := Versions(oldStack), Versions(newStack)
+	d := &Diff{Old: oldStack.ID, New: newStack.ID, Fixed: []Change{}, Introduced: []Change{}, Unchanged: []Change{}}
+
+	for key, e := range before {
+		c := Change{
+			Vulnerability: key.Name,
+			Component:     key.Component,
+			Severity:      e.Severity,
+			OldVersion:    oldVersions[key.Component],
+			NewVersion:    newVersions[key.Component],
+		}
+		if n, ok := after[key]; ok {
+			c.Status = Unchanged
+			c.Severity = n.Severity
+			d.Unchanged = append(d.Unchanged, c)
+			continue
+		}
+		c.Status = Fixed
+		c.Cause = cause(c)
+		d.Fixed = append(d.Fixed, c)
+	}
+	for key, e := range after {
+		if _, ok := before[key]; ok {
+			continue
+		}
+		c := Change{
+			Status:        Introduced,
+			Vulnerability: key.Name,
+			Component:     key.Component,
+			Severity:      e.Severity,
+			OldVersion:    oldVersions[key.Component],
+			NewVersion:    newVersions[key.Component],
+		}
+		c.Cause = cause(c)
+		d.Introduced = append(d.Introduced, c)
+	}
+
+	for _, changes := range [][]Change{d.Fixed, d.Introduced, d.Unchanged} {
+		sortChanges(changes)
+	}
+	return d
+}
+
+// cause tells which component change made a vulnerability appear or go.
+func cause(c Change) string {
+	switch {
+	case c.Component == "":
+		return "stack vulnerabilities changed"
+	case c.OldVersion == "" && c.NewVersion != "":
+		return fmt.Sprintf("%s %s added", c.Component, c.NewVersion)
+	case c.OldVersion != "" && c.NewVersion == "":
+		return fmt.Sprintf("%s %s removed", c.Component, c.OldVersion)
+	case c.OldVersion != c.NewVersion:
+		return fmt.Sprintf("%s changed from %s to %s", c.Component, c.OldVersion, c.NewVersion)
+	}
+	return fmt.Sprintf("%s %s unchanged, vulnerability data updated", c.Component, c.NewVersion)
+}
+
+func sortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Component != changes[j].Component {
+			return changes[i].Component < changes[j].Component
+		}
+		return changes[i].Vulnerability < changes[j].Vulnerability
+	})
+}
+
+// Changes returns the fixed, introduced and unchanged vulnerabilities keyed
+// by vulnerability name and component.
+func (d *Diff) Changes() map[Key]Change {
+	changes := make(map[Key]Change)
+	for _, list := range [][]Change{d.Fixed, d.Introduced, d.Unchanged} {
+		for _, c := range list {
+			changes[Key{Name: c.Vulnerability, Component: c.Component}] = c
+		}
+	}
+	return changes
+}
+
+// Empty tells whether no vulnerability was fixed or introduced.
+func (d *Diff) Empty() bool {
+	return len(d.Fixed) == 0 && len(d.Introduced) == 0
+}
@@ -0,0 +1,116 @@
+package vulns
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testStack(t *testing.T) *stacksmith.Stack {
+	stack := new(stacksmith.Stack)
+	if err := json.Unmarshal(utils.GetJSON("stack"), stack); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func testItems(t *testing.T, data string) []stacksmith.VulnerabilityItem {
+	var list stacksmith.Vulnerability
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list.Items
+}
+
+// testRegeneration returns the diff of the fixture stack before and after a
+// regeneration upgrading ruby and adding nginx.
+func testRegeneration(t *testing.T) *Diff {
+	oldStack, newStack := testStack(t), testStack(t)
+	ruby := &newStack.Components[0]
+	ruby.Version, ruby.Revision = "2.2.5", 0
+	ruby.Vulnerabilities.Items = testItems(t, `{"items":[
+		{"name":"CVE-2015-3197","severity":"low"},
+		{"name":"CVE-2016-2105","severity":"medium"}]}`)
+	newStack.Components = append(newStack.Components, stacksmith.Component{ID: "nginx", Version: "1.10.1", Revision: 2})
+
+	oldVulnerabilities := testItems(t, `{"items":[{"name":"USN-3000-1","severity":"low"}]}`)
+	newVulnerabilities := testItems(t, `{"items":[
+		{"name":"CVE-2016-4450","severity":"high","ranges":[{"component":"nginx","from":"1.10","to":"1.10.1"}]},
+		{"name":"CVE-2016-9999","severity":"low","ranges":[{"component":"debian","from":"wheezy","to":"wheezy"}]}]}`)
+	return Compare(oldStack, oldVulnerabilities, newStack, newVulnerabilities)
+}
+
+func TestCompare(t *testing.T) {
+	d := testRegeneration(t)
+
+	var got []string
+	for _, c := range append(append(d.Fixed, d.Introduced...), d.Unchanged...) {
+		got = append(got, c.Status+" "+c.Vulnerability+"@"+c.Component+" "+c.Severity)
+	}
+	expected := []string{
+		"fixed USN-3000-1@ low",
+		"fixed CVE-2015-7551@ruby medium",
+		"fixed CVE-2016-0800@ruby high",
+		"fixed CVE-2016-2106@ruby low",
+		"fixed CVE-2016-2107@ruby high",
+		"fixed CVE-2016-2109@ruby low",
+		"fixed CVE-2016-2176@ruby low",
+		"introduced CVE-2016-9999@debian low",
+		"introduced CVE-2016-4450@nginx high",
+		"unchanged CVE-2015-3197@ruby low",
+		"unchanged CVE-2016-2105@ruby medium",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Compare returned %q, want %q", got, expected)
+	}
+
+	changes := d.Changes()
+	causes := map[Key]string{
+		{Name: "CVE-2016-0800", Component: "ruby"}:   "ruby changed from 2.2.3-3 to 2.2.5-0",
+		{Name: "CVE-2016-4450", Component: "nginx"}:  "nginx 1.10.1-2 added",
+		{Name: "CVE-2016-9999", Component: "debian"}: "debian wheezy-7 unchanged, vulnerability data updated",
+		{Name: "USN-3000-1"}:                         "stack vulnerabilities changed",
+		{Name: "CVE-2015-3197", Component: "ruby"}:   "",
+	}
+	for key, cause := range causes {
+		if got := changes[key].Cause; got != cause {
+			t.Errorf("cause of %v is %q, want %q", key, got, cause)
+		}
+	}
+	if d.Empty() || !Compare(testStack(t), nil, testStack(t), nil).Empty() {
+		t.Errorf("Diff.Empty is wrong")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	d := testRegeneration(t)
+	for format, golden := range map[string]string{Text: "diff.txt", JSON: "diff.json", Markdown: "diff.md"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, d, format); err != nil {
+			t.Fatalf("Write(%s) returned error: %v", format, err)
+		}
+		path := filepath.Join("testdata", golden)
+		if *update {
+			ioutil.WriteFile(path, buf.Bytes(), 0644)
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != string(want) {
+			t.Errorf("Write(%s) wrote:\n%s", format, buf.String())
+		}
+	}
+	if err := Write(ioutil.Discard, d, "html"); err == nil {
+		t.Errorf("Write in an unknown format returned no error")
+	}
+}
@@ -0,0 +1,124 @@
+package vulns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats understood by Write.
+const (
+	Text     = "text"
+	JSON     = "json"
+	Markdown = "markdown"
+)
+
+// Write writes d to w in format.
+func Write(w io.Writer, d *Diff, format string) error {
+	switch format {
+	case Text:
+		return WriteText(w, d)
+	case JSON:
+		return WriteJSON(w, d)
+	case Markdown:
+		return WriteMarkdown(w, d)
+	}
+	return fmt.Errorf("vulns: unknown format %q", format)
+}
+
+func (d *Diff) summary() string {
+	return fmt.Sprintf("%d fixed, %d introduced, %d unchanged", len(d.Fixed), len(d.Introduced), len(d.Unchanged))
+}
+
+func (d *Diff) stacks() string {
+	if d.Old == d.New {
+		return d.New
+	}
+	return d.Old + " -> " + d.New
+}
+
+// WriteText writes d as aligned text, leaving the unchanged vulnerabilities
+// to the summary line.
+func WriteText(w io.Writer, d *Diff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Vulnerabilities of %s: %s\n", d.stacks(), d.summary())
+	for _, section := range []struct {
+		title   string
+		changes []Change
+	}{{"Fixed", d.Fixed}, {"Introduced", d.Introduced}} {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\n", section.title)
+		for _, c := range section.changes {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", c.Vulnerability, c.Severity, component(c), c.Cause)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes d as indented JSON.
+func WriteJSON(w io.Writer, d *Diff) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteMarkdown writes d as Markdown tables, suited to review comments. The
+// unchanged vulnerabilities are folded.
+func WriteMarkdown(w io.Writer, d *Diff) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Vulnerabilities of %s\n\n%s.\n", markdownEscape(d.stacks()), d.summary())
+	for _, section := range []struct {
+		title   string
+		changes []Change
+	}{{"Fixed", d.Fixed}, {"Introduced", d.Introduced}} {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n#### %s\n\n", section.title)
+		markdownTable(&b, section.changes)
+	}
+	if len(d.Unchanged) > 0 {
+		fmt.Fprintf(&b, "\n<details>\n<summary>%d unchanged</summary>\n\n", len(d.Unchanged))
+		markdownTable(&b, d.Unchanged)
+		b.WriteString("\n</details>\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownTable(b *strings.Builder, changes []Change) {
+	b.WriteString("| Vulnerability | Severity | Component | Cause |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, c := range changes {
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n",
+			markdownEscape(c.Vulnerability), markdownEscape(c.Severity), markdownEscape(component(c)), markdownEscape(c.Cause))
+	}
+}
+
+// component is the component of c with its versions.
+func component(c Change) string {
+	switch {
+	case c.Component == "":
+		return "-"
+	case c.OldVersion == c.NewVersion:
+		return strings.TrimSpace(c.Component + " " + c.NewVersion)
+	case c.OldVersion == "":
+		return c.Component + " " + c.NewVersion
+	case c.NewVersion == "":
+		return c.Component + " " + c.OldVersion
+	}
+	return c.Component + " " + c.OldVersion + " -> " + c.NewVersion
+}
+
+var markdownReplacer = strings.NewReplacer("|", `\|`, "<", "&lt;", "*", `\*`, "_", `\_`, "`", "\\`")
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
@@ -0,0 +1,73 @@
+package vulns
+
+import (
+	"sort"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/version"
+)
+
+// Merge returns the vulnerabilities found in stack, sorted by component and
+// name, each once per component:
+//
+//   - those Stacksmith attaches to the OS and the components of stack;
+//   - those of vulnerabilities, as returned by Stacks.GetAllVulnerabilities,
+//     for the components of stack whose version their ranges affect;
+//     components missing from stack are left out;
+//   - those of vulnerabilities without ranges, for the stack as a whole,
+//     with an empty component.
+//
+// The first entry of a vulnerability in that order gives its severity.
+func Merge(stack *stacksmith.Stack, vulnerabilities []stacksmith.VulnerabilityItem) []Entry {
+	components := make(map[string]stacksmith.Component)
+	for _, c := range append([]stacksmith.Component{stack.Os}, stack.Components...) {
+		if c.ID != "" {
+			components[c.ID] = c
+		}
+	}
+	seen := make(map[Key]bool)
+	var entries []Entry
+	add := func(component string, item stacksmith.VulnerabilityItem) {
+		key := Key{Name: item.Name, Component: component}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		entry := Entry{Key: key, Severity: item.Severity, Item: item}
+		if c, ok := components[component]; ok {
+			entry.Version = version.Format(c.Version, c.Revision)
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, c := range append([]stacksmith.Component{stack.Os}, stack.Components...) {
+		for _, item := range c.Vulnerabilities.Items {
+			add(c.ID, item)
+		}
+	}
+	for _, item := range vulnerabilities {
+		if len(item.Ranges) == 0 {
+			add("", item)
+		}
+		for _, r := range item.Ranges {
+			if c, ok := components[r.Component]; ok && affects(item, c) {
+				add(c.ID, item)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Component != entries[j].Component {
+			return entries[i].Component < entries[j].Component
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// affects tells whether the ranges of item affect the version of c. A
+// version that does not parse cannot be ruled out.
+func affects(item stacksmith.VulnerabilityItem, c stacksmith.Component) bool {
+	v, err := version.FromComponent(c)
+	return err != nil || Affects(item, c.ID, v)
+}
@@ -0,0 +1,38 @@
+package vulns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	stack := testStack(t)
+	stack.Components[0].Vulnerabilities.Items = stack.Components[0].Vulnerabilities.Items[:1]
+	items := testItems(t, `{"items":[
+		{"name":"CVE-2015-7551","severity":"high","ranges":[{"component":"ruby","from":"2.2","to":"2.2.3"}]},
+		{"name":"CVE-2016-2107","severity":"high","ranges":[{"component":"ruby","from":"2.2","to":"2.2.5"},{"component":"openssl","from":"1.0.2","to":"1.0.2g"}]},
+		{"name":"CVE-2014-8080","severity":"medium","ranges":[{"component":"ruby","from":"2.1","to":"2.2.2"}]},
+		{"name":"CVE-2016-9999","severity":"low","ranges":[{"component":"debian","from":"wheezy","to":"wheezy"}]},
+		{"name":"USN-3000-1","severity":"low"}]}`)
+
+	var got []string
+	for _, e := range Merge(stack, items) {
+		got = append(got, e.Component+" "+e.Version+" "+e.Name+" "+e.Severity)
+	}
+	// The attached CVE-2015-7551 keeps its severity, openssl is not in the
+	// stack and ruby 2.2.3-3 is past CVE-2014-8080.
+	want := []string{
+		"  USN-3000-1 low",
+		"debian wheezy-7 CVE-2016-9999 low",
+		"ruby 2.2.3-3 CVE-2015-7551 medium",
+		"ruby 2.2.3-3 CVE-2016-2107 high",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge returned %q, want %q", got, want)
+	}
+
+	set := Set(stack, items)
+	if _, ok := set[Key{Name: "CVE-2016-2107", Component: "openssl"}]; ok || len(set) != len(want) {
+		t.Errorf("Set returned %v", set)
+	}
+}
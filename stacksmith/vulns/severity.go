@@ -0,0 +1,27 @@
+package vulns
+
+import "strings"
+
+// Severities are the Stacksmith severities, from the least severe.
+var Severities = []string{"none", "low", "medium", "high", "critical"}
+
+// Rank orders severities, ignoring case: 0 for "none", up to 4 for
+// "critical". Empty is "none", and unknown severities rank above "critical",
+// so that they are never taken for harmless.
+func Rank(severity string) int {
+	severity = strings.ToLower(severity)
+	if severity == "" {
+		return 0
+	}
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// KnownSeverity tells whether severity is empty or one of Severities.
+func KnownSeverity(severity string) bool {
+	return Rank(severity) < len(Severities)
+}
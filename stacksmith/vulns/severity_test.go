@@ -0,0 +1,24 @@
+package vulns
+
+import "testing"
+
+func TestRank(t *testing.T) {
+	var cases = []struct {
+		severity string
+		rank     int
+		known    bool
+	}{
+		{"", 0, true},
+		{"none", 0, true},
+		{"Low", 1, true},
+		{"medium", 2, true},
+		{"HIGH", 3, true},
+		{"critical", 4, true},
+		{"catastrophic", 5, false},
+	}
+	for _, c := range cases {
+		if rank, known := Rank(c.severity), KnownSeverity(c.severity); rank != c.rank || known != c.known {
+			t.Errorf("Rank(%q), KnownSeverity(%q) = %d, %t, want %d, %t", c.severity, c.severity, rank, known, c.rank, c.known)
+		}
+	}
+}
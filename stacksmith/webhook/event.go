@@ -0,0 +1,92 @@
+// Package webhook receives the hook deliveries Stacksmith sends to the URLs
+// registered through HooksService.Register.
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// Event names sent by Stacksmith in the "event" field of a delivery.
+const (
+	EventTest            = "test"
+	EventStackUpdated    = "stack.updated"
+	EventStackOutdated   = "stack.outdated"
+	EventStackVulnerable = "stack.vulnerable"
+)
+
+// ErrMissingEvent is returned by ParseEvent when the payload does not name its event.
+var ErrMissingEvent = errors.New("webhook: payload has no event")
+
+// Event is implemented by every typed delivery.
+type Event interface {
+	// Name returns the event name, one of the Event* constants for known events.
+	Name() string
+}
+
+// StackEvent is delivered when one of your stacks is updated, becomes
+// outdated or is found to be vulnerable.
+type StackEvent struct {
+	Event  string           `json:"event"`
+	SentAt string           `json:"sent_at"`
+	Stack  stacksmith.Stack `json:"stack"`
+}
+
+// Name ...
+func (e *StackEvent) Name() string {
+	return e.Event
+}
+
+// TestEvent is delivered by HooksService.Test. Its body is the one reported
+// in TestHook.Result.Request.Body.
+type TestEvent struct {
+	Event  string           `json:"event"`
+	SentAt string           `json:"sent_at"`
+	HookID string           `json:"hook_id"`
+	Stack  stacksmith.Stack `json:"stack"`
+}
+
+// Name ...
+func (e *TestEvent) Name() string {
+	return e.Event
+}
+
+// UnknownEvent holds a delivery whose event name this package does not know about.
+type UnknownEvent struct {
+	Event string          `json:"event"`
+	Raw   json.RawMessage `json:"-"`
+}
+
+// Name ...
+func (e *UnknownEvent) Name() string {
+	return e.Event
+}
+
+// ParseEvent decodes a delivery body into its typed event.
+func ParseEvent(body []byte) (Event, error) {
+	envelope := new(struct {
+		Event string `json:"event"`
+	})
+	if err := json.Unmarshal(body, envelope); err != nil {
+		return nil, fmt.Errorf("webhook: malformed payload: %v", err)
+	}
+
+	var event Event
+	switch envelope.Event {
+	case "":
+		return nil, ErrMissingEvent
+	case EventTest:
+		event = new(TestEvent)
+	case EventStackUpdated, EventStackOutdated, EventStackVulnerable:
+		event = new(StackEvent)
+	default:
+		return &UnknownEvent{Event: envelope.Event, Raw: json.RawMessage(body)}, nil
+	}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("webhook: malformed %s payload: %v", envelope.Event, err)
+	}
+	return event, nil
+}
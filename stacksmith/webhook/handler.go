@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// DefaultMaxBodySize is the largest delivery accepted by a Handler unless told otherwise.
+const DefaultMaxBodySize = 1 << 20
+
+// HandlerFunc handles one decoded delivery. Returning an error answers the
+// delivery with a 500 so that Stacksmith treats it as failed.
+type HandlerFunc func(Event) error
+
+// Handler is an http.Handler decoding Stacksmith hook deliveries and
+// dispatching them to the functions registered for each event.
+//
+// Deliveries are answered with:
+//
+//	200 when the registered function succeeded,
+//	202 when no function is registered for the event,
+//	400 when the payload is malformed,
+//	401 when the delivery fails the Verifier,
+//	405 when the request is not a POST,
+//	413 when the body is larger than MaxBodySize,
+//	415 when the body is not JSON,
+//	500 when the registered function failed.
+type Handler struct {
+	// MaxBodySize limits the size of accepted deliveries. Zero means DefaultMaxBodySize.
+	MaxBodySize int64
+	// Verifier, when set, authenticates every delivery before it is decoded.
+	Verifier *Verifier
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+// NewHandler returns a Handler without any registered function.
+func NewHandler() *Handler {
+	return &Handler{
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers fn for the deliveries of the given event, replacing any
+// previous registration.
+func (h *Handler) Handle(event string, fn HandlerFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.handlers == nil {
+		h.handlers = make(map[string]HandlerFunc)
+	}
+	h.handlers[event] = fn
+}
+
+// OnTest registers fn for the deliveries sent by HooksService.Test.
+func (h *Handler) OnTest(fn func(*TestEvent) error) {
+	h.Handle(EventTest, func(e Event) error {
+		return fn(e.(*TestEvent))
+	})
+}
+
+// OnStackUpdated registers fn for the stack.updated deliveries.
+func (h *Handler) OnStackUpdated(fn func(*StackEvent) error) {
+	h.handleStack(EventStackUpdated, fn)
+}
+
+// OnStackOutdated registers fn for the stack.outdated deliveries.
+func (h *Handler) OnStackOutdated(fn func(*StackEvent) error) {
+	h.handleStack(EventStackOutdated, fn)
+}
+
+// OnStackVulnerable registers fn for the stack.vulnerable deliveries.
+func (h *Handler) OnStackVulnerable(fn func(*StackEvent) error) {
+	h.handleStack(EventStackVulnerable, fn)
+}
+
+func (h *Handler) handleStack(event string, fn func(*StackEvent) error) {
+	h.Handle(event, func(e Event) error {
+		return fn(e.(*StackEvent))
+	})
+}
+
+func (h *Handler) lookup(event string) HandlerFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.handlers[event]
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "webhook: method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isJSON(r.Header.Get("Content-Type")) {
+		http.Error(w, "webhook: unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	maxBodySize := h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		http.Error(w, "webhook: cannot read payload", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > maxBodySize {
+		http.Error(w, "webhook: payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if h.Verifier != nil {
+		if err := h.Verifier.Verify(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	event, err := ParseEvent(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fn := h.lookup(event.Name())
+	if fn == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := fn(event); err != nil {
+		http.Error(w, "webhook: delivery not processed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// isJSON accepts a missing content type, as some deliveries are sent without one.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
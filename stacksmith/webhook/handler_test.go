@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+const stackUpdated = `{"event":"stack.updated","sent_at":"2016-08-22T10:12:43.000Z","stack":{"id":"bzr9nhz","name":"My ROR stack2","outdated":false}}`
+
+func deliver(h http.Handler, method, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/hooks", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestParseEvent_TestHookBody(t *testing.T) {
+	testHook := new(stacksmith.TestHook)
+	json.Unmarshal(utils.GetJSON("hook_test"), testHook)
+
+	event, err := ParseEvent([]byte(testHook.Result.Request.Body))
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	testEvent, ok := event.(*TestEvent)
+	if !ok {
+		t.Fatalf("ParseEvent returned %T, want *TestEvent", event)
+	}
+	if testEvent.HookID != "hk29x1a" || testEvent.Stack.ID != "bzr9nhz" {
+		t.Errorf("ParseEvent returned %+v", testEvent)
+	}
+}
+
+func TestParseEvent_Errors(t *testing.T) {
+	if _, err := ParseEvent([]byte(`{"stack":{}}`)); err != ErrMissingEvent {
+		t.Errorf("ParseEvent without event returned %v, want %v", err, ErrMissingEvent)
+	}
+	if _, err := ParseEvent([]byte(`{"event":`)); err == nil {
+		t.Errorf("ParseEvent of truncated payload returned no error")
+	}
+	event, err := ParseEvent([]byte(`{"event":"stack.renamed"}`))
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	if _, ok := event.(*UnknownEvent); !ok || event.Name() != "stack.renamed" {
+		t.Errorf("ParseEvent returned %+v, want an UnknownEvent", event)
+	}
+}
+
+func TestHandler_Dispatch(t *testing.T) {
+	h := NewHandler()
+	var received *StackEvent
+	h.OnStackUpdated(func(e *StackEvent) error {
+		received = e
+		return nil
+	})
+
+	w := deliver(h, "POST", "application/json; charset=utf-8", stackUpdated)
+	if w.Code != http.StatusOK {
+		t.Errorf("Handler answered %d, want %d", w.Code, http.StatusOK)
+	}
+	if received == nil || received.Stack.ID != "bzr9nhz" {
+		t.Errorf("Handler dispatched %+v", received)
+	}
+}
+
+func TestHandler_StatusCodes(t *testing.T) {
+	h := NewHandler()
+	h.MaxBodySize = 256
+	h.OnStackOutdated(func(e *StackEvent) error {
+		return errors.New("boom")
+	})
+
+	var cases = []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"wrong method", "GET", "", "", http.StatusMethodNotAllowed},
+		{"wrong content type", "POST", "text/plain", stackUpdated, http.StatusUnsupportedMediaType},
+		{"oversized", "POST", "application/json", `{"event":"test","pad":"` + strings.Repeat("x", 256) + `"}`, http.StatusRequestEntityTooLarge},
+		{"malformed", "POST", "application/json", `{"event":`, http.StatusBadRequest},
+		{"no event", "POST", "application/json", `{}`, http.StatusBadRequest},
+		{"unhandled", "POST", "application/json", stackUpdated, http.StatusAccepted},
+		{"failing handler", "POST", "", `{"event":"stack.outdated","stack":{"id":"x"}}`, http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		if w := deliver(h, c.method, c.contentType, c.body); w.Code != c.want {
+			t.Errorf("%s: Handler answered %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+)
+
+// SecretURL returns hookURL with the token derived from secret added to its
+// query, as checked by Verifier for unsigned deliveries.
+func SecretURL(hookURL, secret string) (string, error) {
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(TokenParam, Token(secret))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Register registers hookURL for the stack with the token derived from secret embedded.
+func Register(hooks *stacksmith.HooksService, stackID, hookURL, secret string) (*stacksmith.ResponseGeneration, *http.Response, error) {
+	secretURL, err := SecretURL(hookURL, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hooks.Register(stackID, &stacksmith.HookParams{URL: secretURL})
+}
+
+// Update points an existing hook at hookURL with the token derived from secret
+// embedded, which is how a secret is rotated.
+func Update(hooks *stacksmith.HooksService, stackID, hookID, hookURL, secret string) (*stacksmith.ResponseGeneration, *http.Response, error) {
+	secretURL, err := SecretURL(hookURL, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hooks.Update(stackID, hookID, &stacksmith.HookParams{URL: secretURL})
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers and query parameter used to authenticate deliveries.
+// DeliveryHeader identifies a delivery in logs; it is not signed, so replays
+// are detected from the signature or the body instead.
+const (
+	SignatureHeader = "X-Stacksmith-Signature"
+	TimestampHeader = "X-Stacksmith-Timestamp"
+	DeliveryHeader  = "X-Stacksmith-Delivery"
+	TokenParam      = "token"
+)
+
+// DefaultWindow is how far a delivery timestamp may drift from the local clock.
+const DefaultWindow = 5 * time.Minute
+
+// Errors returned by Verifier.Verify.
+var (
+	ErrMissingSignature = errors.New("webhook: delivery is not signed")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrInvalidToken     = errors.New("webhook: invalid token")
+	ErrStaleDelivery    = errors.New("webhook: delivery timestamp outside the allowed window")
+	ErrReplayedDelivery = errors.New("webhook: delivery already received")
+)
+
+// NonceCache remembers the deliveries already accepted.
+type NonceCache interface {
+	// Add records nonce until expiry and reports whether it was already recorded.
+	Add(nonce string, expiry time.Time) bool
+}
+
+// Verifier authenticates deliveries with a secret shared with the hook
+// registration.
+//
+// A delivery carrying SignatureHeader must hold the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with Secret, with the unix timestamp sent in
+// TimestampHeader. Otherwise the hook URL must carry the token derived from
+// Secret, as added by SecretURL, unless RequireSignature is set.
+//
+// Both ways reject deliveries whose timestamp is missing or outside Window,
+// and deliveries seen before: those with the same signature, or with the
+// same body when authenticated by token. The timestamp of a signed delivery
+// is the signed TimestampHeader; that of a delivery authenticated by token
+// is the "sent_at" field of its payload, since no header is authenticated
+// then.
+type Verifier struct {
+	Secret string
+	// RequireSignature rejects deliveries authenticated only by the URL token.
+	RequireSignature bool
+	// Window is the allowed clock drift. Zero means DefaultWindow.
+	Window time.Duration
+	// Nonces remembers accepted deliveries. Nil means an in-memory cache.
+	Nonces NonceCache
+
+	once sync.Once
+	now  func() time.Time
+}
+
+// NewVerifier returns a Verifier using secret and an in-memory nonce cache.
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{Secret: secret}
+}
+
+func (v *Verifier) init() {
+	v.once.Do(func() {
+		if v.Window <= 0 {
+			v.Window = DefaultWindow
+		}
+		if v.now == nil {
+			v.now = time.Now
+		}
+		if v.Nonces == nil {
+			v.Nonces = &MemoryNonceCache{nonces: make(map[string]time.Time), now: v.now}
+		}
+	})
+}
+
+// Verify checks that the delivery r, whose body has already been read, was
+// sent by Stacksmith and is not a replay.
+func (v *Verifier) Verify(r *http.Request, body []byte) error {
+	v.init()
+
+	// The timestamp and the nonce are taken from authenticated data only, so
+	// that a replay cannot pass for a new delivery by changing an unsigned
+	// header.
+	var timestamp time.Time
+	var nonce string
+	if signature := r.Header.Get(SignatureHeader); signature != "" {
+		header := r.Header.Get(TimestampHeader)
+		seconds, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		timestamp = time.Unix(seconds, 0)
+		expected := Sign(v.Secret, timestamp, body)
+		if !hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected)) {
+			return ErrInvalidSignature
+		}
+		nonce = expected
+	} else {
+		if v.RequireSignature {
+			return ErrMissingSignature
+		}
+		if !hmac.Equal([]byte(r.URL.Query().Get(TokenParam)), []byte(Token(v.Secret))) {
+			return ErrInvalidToken
+		}
+		var err error
+		if timestamp, err = sentAt(body); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		nonce = hex.EncodeToString(sum[:])
+	}
+
+	now := v.now()
+	if timestamp.Before(now.Add(-v.Window)) || timestamp.After(now.Add(v.Window)) {
+		return ErrStaleDelivery
+	}
+
+	if v.Nonces.Add(nonce, now.Add(2*v.Window)) {
+		return ErrReplayedDelivery
+	}
+	return nil
+}
+
+// sentAt reads the "sent_at" field of a payload, failing with
+// ErrStaleDelivery when it is missing or invalid.
+func sentAt(body []byte) (time.Time, error) {
+	payload := new(struct {
+		SentAt string `json:"sent_at"`
+	})
+	if json.Unmarshal(body, payload) != nil || payload.SentAt == "" {
+		return time.Time{}, ErrStaleDelivery
+	}
+	t, err := time.Parse(time.RFC3339, payload.SentAt)
+	if err != nil {
+		return time.Time{}, ErrStaleDelivery
+	}
+	return t, nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a delivery sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Token returns the token embedded in hook URLs for secret. It is derived
+// from the secret so that a leaked URL does not reveal the signing key.
+func Token(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("stacksmith-hook-token"))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// MemoryNonceCache is a NonceCache kept in memory.
+type MemoryNonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	now    func() time.Time
+}
+
+// NewMemoryNonceCache returns an empty MemoryNonceCache.
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Add records nonce until expiry and reports whether it was already recorded.
+// Expired nonces are dropped on the way.
+func (c *MemoryNonceCache) Add(nonce string, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for n, e := range c.nonces {
+		if e.Before(now) {
+			delete(c.nonces, n)
+		}
+	}
+	if _, ok := c.nonces[nonce]; ok {
+		return true
+	}
+	c.nonces[nonce] = expiry
+	return false
+}
@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JesusTinoco/go-smith/stacksmith"
+	"github.com/JesusTinoco/go-smith/stacksmith/utils"
+)
+
+var fixedNow = time.Date(2016, 8, 22, 10, 14, 0, 0, time.UTC)
+
+func newTestVerifier() *Verifier {
+	v := NewVerifier("s3cr3t")
+	v.now = func() time.Time { return fixedNow }
+	return v
+}
+
+func signedRequest(secret string, at time.Time, body, delivery string) *http.Request {
+	r := httptest.NewRequest("POST", "/hooks", strings.NewReader(body))
+	r.Header.Set(TimestampHeader, strconv.FormatInt(at.Unix(), 10))
+	r.Header.Set(SignatureHeader, "sha256="+Sign(secret, at, []byte(body)))
+	if delivery != "" {
+		r.Header.Set(DeliveryHeader, delivery)
+	}
+	return r
+}
+
+func TestVerifier_Signature(t *testing.T) {
+	v := newTestVerifier()
+	body := []byte(stackUpdated)
+
+	var cases = []struct {
+		name string
+		req  *http.Request
+		want error
+	}{
+		{"valid", signedRequest("s3cr3t", fixedNow, stackUpdated, "d1"), nil},
+		{"replayed", signedRequest("s3cr3t", fixedNow, stackUpdated, "d1"), ErrReplayedDelivery},
+		{"replayed with another delivery ID", signedRequest("s3cr3t", fixedNow, stackUpdated, "d5"), ErrReplayedDelivery},
+		{"resent later", signedRequest("s3cr3t", fixedNow.Add(time.Second), stackUpdated, "d1"), nil},
+		{"wrong secret", signedRequest("guess", fixedNow, stackUpdated, "d2"), ErrInvalidSignature},
+		{"stale", signedRequest("s3cr3t", fixedNow.Add(-time.Hour), stackUpdated, "d3"), ErrStaleDelivery},
+		{"future", signedRequest("s3cr3t", fixedNow.Add(time.Hour), stackUpdated, "d4"), ErrStaleDelivery},
+	}
+
+	for _, c := range cases {
+		if err := v.Verify(c.req, body); err != c.want {
+			t.Errorf("%s: Verify returned %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestVerifier_Token(t *testing.T) {
+	v := newTestVerifier()
+	body := []byte(stackUpdated)
+
+	hookURL, _ := SecretURL("https://example.com/hooks?team=ops", "s3cr3t")
+	r := httptest.NewRequest("POST", hookURL, strings.NewReader(stackUpdated))
+	if err := v.Verify(r, body); err != nil {
+		t.Errorf("Verify returned %v, want nil", err)
+	}
+	if err := v.Verify(r, body); err != ErrReplayedDelivery {
+		t.Errorf("Verify of the same body returned %v, want %v", err, ErrReplayedDelivery)
+	}
+
+	r = httptest.NewRequest("POST", "https://example.com/hooks?token=nope", nil)
+	if err := v.Verify(r, body); err != ErrInvalidToken {
+		t.Errorf("Verify with a wrong token returned %v, want %v", err, ErrInvalidToken)
+	}
+
+	v.RequireSignature = true
+	r = httptest.NewRequest("POST", hookURL, nil)
+	if err := v.Verify(r, []byte(`{"event":"test"}`)); err != ErrMissingSignature {
+		t.Errorf("Verify without signature returned %v, want %v", err, ErrMissingSignature)
+	}
+}
+
+func TestVerifier_SentAtWindow(t *testing.T) {
+	v := newTestVerifier()
+	now := fixedNow
+	v.now = func() time.Time { return now }
+	hookURL, _ := SecretURL("https://example.com/hooks", "s3cr3t")
+
+	// stackUpdated was sent at 10:12:43, within the window of fixedNow.
+	r := httptest.NewRequest("POST", hookURL, nil)
+	if err := v.Verify(r, []byte(stackUpdated)); err != nil {
+		t.Errorf("Verify returned %v, want nil", err)
+	}
+
+	old := strings.Replace(stackUpdated, "2016-08-22T10:12:43", "2016-08-21T10:12:43", 1)
+	if err := v.Verify(r, []byte(old)); err != ErrStaleDelivery {
+		t.Errorf("Verify of an old delivery returned %v, want %v", err, ErrStaleDelivery)
+	}
+
+	// Once its nonce expired, a captured body replayed with a fresh, unsigned
+	// timestamp header is still judged by its sent_at.
+	now = fixedNow.Add(2*v.Window + time.Minute)
+	r = httptest.NewRequest("POST", hookURL, nil)
+	r.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	if err := v.Verify(r, []byte(stackUpdated)); err != ErrStaleDelivery {
+		t.Errorf("Verify of a replay with a new timestamp header returned %v, want %v", err, ErrStaleDelivery)
+	}
+
+	var payload map[string]interface{}
+	json.Unmarshal([]byte(stackUpdated), &payload)
+	delete(payload, "sent_at")
+	undated, _ := json.Marshal(payload)
+	r.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	if err := v.Verify(r, undated); err != ErrStaleDelivery {
+		t.Errorf("Verify of a delivery without sent_at returned %v, want %v", err, ErrStaleDelivery)
+	}
+}
+
+func TestHandler_Verifier(t *testing.T) {
+	h := NewHandler()
+	h.Verifier = newTestVerifier()
+	h.Verifier.RequireSignature = true
+	h.OnStackUpdated(func(e *StackEvent) error { return nil })
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest("s3cr3t", fixedNow, stackUpdated, "d1"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Handler answered %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest("guess", fixedNow, stackUpdated, "d2"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Handler answered %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	response := utils.GetJSON("stack_response")
+	mux.HandleFunc("/api/v1/stacks/stack1/hooks", func(w http.ResponseWriter, r *http.Request) {
+		params := new(stacksmith.HookParams)
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, params)
+		if want := "https://example.com/hooks?token=" + Token("s3cr3t"); params.URL != want {
+			t.Errorf("Register sent URL %q, want %q", params.URL, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	})
+
+	client := stacksmith.NewClient("my_api_key", utils.RedirectClient(server.URL))
+	if _, _, err := Register(client.Hooks, "stack1", "https://example.com/hooks", "s3cr3t"); err != nil {
+		t.Errorf("Register returned error: %v", err)
+	}
+}